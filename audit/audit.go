@@ -10,10 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nixmade/pippy/helpers"
 	"github.com/nixmade/pippy/store"
 
-	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/lipgloss/table"
 	"github.com/google/uuid"
 	"github.com/urfave/cli/v3"
 )
@@ -27,10 +26,23 @@ type Audit struct {
 	Resource map[string]string
 	Actor    string
 	Email    string
+	Source   string
 	Message  string
 }
 
-func Save(ctx context.Context, name string, resource map[string]string, actor, email, msg string) error {
+// Entry audit printed by audit list with --output json or yaml, id and type are read from audit key
+type Entry struct {
+	Id       string            `json:"id"`
+	Type     string            `json:"type"`
+	Time     time.Time         `json:"time"`
+	Resource map[string]string `json:"resource"`
+	Actor    string            `json:"actor"`
+	Email    string            `json:"email"`
+	Source   string            `json:"source,omitempty"`
+	Message  string            `json:"message"`
+}
+
+func Save(ctx context.Context, name string, resource map[string]string, actor, email, source, msg string) error {
 	dbStore, err := store.Get(ctx)
 	if err != nil {
 		return err
@@ -48,6 +60,7 @@ func Save(ctx context.Context, name string, resource map[string]string, actor, e
 		Resource: resource,
 		Actor:    actor,
 		Email:    email,
+		Source:   source,
 		Message:  msg,
 	}
 	return dbStore.SaveJSON(key, &auditFields)
@@ -118,69 +131,56 @@ func ListAuditsN(ctx context.Context, limit int64) (map[string]Audit, error) {
 	return audits, nil
 }
 
-func ListAuditsUI(limit int64) error {
-	audits, err := ListAuditsN(context.Background(), limit)
+// ListEntries audits sorted latest first
+func ListEntries(ctx context.Context, limit int64) ([]Entry, error) {
+	audits, err := ListAuditsN(ctx, limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rows := [][]string{}
+	entries := []Entry{}
 	for auditKey, data := range audits {
 		strippedAuditKey, _ := strings.CutPrefix(auditKey, AuditPrefix)
+		auditType, id, _ := strings.Cut(strippedAuditKey, "/")
+		entries = append(entries, Entry{
+			Id:       id,
+			Type:     auditType,
+			Time:     data.Time,
+			Resource: data.Resource,
+			Actor:    data.Actor,
+			Email:    data.Email,
+			Source:   data.Source,
+			Message:  data.Message,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
 
-		auditNameId := strings.Split(strippedAuditKey, "/")
-		rows = append(rows, []string{data.Time.Format(time.RFC3339), auditNameId[1], auditNameId[0], convertResouceToList(data.Resource), data.Actor, data.Email, data.Message})
+	return entries, nil
+}
 
+// ListAuditsUI lists latest audit entries, wide adds id and email columns
+func ListAuditsUI(limit int64, output string) error {
+	entries, err := ListEntries(context.Background(), limit)
+	if err != nil {
+		return err
 	}
-	sort.Slice(rows, func(i, j int) bool {
-		leftTime, err := time.Parse(time.RFC3339, rows[i][0])
-		if err != nil {
-			return false
-		}
 
-		rightTime, err := time.Parse(time.RFC3339, rows[j][0])
-		if err != nil {
-			return false
+	headers := []string{"TIME", "TYPE", "RESOURCE", "ACTOR", "SOURCE", "MESSAGE"}
+	if output == helpers.OutputWide {
+		headers = append(headers, "ID", "EMAIL")
+	}
+	rows := [][]string{}
+	for _, entry := range entries {
+		row := []string{entry.Time.Format(time.RFC3339), entry.Type, convertResouceToList(entry.Resource), entry.Actor, entry.Source, entry.Message}
+		if output == helpers.OutputWide {
+			row = append(row, entry.Id, entry.Email)
 		}
+		rows = append(rows, row)
+	}
 
-		return leftTime.After(rightTime)
-	})
-
-	re := lipgloss.NewRenderer(os.Stdout)
-
-	var (
-		// HeaderStyle is the lipgloss style used for the table headers.
-		HeaderStyle = re.NewStyle().Foreground(lipgloss.Color("#929292")).Bold(true).Align(lipgloss.Center)
-		// CellStyle is the base lipgloss style used for the table rows.
-		CellStyle = re.NewStyle().Padding(0, 1).Width(14)
-		// OddRowStyle is the lipgloss style used for odd-numbered table rows.
-		OddRowStyle = CellStyle.Foreground(lipgloss.Color("#FDFF90"))
-		// EvenRowStyle is the lipgloss style used for even-numbered table rows.
-		EvenRowStyle = CellStyle.Foreground(lipgloss.Color("#97AD64"))
-		// BorderStyle is the lipgloss style used for the table border.
-		BorderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#97AD64"))
-	)
-
-	t := table.New().
-		Width(120).
-		Border(lipgloss.RoundedBorder()).
-		BorderStyle(BorderStyle).
-		Headers("TIME", "ID", "TYPE", "RESOURCE", "ACTOR", "EMAIL", "MESSAGE").
-		Rows(rows...).
-		StyleFunc(func(row, col int) lipgloss.Style {
-			switch {
-			case row == 0:
-				return HeaderStyle
-			case row%2 == 0:
-				return EvenRowStyle
-			default:
-				return OddRowStyle
-			}
-		})
-
-	fmt.Println(t)
-
-	return nil
+	return helpers.WriteOutput(os.Stdout, output, entries, headers, rows)
 }
 
 func convertResouceToList(resource map[string]string) string {
@@ -201,9 +201,8 @@ func Command() *cli.Command {
 				Name:  "list",
 				Usage: "list",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := ListAuditsUI(c.Int64("limit")); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := ListAuditsUI(c.Int64("limit"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
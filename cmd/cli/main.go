@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"sort"
 
 	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 	"github.com/nixmade/pippy/orgs"
 	"github.com/nixmade/pippy/pipelines"
 	"github.com/nixmade/pippy/repos"
+	"github.com/nixmade/pippy/secrets"
 	"github.com/nixmade/pippy/users"
+	"github.com/nixmade/pippy/web"
 	"github.com/nixmade/pippy/workflows"
 
 	"github.com/urfave/cli/v3"
@@ -33,19 +38,31 @@ func main() {
 		Name:    "pippy",
 		Version: fmt.Sprintf("v%s", version),
 		Usage:   "pippy interacts with github actions",
+		Flags:   append(append(users.Flags(), github.Flags()...), helpers.OutputFlag()),
+		Before:  users.Before,
 		Commands: []*cli.Command{
 			users.Command(),
+			users.ProfileCommand(),
 			workflows.Command(),
 			repos.Command(),
 			orgs.Command(),
+			github.Command(),
 			pipelines.Command(),
+			pipelines.UICommand(),
 			audit.Command(),
+			secrets.Command(),
+			web.Command(),
 		},
 	}
 
 	sort.Sort(cli.FlagsByName(appCli.Flags))
 
 	if err := appCli.Run(context.Background(), os.Args); err != nil {
+		// errors printed by commands, eg: as json with --output json, only change exit status
+		var printedErr *helpers.PrintedError
+		if errors.As(err, &printedErr) {
+			os.Exit(1)
+		}
 		log.Fatal(err)
 	}
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v75/github"
@@ -31,10 +33,21 @@ type Client interface {
 	GetWorkflow(org, repo string, id int64) (*Workflow, error)
 	ListWorkflows(org, repo string) ([]Workflow, error)
 	ListWorkflowRuns(org, repo string, workflowID int64, created string) ([]WorkflowRun, error)
+	FindWorkflowRun(org, repo string, workflowID int64, created, match string) (*WorkflowRun, error)
+	ListWorkflowJobs(org, repo string, runID int64) ([]WorkflowJob, error)
+	GetWorkflowRunLogs(org, repo string, runID int64) ([]WorkflowLog, error)
+	GetWorkflowJobLogs(org, repo string, jobID int64) (string, error)
 	CreateWorkflowDispatch(org, repo string, workflowID int64, ref string, inputs map[string]interface{}) error
 	ValidateWorkflow(org, repo, path string) ([]string, map[string]string, error)
 	ValidateWorkflowFull(org, repo, path string) (string, string, error)
+	ListWorkflowInputs(org, repo, path string) ([]WorkflowInput, error)
+	FindPullRequest(org, repo, branch string) (*PullRequest, error)
+	CreatePullRequest(org, repo, branch, title, body string, files map[string]string) (*PullRequest, error)
 	ListOrgsForUser() ([]Org, error)
+	CreateDeployment(org, repo, ref, environment, description string, payload map[string]interface{}) (int64, error)
+	CreateDeploymentStatus(org, repo string, deploymentID int64, state, environment, logUrl, description string) error
+	CurrentRateLimit() *RateLimit
+	ListRateLimits() ([]RateLimit, error)
 }
 
 type Github struct {
@@ -47,10 +60,19 @@ func getAccessToken(ctx context.Context) (accessToken string, err error) {
 		return accessTokenCtx.(string), nil
 	}
 
+	if credentials := users.HeadlessCredentials(); credentials != nil && credentials.Token != "" {
+		return credentials.Token, nil
+	}
+
 	return users.GetCachedAccessToken()
 }
 
 func (g *Github) New() (*github.Client, error) {
+	profile, err := users.ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	privateKeyCtx := g.Value(PrivateKeyCtx)
 	if privateKeyCtx != nil {
 		privateKey, err := base64.StdEncoding.DecodeString(privateKeyCtx.(string))
@@ -65,18 +87,47 @@ func (g *Github) New() (*github.Client, error) {
 		if installationIDCtx == nil {
 			return nil, fmt.Errorf("installation id is not provided or empty")
 		}
-		tr, err := ghinstallation.New(http.DefaultTransport, appIDCtx.(int64), installationIDCtx.(int64), privateKey)
+		tr, err := ghinstallation.New(transport, appIDCtx.(int64), installationIDCtx.(int64), privateKey)
+		if err != nil {
+			return nil, err
+		}
+		tr.BaseURL = profile.ApiUrl
+		return withProfile(github.NewClient(&http.Client{Transport: tr}), profile)
+	}
+
+	if credentials := users.HeadlessCredentials(); credentials != nil && credentials.AppID != 0 {
+		tr, err := ghinstallation.New(transport, credentials.AppID, credentials.InstallationID, credentials.PrivateKey)
 		if err != nil {
 			return nil, err
 		}
-		return github.NewClient(&http.Client{Transport: tr}), nil
+		tr.BaseURL = profile.ApiUrl
+		return withProfile(github.NewClient(&http.Client{Transport: tr}), profile)
 	}
 
 	accessToken, err := getAccessToken(g.Context)
 	if err != nil {
 		return nil, err
 	}
-	return github.NewClient(nil).WithAuthToken(accessToken), nil
+	return withProfile(github.NewClient(&http.Client{Transport: transport}).WithAuthToken(accessToken), profile)
+}
+
+// withProfile routes client to profile endpoints, urls are used as configured unlike WithEnterpriseURLs
+func withProfile(client *github.Client, profile *users.Profile) (*github.Client, error) {
+	if profile.IsDefault() {
+		return client, nil
+	}
+
+	baseURL, err := url.Parse(strings.TrimRight(profile.ApiUrl, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	uploadURL, err := url.Parse(strings.TrimRight(profile.UploadUrl, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = baseURL
+	client.UploadURL = uploadURL
+	return client, nil
 }
 
 var (
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nixmade/pippy/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ghesServer github enterprise server stand in, api is served under /api/v3
+func ghesServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/app/installations/5678/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"ghs_installation","expires_at":"2099-01-01T00:00:00Z"}`))
+		case "/api/v3/user/orgs":
+			assert.Contains(t, []string{"Bearer ghes_token", "token ghs_installation"}, r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`[{"login":"ghesorg","id":1}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	profile, err := users.NewProfile("ghes", server.URL+"/api/v3", "", "", "")
+	require.NoError(t, err)
+	users.UseProfile(profile)
+	t.Cleanup(func() {
+		users.SetProfile(users.DefaultProfile)
+	})
+
+	return server
+}
+
+func TestClientProfile(t *testing.T) {
+	ghesServer(t)
+
+	client := &Github{Context: context.WithValue(context.Background(), AccessTokenCtx, "ghes_token")}
+	orgs, err := client.ListOrgsForUser()
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "ghesorg", orgs[0].Login)
+}
+
+func TestClientProfileApp(t *testing.T) {
+	ghesServer(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKeyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	ctx := context.WithValue(context.Background(), PrivateKeyCtx, base64.StdEncoding.EncodeToString(privateKeyPem))
+	ctx = context.WithValue(ctx, AppIDCtx, int64(1234))
+	ctx = context.WithValue(ctx, InstallationIDCtx, int64(5678))
+
+	client := &Github{Context: ctx}
+	orgs, err := client.ListOrgsForUser()
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "ghesorg", orgs[0].Login)
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// CreateDeployment creates a github deployment for environment, returns deployment id
+func (g *Github) CreateDeployment(org, repo, ref, environment, description string, payload map[string]interface{}) (int64, error) {
+	client, err := g.New()
+	if err != nil {
+		return 0, err
+	}
+
+	request := &github.DeploymentRequest{
+		Ref:         github.Ptr(ref),
+		Environment: github.Ptr(environment),
+		Description: github.Ptr(description),
+		Payload:     payload,
+		AutoMerge:   github.Ptr(false),
+		// workflow is dispatched regardless, skip commit status checks
+		RequiredContexts: &[]string{},
+	}
+	deployment, resp, err := client.Repositories.CreateDeployment(context.Background(), org, repo, request)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return deployment.GetID(), nil
+	}
+
+	return 0, fmt.Errorf("create deployment returned error %s", resp.Status)
+}
+
+// CreateDeploymentStatus sets state of deployment, one of in_progress, success, failure, error or inactive
+func (g *Github) CreateDeploymentStatus(org, repo string, deploymentID int64, state, environment, logUrl, description string) error {
+	client, err := g.New()
+	if err != nil {
+		return err
+	}
+
+	request := &github.DeploymentStatusRequest{
+		State:       github.Ptr(state),
+		Environment: github.Ptr(environment),
+		Description: github.Ptr(description),
+	}
+	if logUrl != "" {
+		request.LogURL = github.Ptr(logUrl)
+	}
+	_, resp, err := client.Repositories.CreateDeploymentStatus(context.Background(), org, repo, deploymentID, request)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return fmt.Errorf("create deployment status returned error %s", resp.Status)
+}
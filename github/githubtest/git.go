@@ -0,0 +1,307 @@
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PullRequest opened against repo, files changed by it are on Head branch
+type PullRequest struct {
+	Number          int
+	Title, Body     string
+	Head, Base, Url string
+	State           string
+	// label owner:branch of head
+	label string
+}
+
+// PullRequests copy of pull requests opened for repo
+func (s *Server) PullRequests(fullName string) []PullRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(fullName)
+	if repo == nil {
+		return nil
+	}
+	var pulls []PullRequest
+	for _, pull := range repo.pulls {
+		pulls = append(pulls, *pull)
+	}
+	return pulls
+}
+
+// ClosePullRequest closes pull request, its branch is left behind like github does
+func (s *Server) ClosePullRequest(fullName string, number int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if repo := s.repo(fullName); repo != nil {
+		for _, pull := range repo.pulls {
+			if pull.Number == number {
+				pull.State = "closed"
+			}
+		}
+	}
+}
+
+// File content of path on branch, empty branch is default branch
+func (s *Server) File(fullName, branch, path string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(fullName)
+	if repo == nil {
+		return "", false
+	}
+	return repo.file(branch, path)
+}
+
+// file caller holds lock
+func (r *Repo) file(branch, path string) (string, bool) {
+	if branch != "" && branch != r.DefaultBranch {
+		if content, ok := r.files[branch][path]; ok {
+			return content, true
+		}
+		if _, ok := r.branches[branch]; !ok {
+			return "", false
+		}
+	}
+	for _, workflow := range r.workflows {
+		if workflow.Path == path && workflow.State != "deleted" {
+			return workflow.Content, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) getRepo(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":           repo.Name,
+		"full_name":      repo.FullName(),
+		"private":        repo.Private,
+		"default_branch": repo.DefaultBranch,
+		"html_url":       fmt.Sprintf("%s/%s", s.URL, repo.FullName()),
+	})
+}
+
+func refJSON(branch, sha string) map[string]interface{} {
+	return map[string]interface{}{"ref": "refs/heads/" + branch, "object": map[string]interface{}{"type": "commit", "sha": sha}}
+}
+
+func (s *Server) getRef(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	branch, ok := strings.CutPrefix(r.PathValue("ref"), "heads/")
+	if repo == nil || !ok || repo.branches[branch] == "" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, refJSON(branch, repo.branches[branch]))
+}
+
+// createRef creates branch, existing branch is rejected like github does
+func (s *Server) createRef(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	branch, ok := strings.CutPrefix(request.Ref, "refs/heads/")
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Reference name must be a branch")
+		return
+	}
+	if _, ok := repo.branches[branch]; ok {
+		writeError(w, http.StatusUnprocessableEntity, "Reference already exists")
+		return
+	}
+	repo.branches[branch] = request.SHA
+	writeJSON(w, http.StatusCreated, refJSON(branch, request.SHA))
+}
+
+// putContents commits file to branch, sha of current file is required to update it
+func (s *Server) putContents(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Message string `json:"message"`
+		Content string `json:"content"`
+		SHA     string `json:"sha"`
+		Branch  string `json:"branch"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(request.Content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	branch := request.Branch
+	if branch == "" {
+		branch = repo.DefaultBranch
+	}
+	if _, ok := repo.branches[branch]; !ok {
+		writeError(w, http.StatusNotFound, "Branch not found")
+		return
+	}
+
+	path := r.PathValue("path")
+	if current, ok := repo.file(branch, path); ok && sha(current) != request.SHA {
+		writeError(w, http.StatusConflict, fmt.Sprintf("%s does not match %s", path, request.SHA))
+		return
+	}
+
+	if branch == repo.DefaultBranch {
+		for _, workflow := range repo.workflows {
+			if workflow.Path == path {
+				workflow.Content = string(content)
+			}
+		}
+	} else {
+		if repo.files[branch] == nil {
+			repo.files[branch] = make(map[string]string)
+		}
+		repo.files[branch][path] = string(content)
+	}
+
+	commit := sha(request.Message + string(content))
+	repo.branches[branch] = commit
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"content": map[string]interface{}{"path": path, "sha": sha(string(content))},
+		"commit":  map[string]interface{}{"sha": commit, "message": request.Message},
+	})
+}
+
+func (s *Server) pullJSON(pull *PullRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"number":   pull.Number,
+		"title":    pull.Title,
+		"body":     pull.Body,
+		"state":    pull.State,
+		"html_url": pull.Url,
+		"head":     map[string]interface{}{"ref": pull.Head, "label": pull.label},
+		"base":     map[string]interface{}{"ref": pull.Base},
+	}
+}
+
+// listPulls filters by state and owner:branch head
+func (s *Server) listPulls(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := r.URL.Query().Get("head")
+
+	s.lock.Lock()
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		s.lock.Unlock()
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var pulls []interface{}
+	for i := len(repo.pulls) - 1; i >= 0; i-- {
+		pull := repo.pulls[i]
+		if state != "all" && pull.State != state {
+			continue
+		}
+		if head != "" && head != pull.label {
+			continue
+		}
+		pulls = append(pulls, s.pullJSON(pull))
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, pulls, "")
+}
+
+func (s *Server) createPull(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if _, ok := repo.branches[request.Head]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "head branch does not exist")
+		return
+	}
+	if _, ok := repo.branches[request.Base]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "base branch does not exist")
+		return
+	}
+	for _, pull := range repo.pulls {
+		if pull.State == "open" && pull.Head == request.Head {
+			writeError(w, http.StatusUnprocessableEntity, "A pull request already exists for "+repo.Owner+":"+request.Head)
+			return
+		}
+	}
+
+	pull := &PullRequest{
+		Number: len(repo.pulls) + 1,
+		Title:  request.Title,
+		Body:   request.Body,
+		Head:   request.Head,
+		Base:   request.Base,
+		State:  "open",
+		label:  repo.Owner + ":" + request.Head,
+	}
+	pull.Url = fmt.Sprintf("%s/%s/pull/%d", s.URL, repo.FullName(), pull.Number)
+	repo.pulls = append(repo.pulls, pull)
+	writeJSON(w, http.StatusCreated, s.pullJSON(pull))
+}
+
+func sha(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
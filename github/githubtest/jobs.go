@@ -0,0 +1,257 @@
+package githubtest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Job of workflow runs, steps log lines are prefixed with timestamps like github does
+type Job struct {
+	Name  string
+	Steps []JobStep
+}
+
+// JobStep step of job, empty conclusion succeeds
+type JobStep struct {
+	Name       string
+	Conclusion string
+	Log        []string
+}
+
+// SetJobs jobs reported for every run of workflow. While run is in progress only first job is completed,
+// all jobs complete with run
+func (s *Server) SetJobs(workflow *Workflow, jobs ...Job) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	workflow.jobs = jobs
+}
+
+// runJob job of workflow run with its status
+type runJob struct {
+	Job
+	id                 int64
+	status, conclusion string
+	started            time.Time
+}
+
+func (j runJob) stepStarted(i int) time.Time {
+	return j.started.Add(time.Duration(i) * time.Second)
+}
+
+func (j runJob) stepConclusion(i int) string {
+	if j.Steps[i].Conclusion == "" {
+		return "success"
+	}
+	return j.Steps[i].Conclusion
+}
+
+// log job log with timestamped lines of every step
+func (j runJob) log() string {
+	var lines []string
+	for i, step := range j.Steps {
+		for k, line := range step.Log {
+			timestamp := j.stepStarted(i).Add(time.Duration(k+1) * time.Millisecond)
+			lines = append(lines, timestamp.Format("2006-01-02T15:04:05.0000000Z")+" "+line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// runJobs jobs of run, caller holds lock
+func runJobs(workflow *Workflow, run *Run) []runJob {
+	step := run.Step()
+	var jobs []runJob
+	for i, job := range workflow.jobs {
+		jobRun := runJob{
+			Job:     job,
+			id:      run.Id*100 + int64(i) + 1,
+			status:  step.Status,
+			started: run.Created.Add(time.Duration(i*len(job.Steps)) * time.Second),
+		}
+		if step.Status == "in_progress" && i == 0 && len(workflow.jobs) > 1 {
+			jobRun.status = "completed"
+		}
+		if jobRun.status == "completed" {
+			jobRun.conclusion = "success"
+			for k := range job.Steps {
+				if conclusion := jobRun.stepConclusion(k); conclusion != "success" {
+					jobRun.conclusion = conclusion
+				}
+			}
+		}
+		jobs = append(jobs, jobRun)
+	}
+	return jobs
+}
+
+// findRun workflow run by id, caller holds lock
+func (s *Server) findRun(r *http.Request, id int64) (*Workflow, *Run) {
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		return nil, nil
+	}
+	for _, workflow := range repo.workflows {
+		for _, run := range workflow.runs {
+			if run.Id == id {
+				return workflow, run
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findJob workflow run job by id, caller holds lock
+func (s *Server) findJob(r *http.Request, id int64) (*runJob, bool) {
+	workflow, run := s.findRun(r, id/100)
+	if run == nil {
+		return nil, false
+	}
+	for _, job := range runJobs(workflow, run) {
+		if job.id == id {
+			return &job, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.lock.Lock()
+	workflow, run := s.findRun(r, id)
+	if run == nil {
+		s.lock.Unlock()
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var jobs []interface{}
+	for _, job := range runJobs(workflow, run) {
+		var steps []interface{}
+		for i, step := range job.Steps {
+			stepJSON := map[string]interface{}{"name": step.Name, "number": i + 1, "status": job.status}
+			if job.status != "queued" {
+				stepJSON["started_at"] = job.stepStarted(i).Format(time.RFC3339)
+			}
+			if job.status == "completed" {
+				stepJSON["conclusion"] = job.stepConclusion(i)
+				stepJSON["completed_at"] = job.stepStarted(i + 1).Format(time.RFC3339)
+			}
+			steps = append(steps, stepJSON)
+		}
+		jobJSON := map[string]interface{}{
+			"id":         job.id,
+			"run_id":     run.Id,
+			"name":       job.Name,
+			"status":     job.status,
+			"conclusion": job.conclusion,
+			"html_url":   fmt.Sprintf("%s/%s/%s/actions/runs/%d/job/%d", s.URL, r.PathValue("owner"), r.PathValue("repo"), run.Id, job.id),
+			"started_at": job.started.Format(time.RFC3339),
+			"steps":      steps,
+		}
+		if job.status == "completed" {
+			jobJSON["completed_at"] = job.stepStarted(len(job.Steps)).Format(time.RFC3339)
+		}
+		jobs = append(jobs, jobJSON)
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, jobs, "jobs")
+}
+
+// getRunLogs redirects to log archive, github has no archive until run completes
+func (s *Server) getRunLogs(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.lock.Lock()
+	_, run := s.findRun(r, id)
+	completed := run != nil && run.Step().Status == "completed"
+	s.lock.Unlock()
+
+	if !completed {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%s/download/%s/%s/runs/%d", s.URL, r.PathValue("owner"), r.PathValue("repo"), id), http.StatusFound)
+}
+
+// getJobLogs redirects to job log, github has no log until job completes
+func (s *Server) getJobLogs(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.lock.Lock()
+	job, ok := s.findJob(r, id)
+	s.lock.Unlock()
+
+	if !ok || job.status != "completed" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%s/download/%s/%s/jobs/%d", s.URL, r.PathValue("owner"), r.PathValue("repo"), id), http.StatusFound)
+}
+
+// download serves signed log urls, credentials are rejected so they are never leaked to storage
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "" {
+		writeError(w, http.StatusBadRequest, "Authorization is not accepted with signed urls")
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.PathValue("kind") == "jobs" {
+		job, ok := s.findJob(r, id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("\ufeff" + job.log()))
+		return
+	}
+
+	workflow, run := s.findRun(r, id)
+	if run == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	// archive has job/<number>_<step>.txt for every step and <number>_<job>.txt with whole job log
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for i, job := range runJobs(workflow, run) {
+		files := map[string]string{fmt.Sprintf("%d_%s.txt", i, job.Name): job.log()}
+		for k, step := range job.Steps {
+			stepJob := job
+			stepJob.Steps = []JobStep{step}
+			stepJob.started = job.stepStarted(k)
+			files[fmt.Sprintf("%s/%d_%s.txt", job.Name, k+1, step.Name)] = stepJob.log()
+		}
+		for name, content := range files {
+			file, err := writer.Create(name)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if _, err := file.Write([]byte("\ufeff" + content)); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	_, _ = w.Write(archive.Bytes())
+}
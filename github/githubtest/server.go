@@ -0,0 +1,662 @@
+// Package githubtest in-process fake github api for end to end tests of github.Github and pipeline runs
+package githubtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/users"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Token access token accepted by server
+	Token = "githubtest"
+	// Login user returned for authenticated requests
+	Login = "githubtest"
+
+	apiPath = "/api/v3"
+)
+
+var (
+	// Succeeds queued, in progress then completed successfully
+	Succeeds = []Step{{Status: "queued"}, {Status: "in_progress"}, {Status: "completed", Conclusion: "success"}}
+	// Fails queued, in progress then completed with failure
+	Fails = []Step{{Status: "queued"}, {Status: "in_progress"}, {Status: "completed", Conclusion: "failure"}}
+
+	runNameInputRegex = regexp.MustCompile(`\$\{\{\s*inputs\.([A-Za-z0-9_-]+)\s*\}\}`)
+)
+
+// Step status and conclusion reported for a workflow run
+type Step struct {
+	Status     string
+	Conclusion string
+}
+
+// Script steps for a dispatched workflow run, each listing of workflow runs advances run by one step
+type Script func(inputs map[string]string) []Step
+
+// Steps script with same steps for every dispatch
+func Steps(steps ...Step) Script {
+	return func(map[string]string) []Step {
+		return steps
+	}
+}
+
+type Repo struct {
+	Owner, Name   string
+	Private       bool
+	DefaultBranch string
+	workflows     []*Workflow
+	// branches head sha, files committed to branches other than default branch
+	branches map[string]string
+	files    map[string]map[string]string
+	pulls    []*PullRequest
+}
+
+func (r *Repo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+type Workflow struct {
+	Id         int64
+	Name, Path string
+	Content    string
+	// State active, disabled_manually, disabled_inactivity or deleted, deleted workflow file is gone
+	State  string
+	script Script
+	runs   []*Run
+	jobs   []Job
+}
+
+// Run dispatched workflow run, name is rendered from workflow run-name
+type Run struct {
+	Id       int64
+	Name     string
+	Ref      string
+	Inputs   map[string]string
+	Created  time.Time
+	steps    []Step
+	position int
+}
+
+// Step current step of run
+func (r *Run) Step() Step {
+	if len(r.steps) == 0 {
+		return Step{Status: "completed", Conclusion: "success"}
+	}
+	return r.steps[min(r.position, len(r.steps)-1)]
+}
+
+type DeploymentStatus struct {
+	State, Environment, LogUrl, Description string
+}
+
+type Deployment struct {
+	Id                     int64
+	Repo, Ref, Environment string
+	Description            string
+	Payload                map[string]interface{}
+	Statuses               []DeploymentStatus
+}
+
+type failure struct {
+	method, path string
+	status       int
+	times        int
+}
+
+// Server fake github api, clients are routed to it through users profile
+type Server struct {
+	*httptest.Server
+
+	lock        sync.Mutex
+	repos       []*Repo
+	deployments []*Deployment
+	failures    []*failure
+	nextId      int64
+}
+
+// NewServer starts fake github api, server is the active users profile with Token as headless credentials
+// and github.DefaultClient talks to it until test cleanup
+func NewServer(t testing.TB) *Server {
+	s := &Server{nextId: 1000}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPath+"/user", s.getUser)
+	mux.HandleFunc("GET "+apiPath+"/user/orgs", s.listOrgs)
+	mux.HandleFunc("GET "+apiPath+"/user/repos", s.listRepos)
+	mux.HandleFunc("GET "+apiPath+"/rate_limit", s.getRateLimit)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/workflows", s.listWorkflows)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/workflows/{id}", s.getWorkflow)
+	mux.HandleFunc("POST "+apiPath+"/repos/{owner}/{repo}/actions/workflows/{id}/dispatches", s.createDispatch)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/workflows/{id}/runs", s.listWorkflowRuns)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/runs/{id}/jobs", s.listJobs)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/runs/{id}/logs", s.getRunLogs)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/actions/jobs/{id}/logs", s.getJobLogs)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/contents/{path...}", s.getContents)
+	mux.HandleFunc("PUT "+apiPath+"/repos/{owner}/{repo}/contents/{path...}", s.putContents)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}", s.getRepo)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/git/ref/{ref...}", s.getRef)
+	mux.HandleFunc("POST "+apiPath+"/repos/{owner}/{repo}/git/refs", s.createRef)
+	mux.HandleFunc("GET "+apiPath+"/repos/{owner}/{repo}/pulls", s.listPulls)
+	mux.HandleFunc("POST "+apiPath+"/repos/{owner}/{repo}/pulls", s.createPull)
+	mux.HandleFunc("POST "+apiPath+"/repos/{owner}/{repo}/deployments", s.createDeployment)
+	mux.HandleFunc("POST "+apiPath+"/repos/{owner}/{repo}/deployments/{id}/statuses", s.createDeploymentStatus)
+
+	// signed log urls github redirects to are not authenticated
+	root := http.NewServeMux()
+	root.HandleFunc("GET /download/{owner}/{repo}/{kind}/{id}", s.download)
+	root.Handle("/", s.handler(mux))
+
+	s.Server = httptest.NewServer(root)
+	t.Cleanup(s.Close)
+
+	users.UseProfile(s.Profile())
+	if err := users.SetCredentials(Token, 0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	defaultClient := github.DefaultClient
+	github.DefaultClient = &github.Github{Context: context.Background()}
+	t.Cleanup(func() {
+		github.DefaultClient = defaultClient
+		users.SetProfile(users.DefaultProfile)
+		_ = users.SetCredentials("", 0, 0, "")
+	})
+
+	return s
+}
+
+// Profile users profile routing api and oauth endpoints to server
+func (s *Server) Profile() *users.Profile {
+	return &users.Profile{
+		Name:      "githubtest",
+		ApiUrl:    s.URL + apiPath,
+		UploadUrl: s.URL + "/api/uploads",
+		WebUrl:    s.URL,
+	}
+}
+
+// AddRepo adds repo, owner is also listed as an org
+func (s *Server) AddRepo(fullName string, private bool) *Repo {
+	owner, name, _ := strings.Cut(fullName, "/")
+	repo := &Repo{
+		Owner:         owner,
+		Name:          name,
+		Private:       private,
+		DefaultBranch: "main",
+		branches:      map[string]string{"main": sha("main")},
+		files:         make(map[string]map[string]string),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.repos = append(s.repos, repo)
+	return repo
+}
+
+// AddWorkflow adds workflow with yaml content to repo, name is read from content
+func (s *Server) AddWorkflow(fullName, path, content string, script Script) (*Workflow, error) {
+	definition := struct {
+		Name string `yaml:"name"`
+	}{}
+	if err := yaml.Unmarshal([]byte(content), &definition); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(fullName)
+	if repo == nil {
+		return nil, fmt.Errorf("repo %s not found", fullName)
+	}
+
+	s.nextId++
+	workflow := &Workflow{Id: s.nextId, Name: definition.Name, Path: path, Content: content, State: "active", script: script}
+	if workflow.Name == "" {
+		workflow.Name = path
+	}
+	repo.workflows = append(repo.workflows, workflow)
+	return workflow, nil
+}
+
+// SetScript replaces script used for following dispatches of workflow
+func (s *Server) SetScript(workflow *Workflow, script Script) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	workflow.script = script
+}
+
+// SetState changes workflow state, eg: disabled_manually or deleted
+func (s *Server) SetState(workflow *Workflow, state string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	workflow.State = state
+}
+
+// Fail responds with status for the next times requests matching method and api path, times < 0 fails forever
+func (s *Server) Fail(method, path string, status, times int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, times: times})
+}
+
+// Runs copy of dispatched runs for workflow, oldest first
+func (s *Server) Runs(workflow *Workflow) []Run {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var runs []Run
+	for _, run := range workflow.runs {
+		runs = append(runs, *run)
+	}
+	return runs
+}
+
+// Deployments copy of deployments created for repo
+func (s *Server) Deployments(fullName string) []Deployment {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var deployments []Deployment
+	for _, deployment := range s.deployments {
+		if deployment.Repo == fullName {
+			deployments = append(deployments, *deployment)
+		}
+	}
+	return deployments
+}
+
+// handler authenticates requests and applies scripted failures
+func (s *Server) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization != "Bearer "+Token && authorization != "token "+Token {
+			writeError(w, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Used", "1")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "core")
+
+		if status := s.failure(r.Method, strings.TrimPrefix(r.URL.Path, apiPath)); status != 0 {
+			writeError(w, status, "scripted failure")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) failure(method, path string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, failure := range s.failures {
+		if failure.method != method || failure.path != path || failure.times == 0 {
+			continue
+		}
+		if failure.times > 0 {
+			failure.times--
+		}
+		return failure.status
+	}
+	return 0
+}
+
+func (s *Server) repo(fullName string) *Repo {
+	for _, repo := range s.repos {
+		if repo.FullName() == fullName {
+			return repo
+		}
+	}
+	return nil
+}
+
+// workflow finds workflow from request path, caller holds lock
+func (s *Server) workflow(r *http.Request) (*Repo, *Workflow) {
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return repo, nil
+	}
+	for _, workflow := range repo.workflows {
+		if workflow.Id == id {
+			return repo, workflow
+		}
+	}
+	return repo, nil
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"login": Login, "id": 1, "name": "Github Test", "email": "githubtest@example.com"})
+}
+
+func (s *Server) getRateLimit(w http.ResponseWriter, r *http.Request) {
+	rate := map[string]interface{}{"limit": 5000, "remaining": 4999, "used": 1, "reset": time.Now().Add(time.Hour).Unix()}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resources": map[string]interface{}{"core": rate}})
+}
+
+func (s *Server) listOrgs(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	var orgs []interface{}
+	var seen []string
+	for _, repo := range s.repos {
+		if slices.Contains(seen, repo.Owner) {
+			continue
+		}
+		seen = append(seen, repo.Owner)
+		orgs = append(orgs, map[string]interface{}{"login": repo.Owner, "id": len(seen)})
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, orgs, "")
+}
+
+func (s *Server) listRepos(w http.ResponseWriter, r *http.Request) {
+	repoType := r.URL.Query().Get("type")
+
+	s.lock.Lock()
+	var repos []interface{}
+	for i, repo := range s.repos {
+		if (repoType == "public" && repo.Private) || (repoType == "private" && !repo.Private) {
+			continue
+		}
+		repos = append(repos, map[string]interface{}{
+			"id":        i + 1,
+			"name":      repo.Name,
+			"full_name": repo.FullName(),
+			"private":   repo.Private,
+			"html_url":  fmt.Sprintf("%s/%s", s.URL, repo.FullName()),
+		})
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, repos, "")
+}
+
+func (s *Server) workflowJSON(repo *Repo, workflow *Workflow) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       workflow.Id,
+		"name":     workflow.Name,
+		"path":     workflow.Path,
+		"state":    workflow.State,
+		"html_url": fmt.Sprintf("%s/%s/blob/main/%s", s.URL, repo.FullName(), workflow.Path),
+	}
+}
+
+func (s *Server) listWorkflows(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		s.lock.Unlock()
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var workflows []interface{}
+	for _, workflow := range repo.workflows {
+		workflows = append(workflows, s.workflowJSON(repo, workflow))
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, workflows, "workflows")
+}
+
+func (s *Server) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo, workflow := s.workflow(r)
+	if workflow == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.workflowJSON(repo, workflow))
+}
+
+// getContents file on ref, branches other than default branch see default branch files they have not changed
+func (s *Server) getContents(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repo := s.repo(r.PathValue("owner") + "/" + r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	path := r.PathValue("path")
+	content, ok := repo.file(r.URL.Query().Get("ref"), path)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":     "file",
+		"encoding": "base64",
+		"name":     path[strings.LastIndex(path, "/")+1:],
+		"path":     path,
+		"sha":      sha(content),
+		"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+	})
+}
+
+// createDispatch creates a workflow run, workflow must define workflow_dispatch trigger
+func (s *Server) createDispatch(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Ref    string                 `json:"ref"`
+		Inputs map[string]interface{} `json:"inputs"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, workflow := s.workflow(r)
+	if workflow == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	definition := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(workflow.Content), &definition); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if !dispatchable(definition["on"]) {
+		writeError(w, http.StatusUnprocessableEntity, "Workflow does not have 'workflow_dispatch' trigger")
+		return
+	}
+
+	inputs := make(map[string]string)
+	for key, value := range request.Inputs {
+		inputs[key] = fmt.Sprint(value)
+	}
+
+	name := workflow.Name
+	if runName, ok := definition["run-name"].(string); ok {
+		name = runNameInputRegex.ReplaceAllStringFunc(runName, func(match string) string {
+			return inputs[runNameInputRegex.FindStringSubmatch(match)[1]]
+		})
+	}
+
+	var steps []Step
+	if workflow.script != nil {
+		steps = workflow.script(inputs)
+	}
+
+	s.nextId++
+	workflow.runs = append(workflow.runs, &Run{Id: s.nextId, Name: name, Ref: request.Ref, Inputs: inputs, Created: time.Now().UTC(), steps: steps})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWorkflowRuns newest run first, every listed run advances to its next step
+func (s *Server) listWorkflowRuns(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	repo, workflow := s.workflow(r)
+	if workflow == nil {
+		s.lock.Unlock()
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var runs []interface{}
+	for i := len(workflow.runs) - 1; i >= 0; i-- {
+		run := workflow.runs[i]
+		step := run.Step()
+		runs = append(runs, map[string]interface{}{
+			"id":             run.Id,
+			"name":           workflow.Name,
+			"display_title":  run.Name,
+			"workflow_id":    workflow.Id,
+			"event":          "workflow_dispatch",
+			"status":         step.Status,
+			"conclusion":     step.Conclusion,
+			"html_url":       fmt.Sprintf("%s/%s/actions/runs/%d", s.URL, repo.FullName(), run.Id),
+			"created_at":     run.Created.Format(time.RFC3339),
+			"run_started_at": run.Created.Format(time.RFC3339),
+			"updated_at":     time.Now().UTC().Format(time.RFC3339),
+		})
+		run.position++
+	}
+	s.lock.Unlock()
+
+	writePage(w, r, runs, "workflow_runs")
+}
+
+func (s *Server) createDeployment(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Ref         string                 `json:"ref"`
+		Environment string                 `json:"environment"`
+		Description string                 `json:"description"`
+		Payload     map[string]interface{} `json:"payload"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+	if s.repo(fullName) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.nextId++
+	deployment := &Deployment{Id: s.nextId, Repo: fullName, Ref: request.Ref, Environment: request.Environment, Description: request.Description, Payload: request.Payload}
+	s.deployments = append(s.deployments, deployment)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": deployment.Id, "ref": deployment.Ref, "environment": deployment.Environment})
+}
+
+func (s *Server) createDeploymentStatus(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		State       string `json:"state"`
+		Environment string `json:"environment"`
+		LogUrl      string `json:"log_url"`
+		Description string `json:"description"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, deployment := range s.deployments {
+		if deployment.Id == id {
+			deployment.Statuses = append(deployment.Statuses, DeploymentStatus{State: request.State, Environment: request.Environment, LogUrl: request.LogUrl, Description: request.Description})
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"id": len(deployment.Statuses), "state": request.State})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// dispatchable workflow on trigger includes workflow_dispatch in string, list or map form
+func dispatchable(on interface{}) bool {
+	switch on := on.(type) {
+	case string:
+		return on == "workflow_dispatch"
+	case []interface{}:
+		return slices.Contains(on, interface{}("workflow_dispatch"))
+	case map[string]interface{}:
+		_, ok := on["workflow_dispatch"]
+		return ok
+	}
+	return false
+}
+
+// writePage writes page of items requested with page and per_page, wrapper key wraps items in an object
+func writePage(w http.ResponseWriter, r *http.Request, items []interface{}, wrapper string) {
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(page*perPage, len(items))
+	pageItems := items[start:end]
+	if pageItems == nil {
+		pageItems = []interface{}{}
+	}
+
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+	}
+
+	if wrapper == "" {
+		writeJSON(w, http.StatusOK, pageItems)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total_count": len(items), wrapper: pageItems})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -21,23 +21,28 @@ func (g *Github) ListOrgsForUser() ([]Org, error) {
 		return nil, err
 	}
 
-	opt := &github.ListOptions{}
-	orgs, _, err := client.Organizations.List(context.Background(), "", opt)
-	if err != nil {
-		return nil, err
-	}
-
 	var orgItems []Org
 
-	for i := 0; i < len(orgs); i++ {
-		orgItems = append(orgItems, Org{
-			Name:      orgs[i].GetName(),
-			Id:        orgs[i].GetID(),
-			Login:     orgs[i].GetLogin(),
-			Url:       orgs[i].GetHTMLURL(),
-			Company:   orgs[i].GetCompany(),
-			AvatarURL: orgs[i].GetAvatarURL(),
-		})
+	err = paginate(func(opt github.ListOptions) (*github.Response, bool, error) {
+		orgs, resp, err := client.Organizations.List(context.Background(), "", &opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for i := 0; i < len(orgs); i++ {
+			orgItems = append(orgItems, Org{
+				Name:      orgs[i].GetName(),
+				Id:        orgs[i].GetID(),
+				Login:     orgs[i].GetLogin(),
+				Url:       orgs[i].GetHTMLURL(),
+				Company:   orgs[i].GetCompany(),
+				AvatarURL: orgs[i].GetAvatarURL(),
+			})
+		}
+		return resp, true, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return orgItems, nil
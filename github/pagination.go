@@ -0,0 +1,55 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nixmade/pippy/log"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// perPage github maximum page size
+	perPage = 100
+)
+
+// MaxPages upper bound on pages followed by a single list call, results beyond it are dropped with a warning
+var MaxPages = 20
+
+// Flags global github flags, values are also read from environment
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "github-max-pages",
+			Usage:       fmt.Sprintf("upper bound on pages of %d items listed from github, eg: repos, workflows and workflow runs", perPage),
+			Value:       MaxPages,
+			Destination: &MaxPages,
+			Sources:     cli.EnvVars("PIPPY_GITHUB_MAX_PAGES"),
+			Action: func(ctx context.Context, c *cli.Command, v int) error {
+				if v > 0 {
+					return nil
+				}
+				return fmt.Errorf("please provide github max pages greater than 0")
+			},
+		},
+	}
+}
+
+// paginate calls list for each page until last page or MaxPages, list returns false to stop early
+func paginate(list func(opt github.ListOptions) (*github.Response, bool, error)) error {
+	opt := github.ListOptions{PerPage: perPage}
+	for page := 0; page < MaxPages; page++ {
+		resp, more, err := list(opt)
+		if err != nil {
+			return err
+		}
+		if !more || resp.NextPage == 0 {
+			return nil
+		}
+		opt.Page = resp.NextPage
+	}
+	log.Get().Warn().Int("MaxPages", MaxPages).Int("NextPage", opt.Page).Msg("github list stopped at max pages, remaining items are dropped, raise --github-max-pages")
+	return nil
+}
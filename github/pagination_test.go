@@ -0,0 +1,181 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/users"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type paginatedList struct {
+	total int
+	// wrapper key for list responses wrapped in an object, blank for arrays
+	wrapper string
+	item    func(i int) string
+}
+
+// paginatedServer serves lists page by page with link headers, requested pages are recorded per path
+type paginatedServer struct {
+	*httptest.Server
+	lists map[string]paginatedList
+	pages map[string][]int
+	lock  sync.Mutex
+}
+
+func newPaginatedServer(t *testing.T, lists map[string]paginatedList) *paginatedServer {
+	s := &paginatedServer{lists: lists, pages: make(map[string][]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve(t)))
+	t.Cleanup(s.Close)
+
+	profile, err := users.NewProfile("ghes", s.URL+"/api/v3", "", "", "")
+	require.NoError(t, err)
+	users.UseProfile(profile)
+	t.Cleanup(func() {
+		users.SetProfile(users.DefaultProfile)
+	})
+
+	return s
+}
+
+func (s *paginatedServer) serve(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v3")
+		list, ok := s.lists[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		pageSize, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+		require.NoError(t, err)
+		page := 1
+		if r.URL.Query().Get("page") != "" {
+			page, err = strconv.Atoi(r.URL.Query().Get("page"))
+			require.NoError(t, err)
+		}
+
+		s.lock.Lock()
+		s.pages[path] = append(s.pages[path], page)
+		s.lock.Unlock()
+
+		var items []string
+		for i := (page - 1) * pageSize; i < page*pageSize && i < list.total; i++ {
+			items = append(items, list.item(i))
+		}
+
+		lastPage := (list.total + pageSize - 1) / pageSize
+		if page < lastPage {
+			next := *r.URL
+			query := next.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next", <%s%s?per_page=%d&page=%d>; rel="last"`, s.URL, next.RequestURI(), s.URL, r.URL.Path, pageSize, lastPage))
+		}
+
+		body := "[" + strings.Join(items, ",") + "]"
+		if list.wrapper != "" {
+			body = fmt.Sprintf(`{"total_count":%d,"%s":%s}`, list.total, list.wrapper, body)
+		}
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func (s *paginatedServer) requestedPages(path string) []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.pages[path]
+}
+
+func TestPagination(t *testing.T) {
+	server := newPaginatedServer(t, map[string]paginatedList{
+		"/user/repos": {total: 250, item: func(i int) string {
+			return fmt.Sprintf(`{"id":%d,"full_name":"org1/repo%d"}`, i, i)
+		}},
+		"/user/orgs": {total: 101, item: func(i int) string {
+			return fmt.Sprintf(`{"id":%d,"login":"org%d"}`, i, i)
+		}},
+		"/repos/org1/repo1/actions/workflows": {total: 120, wrapper: "workflows", item: func(i int) string {
+			return fmt.Sprintf(`{"id":%d,"name":"workflow%d"}`, i, i)
+		}},
+	})
+
+	client := &Github{Context: context.WithValue(context.Background(), AccessTokenCtx, "ghes_token")}
+
+	repos, err := client.ListRepos("all")
+	require.NoError(t, err)
+	require.Len(t, repos, 250)
+	assert.Equal(t, "org1/repo249", repos[249].Name)
+	assert.Equal(t, []int{1, 2, 3}, server.requestedPages("/user/repos"))
+
+	orgs, err := client.ListOrgsForUser()
+	require.NoError(t, err)
+	require.Len(t, orgs, 101)
+	assert.Equal(t, "org100", orgs[100].Login)
+
+	workflows, err := client.ListWorkflows("org1", "repo1")
+	require.NoError(t, err)
+	require.Len(t, workflows, 120)
+	assert.Equal(t, int64(119), workflows[119].Id)
+}
+
+func TestWorkflowRunsPagination(t *testing.T) {
+	runsPath := "/repos/org1/repo1/actions/workflows/1/runs"
+	server := newPaginatedServer(t, map[string]paginatedList{
+		runsPath: {total: 2500, wrapper: "workflow_runs", item: func(i int) string {
+			return fmt.Sprintf(`{"id":%d,"display_title":"Deploy - run-%d","status":"completed","conclusion":"success"}`, i, i)
+		}},
+	})
+
+	client := &Github{Context: context.WithValue(context.Background(), AccessTokenCtx, "ghes_token")}
+
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+	defaultLogger := log.DefaultLogger
+	log.DefaultLogger = &logger
+	t.Cleanup(func() { log.DefaultLogger = defaultLogger })
+
+	// list is bounded by page limit with a warning
+	workflowRuns, err := client.ListWorkflowRuns("org1", "repo1", 1, ">=2024-01-01")
+	require.NoError(t, err)
+	assert.Len(t, workflowRuns, MaxPages*perPage)
+	assert.Len(t, server.requestedPages(runsPath), MaxPages)
+	assert.Contains(t, logs.String(), "github list stopped at max pages")
+
+	// page limit is configurable
+	maxPages := MaxPages
+	MaxPages = 25
+	t.Cleanup(func() { MaxPages = maxPages })
+	server.pages = make(map[string][]int)
+	logs.Reset()
+	workflowRuns, err = client.ListWorkflowRuns("org1", "repo1", 1, ">=2024-01-01")
+	require.NoError(t, err)
+	assert.Len(t, workflowRuns, 2500)
+	assert.Empty(t, logs.String())
+	MaxPages = maxPages
+
+	// matching run on second page stops listing
+	server.pages = make(map[string][]int)
+	workflowRun, err := client.FindWorkflowRun("org1", "repo1", 1, ">=2024-01-01", "run-150")
+	require.NoError(t, err)
+	require.NotNil(t, workflowRun)
+	assert.Equal(t, int64(150), workflowRun.Id)
+	assert.Equal(t, "Deploy - run-150", workflowRun.Name)
+	assert.Equal(t, []int{1, 2}, server.requestedPages(runsPath))
+
+	// run beyond page limit is not found
+	workflowRun, err = client.FindWorkflowRun("org1", "repo1", 1, ">=2024-01-01", "run-2400")
+	require.NoError(t, err)
+	assert.Nil(t, workflowRun)
+}
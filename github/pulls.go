@@ -0,0 +1,119 @@
+package github
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+)
+
+type PullRequest struct {
+	Number int
+	Title  string
+	Url    string
+	Branch string
+}
+
+// FindPullRequest open pull request from branch or a branch named branch-<suffix> of repo, nil when there is none
+func (g *Github) FindPullRequest(org, repo, branch string) (*PullRequest, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *PullRequest
+	opt := &github.PullRequestListOptions{State: "open"}
+	err = paginate(func(listOpt github.ListOptions) (*github.Response, bool, error) {
+		opt.ListOptions = listOpt
+		pulls, resp, err := client.PullRequests.List(context.Background(), org, repo, opt)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, pull := range pulls {
+			// label is owner:branch, pull requests from forks are not ours
+			label := pull.GetHead().GetLabel()
+			if label == org+":"+branch || strings.HasPrefix(label, org+":"+branch+"-") {
+				found = &PullRequest{
+					Number: pull.GetNumber(),
+					Title:  pull.GetTitle(),
+					Url:    pull.GetHTMLURL(),
+					Branch: pull.GetHead().GetRef(),
+				}
+				return resp, false, nil
+			}
+		}
+		return resp, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// CreatePullRequest commits files (path to content) to branch and opens a pull request against default branch,
+// branch is created from default branch head and must not exist, existing branches are never reset
+func (g *Github) CreatePullRequest(org, repo, branch, title, body string, files map[string]string) (*PullRequest, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	repository, _, err := client.Repositories.Get(ctx, org, repo)
+	if err != nil {
+		return nil, err
+	}
+	base := repository.GetDefaultBranch()
+
+	baseRef, _, err := client.Git.GetRef(ctx, org, repo, "heads/"+base)
+	if err != nil {
+		return nil, err
+	}
+	sha := baseRef.GetObject().GetSHA()
+
+	if _, _, err := client.Git.CreateRef(ctx, org, repo, github.CreateRef{Ref: "refs/heads/" + branch, SHA: sha}); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		fileContent, _, _, err := client.Repositories.GetContents(ctx, org, repo, path, &github.RepositoryContentGetOptions{Ref: branch})
+		if err != nil {
+			return nil, err
+		}
+
+		opt := &github.RepositoryContentFileOptions{
+			Message: github.Ptr("Update " + path + " for pippy"),
+			Content: []byte(files[path]),
+			SHA:     github.Ptr(fileContent.GetSHA()),
+			Branch:  github.Ptr(branch),
+		}
+		if _, _, err := client.Repositories.UpdateFile(ctx, org, repo, path, opt); err != nil {
+			return nil, err
+		}
+	}
+
+	pull, _, err := client.PullRequests.Create(ctx, org, repo, &github.NewPullRequest{
+		Title: github.Ptr(title),
+		Head:  github.Ptr(branch),
+		Base:  github.Ptr(base),
+		Body:  github.Ptr(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PullRequest{
+		Number: pull.GetNumber(),
+		Title:  pull.GetTitle(),
+		Url:    pull.GetHTMLURL(),
+		Branch: branch,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nixmade/pippy/helpers"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/urfave/cli/v3"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "github",
+		Usage: "github api",
+		Commands: []*cli.Command{
+			{
+				Name:  "ratelimit",
+				Usage: "show current github api quota, does not count against quota",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := RunListRateLimits(DefaultClient, c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// CurrentRateLimit last observed core quota, nil until github responds with quota headers
+func (g *Github) CurrentRateLimit() *RateLimit {
+	return transport.rateLimit(RATE_LIMIT_CORE)
+}
+
+// ListRateLimits quota for each api resource, core quota tracked by transport is refreshed
+func (g *Github) ListRateLimits() ([]RateLimit, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits, _, err := client.RateLimit.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []struct {
+		name string
+		rate *github.Rate
+	}{
+		{RATE_LIMIT_CORE, rateLimits.Core},
+		{"search", rateLimits.Search},
+		{"graphql", rateLimits.GraphQL},
+		{"code_search", rateLimits.CodeSearch},
+		{"actions_runner_registration", rateLimits.ActionsRunnerRegistration},
+	}
+
+	var rateLimitItems []RateLimit
+	for _, resource := range resources {
+		if resource.rate == nil {
+			continue
+		}
+		rateLimit := RateLimit{
+			Resource:  resource.name,
+			Limit:     resource.rate.Limit,
+			Remaining: resource.rate.Remaining,
+			Used:      resource.rate.Used,
+			Reset:     resource.rate.Reset.Time,
+		}
+		if resource.name == RATE_LIMIT_CORE {
+			transport.setRateLimit(rateLimit)
+		}
+		rateLimitItems = append(rateLimitItems, rateLimit)
+	}
+
+	return rateLimitItems, nil
+}
+
+func RunListRateLimits(client Client, output string) error {
+	rateLimits, err := client.ListRateLimits()
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, rateLimit := range rateLimits {
+		rows = append(rows, []string{
+			rateLimit.Resource,
+			strconv.Itoa(rateLimit.Limit),
+			strconv.Itoa(rateLimit.Remaining),
+			strconv.Itoa(rateLimit.Used),
+			rateLimit.Reset.Local().Format(time.RFC3339),
+		})
+	}
+
+	headers := []string{"RESOURCE", "LIMIT", "REMAINING", "USED", "RESET"}
+	if helpers.Structured(output) {
+		if rateLimits == nil {
+			rateLimits = []RateLimit{}
+		}
+		return helpers.WriteOutput(os.Stdout, output, rateLimits, headers, rows)
+	}
+
+	// resources with low remaining quota are highlighted
+	fmt.Println(helpers.RenderHighlightTable(headers, rows, func(row int) bool {
+		return rateLimits[row].Low()
+	}))
+
+	return nil
+}
@@ -20,20 +20,26 @@ func (g *Github) ListRepos(repoType string) ([]Repo, error) {
 		return nil, err
 	}
 
-	opt := &github.RepositoryListByAuthenticatedUserOptions{Type: repoType}
-	repos, _, err := client.Repositories.ListByAuthenticatedUser(context.Background(), opt)
-	if err != nil {
-		return nil, err
-	}
-
 	var repoItems []Repo
 
-	for i := 0; i < len(repos); i++ {
-		repoItems = append(repoItems, Repo{
-			Name:   repos[i].GetFullName(),
-			Url:    repos[i].GetHTMLURL(),
-			Detail: repos[i].GetDescription(),
-		})
+	err = paginate(func(listOpt github.ListOptions) (*github.Response, bool, error) {
+		opt := &github.RepositoryListByAuthenticatedUserOptions{Type: repoType, ListOptions: listOpt}
+		repos, resp, err := client.Repositories.ListByAuthenticatedUser(context.Background(), opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for i := 0; i < len(repos); i++ {
+			repoItems = append(repoItems, Repo{
+				Name:   repos[i].GetFullName(),
+				Url:    repos[i].GetHTMLURL(),
+				Detail: repos[i].GetDescription(),
+			})
+		}
+		return resp, true, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return repoItems, nil
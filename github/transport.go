@@ -0,0 +1,330 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/log"
+)
+
+const (
+	RATE_LIMIT_CORE = "core"
+
+	// etagCacheSize upper bound on cached GET responses
+	etagCacheSize = 500
+)
+
+var (
+	ErrRateLimited = errors.New("github rate limit exceeded")
+
+	// MaxRetries attempts for 5xx and secondary rate limit errors, backoff doubles after every attempt with jitter
+	MaxRetries   = 3
+	RetryBackoff = time.Second
+	// MaxRateLimitWait upper bound to wait for quota reset or Retry-After, requests fail with ErrRateLimited beyond it
+	MaxRateLimitWait = time.Minute
+	// LowRateLimitPercent remaining quota below this percent of limit is considered low
+	LowRateLimitPercent = 10
+
+	// transport shared by all clients so quota and etag cache outlive a single client
+	transport = newRateLimitTransport(http.DefaultTransport)
+)
+
+// RateLimit quota for a github api resource as last reported by github
+type RateLimit struct {
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Low remaining quota is below LowRateLimitPercent of limit
+func (r *RateLimit) Low() bool {
+	return r.Limit > 0 && r.Remaining*100 < r.Limit*LowRateLimitPercent
+}
+
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// rateLimitTransport tracks quota from response headers, waits for quota reset or Retry-After,
+// retries 5xx and secondary rate limit errors and revalidates GET responses using etags.
+// Quota is tracked per resource for the process, 304 responses do not count against quota
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	lock       sync.Mutex
+	rateLimits map[string]RateLimit
+	cache      map[string]*cachedResponse
+	cacheOrder []string
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:       base,
+		rateLimits: make(map[string]RateLimit),
+		cache:      make(map[string]*cachedResponse),
+	}
+}
+
+func (t *rateLimitTransport) rateLimit(resource string) *RateLimit {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	rateLimit, ok := t.rateLimits[resource]
+	if !ok {
+		return nil
+	}
+	return &rateLimit
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	logger := log.Get().With().Str("Method", req.Method).Str("Url", req.URL.Redacted()).Logger()
+
+	if err := t.waitForQuota(req.Context()); err != nil {
+		return nil, err
+	}
+
+	cacheKey := ""
+	var cached *cachedResponse
+	if req.Method == http.MethodGet {
+		cacheKey = etagCacheKey(req)
+		cached = t.cached(cacheKey)
+	}
+
+	backoff := RetryBackoff
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := cloneRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			attemptReq.Header.Set("If-None-Match", cached.etag)
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		t.updateRateLimit(resp)
+
+		wait, retry := retryAfter(req.Method, resp, backoff)
+		if !retry || attempt > MaxRetries {
+			if cached != nil && resp.StatusCode == http.StatusNotModified {
+				return cached.response(req, resp), nil
+			}
+			if cacheKey != "" {
+				return t.store(cacheKey, resp)
+			}
+			return resp, nil
+		}
+
+		if wait > MaxRateLimitWait {
+			return resp, nil
+		}
+
+		logger.Warn().Int("StatusCode", resp.StatusCode).Int("Attempt", attempt).Dur("Wait", wait).Msg("github request failed, retrying")
+		drainBody(resp)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// waitForQuota blocks until core quota resets when exhausted, fails when reset is beyond MaxRateLimitWait
+func (t *rateLimitTransport) waitForQuota(ctx context.Context) error {
+	rateLimit := t.rateLimit(RATE_LIMIT_CORE)
+	if rateLimit == nil || rateLimit.Remaining > 0 {
+		return nil
+	}
+
+	wait := time.Until(rateLimit.Reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > MaxRateLimitWait {
+		return fmt.Errorf("%w, quota resets at %s", ErrRateLimited, rateLimit.Reset.Format(time.RFC3339))
+	}
+
+	log.Get().Warn().Dur("Wait", wait).Msg("github quota exhausted, waiting for reset")
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+	}
+	return nil
+}
+
+// retryAfter wait before retrying response, rate limited requests are retried,
+// 5xx only for GET since a dispatch may have been accepted before the error
+func retryAfter(method string, resp *http.Response, backoff time.Duration) (time.Duration, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Until(time.Unix(reset, 0)), true
+		}
+		if isAbuseError(resp) {
+			return jitter(backoff), true
+		}
+		return 0, false
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError && method == http.MethodGet {
+		return jitter(backoff), true
+	}
+
+	return 0, false
+}
+
+func (t *rateLimitTransport) setRateLimit(rateLimit RateLimit) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rateLimits[rateLimit.Resource] = rateLimit
+}
+
+func (t *rateLimitTransport) updateRateLimit(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	used, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = RATE_LIMIT_CORE
+	}
+
+	t.setRateLimit(RateLimit{Resource: resource, Limit: limit, Remaining: remaining, Used: used, Reset: time.Unix(reset, 0)})
+}
+
+func (t *rateLimitTransport) cached(key string) *cachedResponse {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.cache[key]
+}
+
+// store caches successful responses with etag, body is read so it can be replayed
+func (t *rateLimitTransport) store(key string, resp *http.Response) (*http.Response, error) {
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.cache[key]; !ok {
+		t.cacheOrder = append(t.cacheOrder, key)
+	}
+	t.cache[key] = &cachedResponse{etag: etag, header: resp.Header.Clone(), body: body}
+	for len(t.cacheOrder) > etagCacheSize {
+		delete(t.cache, t.cacheOrder[0])
+		t.cacheOrder = t.cacheOrder[1:]
+	}
+
+	return resp, nil
+}
+
+// response cached response replayed for 304, quota headers are taken from 304
+func (c *cachedResponse) response(req *http.Request, notModified *http.Response) *http.Response {
+	drainBody(notModified)
+	header := c.header.Clone()
+	for key, values := range notModified.Header {
+		if strings.HasPrefix(key, "X-Ratelimit-") {
+			header[key] = values
+		}
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// etagCacheKey responses are cached per credential so users never see each others responses
+func etagCacheKey(req *http.Request) string {
+	credential := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return req.URL.String() + "#" + hex.EncodeToString(credential[:])
+}
+
+// cloneRequest request for attempt, body is rewound for retries
+func cloneRequest(req *http.Request, attempt int) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("cannot retry %s %s, request body cannot be replayed", req.Method, req.URL.Redacted())
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// isAbuseError secondary rate limit responses do not always carry Retry-After
+func isAbuseError(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+}
+
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+func drainBody(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
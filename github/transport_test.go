@@ -0,0 +1,151 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTransportTest(t *testing.T, handler http.HandlerFunc) *Github {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	profile, err := users.NewProfile("ghes", server.URL+"/api/v3", "", "", "")
+	require.NoError(t, err)
+	users.UseProfile(profile)
+
+	backoff := RetryBackoff
+	RetryBackoff = time.Millisecond
+	t.Cleanup(func() {
+		RetryBackoff = backoff
+		users.SetProfile(users.DefaultProfile)
+		transport.lock.Lock()
+		transport.rateLimits = make(map[string]RateLimit)
+		transport.lock.Unlock()
+	})
+
+	return &Github{Context: context.WithValue(context.Background(), AccessTokenCtx, "ghes_token")}
+}
+
+func setQuota(w http.ResponseWriter, remaining int, reset time.Time) {
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+	w.Header().Set("X-RateLimit-Used", fmt.Sprint(5000-remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+	w.Header().Set("X-RateLimit-Resource", "core")
+}
+
+func TestTransportETag(t *testing.T) {
+	var modified, notModified atomic.Int32
+	client := setupTransportTest(t, func(w http.ResponseWriter, r *http.Request) {
+		setQuota(w, 4000, time.Now().Add(time.Hour))
+		if r.Header.Get("If-None-Match") == `"workflows1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		modified.Add(1)
+		w.Header().Set("ETag", `"workflows1"`)
+		_, _ = w.Write([]byte(`{"total_count":1,"workflows":[{"id":1,"name":"deploy"}]}`))
+	})
+
+	for range 3 {
+		workflows, err := client.ListWorkflows("org1", "repo1")
+		require.NoError(t, err)
+		require.Len(t, workflows, 1)
+		assert.Equal(t, "deploy", workflows[0].Name)
+	}
+	assert.Equal(t, int32(1), modified.Load())
+	assert.Equal(t, int32(2), notModified.Load())
+
+	rateLimit := client.CurrentRateLimit()
+	require.NotNil(t, rateLimit)
+	assert.Equal(t, 4000, rateLimit.Remaining)
+	assert.False(t, rateLimit.Low())
+
+	// responses are not shared across credentials
+	other := &Github{Context: context.WithValue(context.Background(), AccessTokenCtx, "other_token")}
+	_, err := other.ListWorkflows("org1", "repo1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), modified.Load())
+}
+
+func TestTransportRetry(t *testing.T) {
+	var attempts atomic.Int32
+	client := setupTransportTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit"}`))
+		case 3:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"You have triggered an abuse detection mechanism"}`))
+		default:
+			_, _ = w.Write([]byte(`[{"login":"org1","id":1}]`))
+		}
+	})
+
+	orgs, err := client.ListOrgsForUser()
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, int32(4), attempts.Load())
+
+	// retries are bounded
+	attempts.Store(0)
+	client = setupTransportTest(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	_, err = client.ListOrgsForUser()
+	require.Error(t, err)
+	assert.Equal(t, int32(MaxRetries+1), attempts.Load())
+
+	// dispatch may have been accepted, it is not retried on 5xx
+	attempts.Store(0)
+	err = client.CreateWorkflowDispatch("org1", "repo1", 1, "main", nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestTransportQuota(t *testing.T) {
+	var requests atomic.Int32
+	client := setupTransportTest(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/api/v3/rate_limit" {
+			reset := time.Now().Add(time.Hour).Unix()
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"resources":{"core":{"limit":5000,"remaining":100,"used":4900,"reset":%d},"search":{"limit":30,"remaining":30,"used":0,"reset":%d}}}`, reset, reset)))
+			return
+		}
+		setQuota(w, 0, time.Now().Add(time.Hour))
+		_, _ = w.Write([]byte(`[]`))
+	})
+
+	rateLimits, err := client.ListRateLimits()
+	require.NoError(t, err)
+	require.Len(t, rateLimits, 2)
+	assert.Equal(t, RATE_LIMIT_CORE, rateLimits[0].Resource)
+	assert.Equal(t, "search", rateLimits[1].Resource)
+	assert.True(t, client.CurrentRateLimit().Low())
+	assert.Equal(t, 100, client.CurrentRateLimit().Remaining)
+
+	// last request exhausts quota, reset is beyond wait limit so requests fail without calling github
+	_, err = client.ListOrgsForUser()
+	require.NoError(t, err)
+	assert.Equal(t, 0, client.CurrentRateLimit().Remaining)
+
+	_, err = client.ListOrgsForUser()
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(2), requests.Load())
+}
@@ -0,0 +1,112 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/v75/github"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	WORKFLOW_ACTIVE  = "active"
+	WORKFLOW_DELETED = "deleted"
+
+	INPUT_STRING  = "string"
+	INPUT_BOOLEAN = "boolean"
+	INPUT_NUMBER  = "number"
+	INPUT_CHOICE  = "choice"
+)
+
+// WorkflowInput workflow_dispatch input as defined in workflow, type defaults to string
+type WorkflowInput struct {
+	Name        string
+	Description string
+	Required    bool
+	Type        string
+	Default     string
+	Options     []string
+}
+
+// IsNotFound github responded 404, eg: workflow or its file was deleted
+func IsNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// ListWorkflowInputs workflow_dispatch inputs of workflow at path on default branch sorted by name,
+// empty when workflow cannot be dispatched
+func (g *Github) ListWorkflowInputs(org, repo, path string) ([]WorkflowInput, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &github.RepositoryContentGetOptions{}
+	fileContent, _, _, err := client.Repositories.GetContents(context.Background(), org, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseWorkflowInputs(content)
+}
+
+func parseWorkflowInputs(content string) ([]WorkflowInput, error) {
+	definition := struct {
+		On yaml.Node `yaml:"on"`
+	}{}
+	if err := yaml.Unmarshal([]byte(content), &definition); err != nil {
+		return nil, err
+	}
+
+	// on as event name or list of events has no inputs
+	if definition.On.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	on := struct {
+		WorkflowDispatch struct {
+			Inputs map[string]struct {
+				Description string      `yaml:"description"`
+				Required    bool        `yaml:"required"`
+				Type        string      `yaml:"type"`
+				Default     interface{} `yaml:"default"`
+				Options     []string    `yaml:"options"`
+			} `yaml:"inputs"`
+		} `yaml:"workflow_dispatch"`
+	}{}
+	if err := definition.On.Decode(&on); err != nil {
+		return nil, fmt.Errorf("invalid workflow_dispatch inputs: %w", err)
+	}
+
+	var inputs []WorkflowInput
+	for name, input := range on.WorkflowDispatch.Inputs {
+		workflowInput := WorkflowInput{
+			Name:        name,
+			Description: input.Description,
+			Required:    input.Required,
+			Type:        input.Type,
+			Options:     input.Options,
+		}
+		if workflowInput.Type == "" {
+			workflowInput.Type = INPUT_STRING
+		}
+		if input.Default != nil {
+			workflowInput.Default = fmt.Sprint(input.Default)
+		}
+		inputs = append(inputs, workflowInput)
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Name < inputs[j].Name
+	})
+
+	return inputs, nil
+}
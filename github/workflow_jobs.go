@@ -0,0 +1,214 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// LogDownloadTimeout bounds downloading log archives and job logs github redirects to
+var LogDownloadTimeout = 2 * time.Minute
+
+type WorkflowJob struct {
+	Name, Status, Conclusion, Url string
+	Id                            int64
+	StartedAt, CompletedAt        time.Time
+	Steps                         []WorkflowStep
+}
+
+type WorkflowStep struct {
+	Name, Status, Conclusion string
+	Number                   int64
+	StartedAt, CompletedAt   time.Time
+}
+
+// WorkflowLog log file from workflow run log archive, step is empty for log of whole job
+type WorkflowLog struct {
+	Job, Step string
+	Number    int64
+	Content   string
+}
+
+// ListWorkflowJobs jobs of latest attempt of workflow run with their steps
+func (g *Github) ListWorkflowJobs(org, repo string, runID int64) ([]WorkflowJob, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobItems []WorkflowJob
+
+	err = paginate(func(listOpt github.ListOptions) (*github.Response, bool, error) {
+		opt := &github.ListWorkflowJobsOptions{Filter: "latest", ListOptions: listOpt}
+		jobs, resp, err := client.Actions.ListWorkflowJobs(context.Background(), org, repo, runID, opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, job := range jobs.Jobs {
+			jobItem := WorkflowJob{
+				Name:        job.GetName(),
+				Status:      job.GetStatus(),
+				Conclusion:  job.GetConclusion(),
+				Url:         job.GetHTMLURL(),
+				Id:          job.GetID(),
+				StartedAt:   job.GetStartedAt().Time,
+				CompletedAt: job.GetCompletedAt().Time,
+			}
+			for _, step := range job.Steps {
+				jobItem.Steps = append(jobItem.Steps, WorkflowStep{
+					Name:        step.GetName(),
+					Status:      step.GetStatus(),
+					Conclusion:  step.GetConclusion(),
+					Number:      step.GetNumber(),
+					StartedAt:   step.GetStartedAt().Time,
+					CompletedAt: step.GetCompletedAt().Time,
+				})
+			}
+			jobItems = append(jobItems, jobItem)
+		}
+		return resp, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobItems, nil
+}
+
+// GetWorkflowRunLogs downloads and decompresses log archive of completed workflow run,
+// logs are sorted by job and step number
+func (g *Github) GetWorkflowRunLogs(org, repo string, runID int64) ([]WorkflowLog, error) {
+	client, err := g.New()
+	if err != nil {
+		return nil, err
+	}
+
+	archiveUrl, _, err := client.Actions.GetWorkflowRunLogs(context.Background(), org, repo, runID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := download(archiveUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return readLogArchive(archive)
+}
+
+// GetWorkflowJobLogs downloads log of job, github only has logs of completed jobs
+func (g *Github) GetWorkflowJobLogs(org, repo string, jobID int64) (string, error) {
+	client, err := g.New()
+	if err != nil {
+		return "", err
+	}
+
+	logUrl, _, err := client.Actions.GetWorkflowJobLogs(context.Background(), org, repo, jobID, 1)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := download(logUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(string(content), "\ufeff"), nil
+}
+
+// download short lived url github redirects to, it is signed and must not be sent credentials
+func download(downloadUrl *url.URL) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), LogDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download logs, %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// readLogArchive reads job/<number>_<step>.txt step logs and <number>_<job>.txt job logs
+func readLogArchive(archive []byte) ([]WorkflowLog, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []WorkflowLog
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || path.Ext(file.Name) != ".txt" {
+			continue
+		}
+
+		dir, name := path.Split(strings.TrimSuffix(file.Name, ".txt"))
+		prefix, title, ok := strings.Cut(name, "_")
+		number, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil {
+			title = name
+			number = 0
+		}
+
+		workflowLog := WorkflowLog{Job: title}
+		if dir != "" {
+			workflowLog = WorkflowLog{Job: strings.TrimSuffix(dir, "/"), Step: title, Number: number}
+		}
+
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		workflowLog.Content = strings.TrimPrefix(content, "\ufeff")
+		logs = append(logs, workflowLog)
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].Job != logs[j].Job {
+			return logs[i].Job < logs[j].Job
+		}
+		return logs[i].Number < logs[j].Number
+	})
+
+	return logs, nil
+}
+
+func readZipFile(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
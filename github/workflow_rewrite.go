@@ -0,0 +1,367 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const pippyRunIdExpr = "${{inputs.pippy_run_id}}"
+
+// lineEdit replaces lines start to end (1 based, inclusive), end = start-1 inserts before start
+type lineEdit struct {
+	start, end int
+	lines      []string
+}
+
+// workflowRewrite edits workflow source using positions from its yaml.Node tree,
+// lines outside of edits are kept byte for byte
+type workflowRewrite struct {
+	lines []string
+	root  *yaml.Node
+	step  int
+	edits []lineEdit
+}
+
+// rewriteWorkflow adds pippy_run_id to on.workflow_dispatch.inputs and to run-name of workflow,
+// comments, key order and expressions in the rest of workflow are left as is
+func rewriteWorkflow(content string) (string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return "", err
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) <= 0 || document.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("workflow is not a yaml mapping")
+	}
+
+	w := &workflowRewrite{
+		lines: strings.Split(content, "\n"),
+		root:  document.Content[0],
+		step:  indentStep(document.Content[0]),
+	}
+	if w.step <= 0 {
+		w.step = 2
+	}
+	if err := w.runName(); err != nil {
+		return "", err
+	}
+	if err := w.on(); err != nil {
+		return "", err
+	}
+
+	return w.apply(), nil
+}
+
+func (w *workflowRewrite) runName() error {
+	_, name := mappingValue(w.root, "name")
+	key, value := mappingValue(w.root, "run-name")
+
+	if key == nil {
+		runName := pippyRunIdExpr
+		if name != nil && name.Value != "" {
+			runName = name.Value + " - " + pippyRunIdExpr
+		}
+		encoded, err := encodeScalar(runName, 0)
+		if err != nil {
+			return err
+		}
+
+		// run-name goes right after name when present, otherwise it is the first key
+		line := strings.Repeat(" ", w.root.Content[0].Column-1) + "run-name: " + encoded
+		if nameKey, _ := mappingValue(w.root, "name"); nameKey != nil {
+			end := w.entryEnd(nameKey)
+			w.edits = append(w.edits, lineEdit{start: end + 1, end: end, lines: []string{line}})
+		} else {
+			first := w.root.Content[0].Line
+			w.edits = append(w.edits, lineEdit{start: first, end: first - 1, lines: []string{line}})
+		}
+		return nil
+	}
+
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("run-name at line %d is not a string", value.Line)
+	}
+	if strings.Contains(value.Value, "inputs.pippy_run_id") {
+		return nil
+	}
+
+	runName := pippyRunIdExpr
+	if value.Value != "" {
+		runName = value.Value + " - " + pippyRunIdExpr
+	}
+	style := value.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+	encoded, err := encodeScalar(runName, style)
+	if err != nil {
+		return err
+	}
+
+	line := w.keyPrefix(key) + " " + encoded + lineComment(key, value)
+	w.edits = append(w.edits, lineEdit{start: key.Line, end: w.entryEnd(key), lines: []string{line}})
+	return nil
+}
+
+func (w *workflowRewrite) on() error {
+	key, value := mappingValue(w.root, "on")
+
+	if key == nil {
+		// on goes before jobs, otherwise it is appended
+		indent := w.root.Content[0].Column - 1
+		block, err := w.block(indent, "on", "workflow_dispatch", "inputs")
+		if err != nil {
+			return err
+		}
+		if jobsKey, _ := mappingValue(w.root, "jobs"); jobsKey != nil {
+			w.edits = append(w.edits, lineEdit{start: jobsKey.Line, end: jobsKey.Line - 1, lines: block})
+		} else {
+			end := w.entryEnd(w.root.Content[len(w.root.Content)-2])
+			w.edits = append(w.edits, lineEdit{start: end + 1, end: end, lines: block})
+		}
+		return nil
+	}
+
+	if !isBlockMapping(value) {
+		return w.replaceOn(key, value)
+	}
+
+	dispatchKey, dispatch := mappingValue(value, "workflow_dispatch")
+	if dispatchKey == nil {
+		return w.insertInto(value, "workflow_dispatch", "inputs")
+	}
+	if !isBlockMapping(dispatch) {
+		return w.replaceValue(dispatchKey, dispatch, "inputs")
+	}
+
+	inputsKey, inputs := mappingValue(dispatch, "inputs")
+	if inputsKey == nil {
+		return w.insertInto(dispatch, "inputs")
+	}
+	if !isBlockMapping(inputs) {
+		return w.replaceValue(inputsKey, inputs)
+	}
+
+	if pippyRunIdKey, _ := mappingValue(inputs, "pippy_run_id"); pippyRunIdKey != nil {
+		return nil
+	}
+	return w.insertInto(inputs)
+}
+
+// replaceOn rewrites on given as event name, list of events or flow mapping into a block mapping with workflow_dispatch
+func (w *workflowRewrite) replaceOn(key, value *yaml.Node) error {
+	events := &yaml.Node{Kind: yaml.MappingNode}
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Tag != "!!null" && value.Value != "" {
+			events.Content = append(events.Content, eventNode(value.Value)...)
+		}
+	case yaml.SequenceNode:
+		for _, event := range value.Content {
+			if event.Kind != yaml.ScalarNode {
+				return fmt.Errorf("on event at line %d is not a string", event.Line)
+			}
+			events.Content = append(events.Content, eventNode(event.Value)...)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(value.Content); i += 2 {
+			if value.Content[i-1].Value == "workflow_dispatch" {
+				if !isEmpty(value.Content[i]) {
+					return fmt.Errorf("on.workflow_dispatch at line %d must be a block mapping", value.Content[i].Line)
+				}
+				continue
+			}
+			events.Content = append(events.Content, value.Content[i-1], value.Content[i])
+		}
+	default:
+		return fmt.Errorf("on at line %d is not supported", value.Line)
+	}
+	events.Content = append(events.Content, dispatchNode("workflow_dispatch", "inputs")...)
+
+	indent := key.Column - 1 + w.step
+	block, err := w.encode(indent, events)
+	if err != nil {
+		return err
+	}
+
+	lines := append([]string{w.keyPrefix(key) + lineComment(key, value)}, block...)
+	w.edits = append(w.edits, lineEdit{start: key.Line, end: w.entryEnd(key), lines: lines})
+	return nil
+}
+
+// replaceValue replaces empty value of key with nested keys ending in pippy_run_id
+func (w *workflowRewrite) replaceValue(key, value *yaml.Node, keys ...string) error {
+	if !isEmpty(value) {
+		return fmt.Errorf("%s at line %d must be a mapping", key.Value, value.Line)
+	}
+
+	block, err := w.block(key.Column-1+w.step, keys...)
+	if err != nil {
+		return err
+	}
+
+	// empty value is on key line, comments below it are kept
+	lines := append([]string{w.keyPrefix(key) + lineComment(key, value)}, block...)
+	w.edits = append(w.edits, lineEdit{start: key.Line, end: key.Line, lines: lines})
+	return nil
+}
+
+// insertInto appends nested keys ending in pippy_run_id after last entry of block mapping
+func (w *workflowRewrite) insertInto(mapping *yaml.Node, keys ...string) error {
+	block, err := w.block(mapping.Content[0].Column-1, keys...)
+	if err != nil {
+		return err
+	}
+
+	end := w.entryEnd(mapping.Content[len(mapping.Content)-2])
+	w.edits = append(w.edits, lineEdit{start: end + 1, end: end, lines: block})
+	return nil
+}
+
+// block nested keys ending in pippy_run_id input at indent
+func (w *workflowRewrite) block(indent int, keys ...string) ([]string, error) {
+	return w.encode(indent, &yaml.Node{Kind: yaml.MappingNode, Content: dispatchNode(keys...)})
+}
+
+// encode node as block yaml using indentation of workflow
+func (w *workflowRewrite) encode(indent int, node *yaml.Node) ([]string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(w.step)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.Repeat(" ", indent) + lines[i]
+	}
+	return lines, nil
+}
+
+// keyPrefix line of key up to and including colon
+func (w *workflowRewrite) keyPrefix(key *yaml.Node) string {
+	line := w.lines[key.Line-1]
+	start := key.Column - 1 + len(key.Value)
+	if colon := strings.Index(line[start:], ":"); colon >= 0 {
+		return line[:start+colon+1]
+	}
+	return line[:start] + ":"
+}
+
+// entryEnd last line of mapping entry for key, deeper indented lines and
+// sequence items at key indentation belong to it, trailing blank lines do not
+func (w *workflowRewrite) entryEnd(key *yaml.Node) int {
+	indent := key.Column - 1
+	end := key.Line
+	for i := key.Line; i < len(w.lines); i++ {
+		line := w.lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		lineIndent := len(line) - len(trimmed)
+		if lineIndent > indent || (lineIndent == indent && (trimmed == "-" || strings.HasPrefix(trimmed, "- "))) {
+			end = i + 1
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// apply edits from bottom so line numbers of earlier edits stay valid,
+// insertions at the same line keep the order they were added in
+func (w *workflowRewrite) apply() string {
+	slices.Reverse(w.edits)
+	slices.SortStableFunc(w.edits, func(a, b lineEdit) int {
+		return b.start - a.start
+	})
+
+	lines := w.lines
+	for _, edit := range w.edits {
+		lines = slices.Concat(lines[:edit.start-1], edit.lines, lines[edit.end:])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentStep indentation used by workflow, first nested block mapping decides it, 0 when there is none
+func indentStep(node *yaml.Node) int {
+	if node.Kind != yaml.MappingNode {
+		return 0
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		key, value := node.Content[i-1], node.Content[i]
+		if isBlockMapping(value) && value.Content[0].Column > key.Column {
+			return value.Content[0].Column - key.Column
+		}
+		if step := indentStep(value); step > 0 {
+			return step
+		}
+	}
+	return 0
+}
+
+func mappingValue(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 1; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i-1].Value == key {
+			return mapping.Content[i-1], mapping.Content[i]
+		}
+	}
+	return nil, nil
+}
+
+func isBlockMapping(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle == 0 && len(node.Content) > 0
+}
+
+// isEmpty null or {} value
+func isEmpty(node *yaml.Node) bool {
+	return (node.Kind == yaml.ScalarNode && node.Tag == "!!null") || (node.Kind == yaml.MappingNode && len(node.Content) <= 0)
+}
+
+func lineComment(key, value *yaml.Node) string {
+	if value.LineComment != "" {
+		return " " + value.LineComment
+	}
+	if key.LineComment != "" {
+		return " " + key.LineComment
+	}
+	return ""
+}
+
+func encodeScalar(value string, style yaml.Style) (string, error) {
+	encoded, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Style: style, Value: value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(encoded), "\n"), nil
+}
+
+func eventNode(event string) []*yaml.Node {
+	return []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: event},
+		{Kind: yaml.ScalarNode, Tag: "!!null"},
+	}
+}
+
+// dispatchNode key value pair for keys nested in order ending in pippy_run_id string input
+func dispatchNode(keys ...string) []*yaml.Node {
+	content := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "pippy_run_id"},
+		{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "type"},
+			{Kind: yaml.ScalarNode, Value: "string"},
+		}},
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		content = []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: keys[i]},
+			{Kind: yaml.MappingNode, Content: content},
+		}
+	}
+	return content
+}
@@ -0,0 +1,92 @@
+package github
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestRewriteWorkflow(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "workflows", "*.yml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		if strings.HasSuffix(file, ".golden.yml") {
+			continue
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+
+			rewritten, err := rewriteWorkflow(string(content))
+			require.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".yml") + ".golden.yml"
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(rewritten), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), rewritten)
+
+			// rewritten workflow is pippy ready and is not rewritten again
+			definition := struct {
+				RunName string `yaml:"run-name"`
+				On      struct {
+					WorkflowDispatch struct {
+						Inputs map[string]map[string]interface{} `yaml:"inputs"`
+					} `yaml:"workflow_dispatch"`
+				} `yaml:"on"`
+			}{}
+			require.NoError(t, yaml.Unmarshal([]byte(rewritten), &definition))
+			assert.Contains(t, definition.RunName, "inputs.pippy_run_id")
+			assert.Equal(t, "string", definition.On.WorkflowDispatch.Inputs["pippy_run_id"]["type"])
+
+			again, err := rewriteWorkflow(rewritten)
+			require.NoError(t, err)
+			assert.Equal(t, rewritten, again)
+		})
+	}
+}
+
+func TestRewriteWorkflowKeepsLines(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "workflows", "existing_inputs.yml"))
+	require.NoError(t, err)
+
+	rewritten, err := rewriteWorkflow(string(content))
+	require.NoError(t, err)
+
+	// every original line except run-name is kept as is and in order
+	lines := strings.Split(rewritten, "\n")
+	next := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "run-name:") {
+			continue
+		}
+		for next < len(lines) && lines[next] != line {
+			next++
+		}
+		require.Less(t, next, len(lines), "line %q not found in order", line)
+		next++
+	}
+}
+
+func TestRewriteWorkflowErrors(t *testing.T) {
+	_, err := rewriteWorkflow("- not\n- a mapping\n")
+	require.Error(t, err)
+
+	_, err = rewriteWorkflow("name: bad\non:\n  workflow_dispatch: manual\n")
+	require.Error(t, err)
+
+	_, err = rewriteWorkflow("name: bad\non: [push\n")
+	require.Error(t, err)
+}
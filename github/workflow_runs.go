@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v75/github"
@@ -17,35 +18,76 @@ func (i WorkflowRun) Title() string       { return i.Name }
 func (i WorkflowRun) Description() string { return i.Url }
 func (i WorkflowRun) FilterValue() string { return i.Name }
 
+// NewWorkflowRun converts github api workflow run, also used for workflow_run webhook payloads
+func NewWorkflowRun(workflowRun *github.WorkflowRun) WorkflowRun {
+	return WorkflowRun{
+		Name:         workflowRun.GetDisplayTitle(),
+		Url:          workflowRun.GetHTMLURL(),
+		Id:           workflowRun.GetID(),
+		Status:       workflowRun.GetStatus(),
+		WorkflowID:   workflowRun.GetWorkflowID(),
+		Conclusion:   workflowRun.GetConclusion(),
+		RunStartedAt: workflowRun.GetRunStartedAt().Time,
+		UpdatedAt:    workflowRun.GetUpdatedAt().Time,
+	}
+}
+
 func (g *Github) ListWorkflowRuns(org, repo string, workflowID int64, created string) ([]WorkflowRun, error) {
-	client, err := g.New()
+	var workflowItems []WorkflowRun
+
+	err := g.listWorkflowRuns(org, repo, workflowID, created, func(workflowRun WorkflowRun) bool {
+		workflowItems = append(workflowItems, workflowRun)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	opt := &github.ListWorkflowRunsOptions{
-		Event:   "workflow_dispatch",
-		Created: created,
-	}
-	workflowRuns, _, err := client.Actions.ListWorkflowRunsByID(context.Background(), org, repo, workflowID, opt)
+	return workflowItems, nil
+}
+
+// FindWorkflowRun first workflow run with name containing match, pages are listed until run is found
+// returns nil when there is no match within page limit
+func (g *Github) FindWorkflowRun(org, repo string, workflowID int64, created, match string) (*WorkflowRun, error) {
+	var found *WorkflowRun
+
+	err := g.listWorkflowRuns(org, repo, workflowID, created, func(workflowRun WorkflowRun) bool {
+		if strings.Contains(workflowRun.Name, match) {
+			found = &workflowRun
+			return false
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var workflowItems []WorkflowRun
+	return found, nil
+}
 
-	for i := 0; i < len(workflowRuns.WorkflowRuns); i++ {
-		workflowItems = append(workflowItems, WorkflowRun{
-			Name:         workflowRuns.WorkflowRuns[i].GetDisplayTitle(),
-			Url:          workflowRuns.WorkflowRuns[i].GetHTMLURL(),
-			Id:           workflowRuns.WorkflowRuns[i].GetID(),
-			Status:       workflowRuns.WorkflowRuns[i].GetStatus(),
-			WorkflowID:   workflowRuns.WorkflowRuns[i].GetWorkflowID(),
-			Conclusion:   workflowRuns.WorkflowRuns[i].GetConclusion(),
-			RunStartedAt: workflowRuns.WorkflowRuns[i].GetRunStartedAt().Time,
-			UpdatedAt:    workflowRuns.WorkflowRuns[i].GetUpdatedAt().Time,
-		})
+// listWorkflowRuns calls next for each dispatched workflow run, stops when next returns false
+func (g *Github) listWorkflowRuns(org, repo string, workflowID int64, created string, next func(WorkflowRun) bool) error {
+	client, err := g.New()
+	if err != nil {
+		return err
 	}
 
-	return workflowItems, nil
+	return paginate(func(listOpt github.ListOptions) (*github.Response, bool, error) {
+		opt := &github.ListWorkflowRunsOptions{
+			Event:       "workflow_dispatch",
+			Created:     created,
+			ListOptions: listOpt,
+		}
+		workflowRuns, resp, err := client.Actions.ListWorkflowRunsByID(context.Background(), org, repo, workflowID, opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for i := 0; i < len(workflowRuns.WorkflowRuns); i++ {
+			if !next(NewWorkflowRun(workflowRuns.WorkflowRuns[i])) {
+				return resp, false, nil
+			}
+		}
+		return resp, true, nil
+	})
 }
@@ -45,22 +45,27 @@ func (g *Github) ListWorkflows(org, repo string) ([]Workflow, error) {
 		return nil, err
 	}
 
-	opt := &github.ListOptions{}
-	workflows, _, err := client.Actions.ListWorkflows(context.Background(), org, repo, opt)
-	if err != nil {
-		return nil, err
-	}
-
 	var workflowItems []Workflow
 
-	for i := 0; i < len(workflows.Workflows); i++ {
-		workflowItems = append(workflowItems, Workflow{
-			Name:  workflows.Workflows[i].GetName(),
-			Url:   workflows.Workflows[i].GetHTMLURL(),
-			Id:    workflows.Workflows[i].GetID(),
-			State: workflows.Workflows[i].GetState(),
-			Path:  workflows.Workflows[i].GetPath(),
-		})
+	err = paginate(func(opt github.ListOptions) (*github.Response, bool, error) {
+		workflows, resp, err := client.Actions.ListWorkflows(context.Background(), org, repo, &opt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for i := 0; i < len(workflows.Workflows); i++ {
+			workflowItems = append(workflowItems, Workflow{
+				Name:  workflows.Workflows[i].GetName(),
+				Url:   workflows.Workflows[i].GetHTMLURL(),
+				Id:    workflows.Workflows[i].GetID(),
+				State: workflows.Workflows[i].GetState(),
+				Path:  workflows.Workflows[i].GetPath(),
+			})
+		}
+		return resp, true, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return workflowItems, nil
@@ -141,8 +146,11 @@ func (g *Github) ValidateWorkflow(org, repo, path string) ([]string, map[string]
 				requiredChanges = append(requiredChanges, string(changes))
 			}
 		} else {
-			// this is an array
-			dispatches := onDispatches.([]interface{})
+			// this is an array or a single event
+			dispatches, ok := onDispatches.([]interface{})
+			if !ok {
+				dispatches = []interface{}{onDispatches}
+			}
 			newDispatch := make(map[string]interface{})
 			for _, dispatch := range dispatches {
 				newDispatch[dispatch.(string)] = map[string]interface{}{}
@@ -194,73 +202,10 @@ func (g *Github) ValidateWorkflowFull(org, repo, path string) (string, string, e
 		return "", "", err
 	}
 
-	workflowsDef := make(map[string]interface{})
-	err = yaml.Unmarshal([]byte(oldFile), workflowsDef)
-	if err != nil {
-		return oldFile, "", err
-	}
-
-	var workflowName string
-	var workflowRunName string
-	//workflowDispatchInputs := make(map[string]string)
-
-	if value, ok := workflowsDef["name"]; ok {
-		workflowName = value.(string)
-	}
-
-	if value, ok := workflowsDef["run-name"]; ok {
-		workflowRunName = value.(string)
-	}
-
-	workflowInputs := make(map[string]string)
-	if onDispatches, ok := workflowsDef["on"]; ok {
-		if dispatches, ok := onDispatches.(map[string]interface{}); ok {
-			if workflowDispatch, ok := dispatches["workflow_dispatch"]; ok {
-				if workflowDispatchInputs, ok := workflowDispatch.(map[string]interface{}); ok {
-					if inputs, ok := workflowDispatchInputs["inputs"]; ok {
-						newInputs := make(map[string]interface{})
-						for key, value := range inputs.(map[string]interface{}) {
-							inputType := value.(map[string]interface{})["type"]
-							newInputs[key] = map[string]interface{}{"type": inputType.(string)}
-							workflowInputs[key] = inputType.(string)
-						}
-						if _, ok := newInputs["pippy_run_id"]; !ok {
-							newInputs["pippy_run_id"] = map[string]string{"type": "string"}
-							workflowDispatchInputs["inputs"] = map[string]interface{}{"inputs": newInputs}
-						}
-					}
-				} else {
-					dispatches["workflow_dispatch"] = map[string]interface{}{"inputs": map[string]interface{}{"pippy_run_id": map[string]interface{}{"type": "string"}}}
-				}
-			} else {
-				dispatches["workflow_dispatch"] = map[string]interface{}{"inputs": map[string]interface{}{"pippy_run_id": map[string]interface{}{"type": "string"}}}
-			}
-		} else {
-			// this is an array
-			dispatches := onDispatches.([]interface{})
-			newDispatch := make(map[string]interface{})
-			for _, dispatch := range dispatches {
-				newDispatch[dispatch.(string)] = map[string]interface{}{}
-			}
-			newDispatch["workflow_dispatch"] = map[string]interface{}{"inputs": map[string]interface{}{"pippy_run_id": map[string]interface{}{"type": "string"}}}
-			workflowsDef["on"] = newDispatch
-		}
-	} else {
-		newDispatch := map[string]interface{}{"workflow_dispatch": map[string]interface{}{"inputs": map[string]interface{}{"pippy_run_id": map[string]interface{}{"type": "string"}}}}
-		workflowsDef["on"] = newDispatch
-	}
-
-	if !strings.Contains(workflowRunName, "inputs.pippy_run_id") {
-		if workflowRunName == "" {
-			workflowRunName = workflowName
-		}
-		workflowsDef["run-name"] = fmt.Sprintf("%s - ${{inputs.pippy_run_id}}", workflowRunName)
-	}
-
-	newFile, err := yaml.Marshal(workflowsDef)
+	newFile, err := rewriteWorkflow(oldFile)
 	if err != nil {
 		return oldFile, "", err
 	}
 
-	return oldFile, string(newFile), nil
+	return oldFile, newFile, nil
 }
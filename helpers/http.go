@@ -63,3 +63,35 @@ func HttpGet(url string, headers map[string]string) (string, error) {
 	}
 	return string(body), err
 }
+
+func HttpPostJSON(url string, body []byte, headers map[string]string) (string, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Close = true
+	respU, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := respU.Body.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	respBody, err := io.ReadAll(respU.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if respU.StatusCode < 200 || respU.StatusCode > 299 {
+		return "", fmt.Errorf("post returned %d, expected success with 2xx, error: %s", respU.StatusCode, respU.Status)
+	}
+
+	return string(respBody), err
+}
@@ -0,0 +1,176 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OutputTable = "table"
+	OutputWide  = "wide"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+var Outputs = []string{OutputTable, OutputWide, OutputJSON, OutputYAML}
+
+// OutputFlag global --output, commands keep their interactive or styled views when it is not set
+func OutputFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:     "output",
+		Usage:    "print results as table, wide, json or yaml, colors are dropped when stdout is not a terminal",
+		Required: false,
+		Action: func(ctx context.Context, c *cli.Command, v string) error {
+			if slices.Contains(Outputs, v) {
+				return nil
+			}
+			return fmt.Errorf("please provide a valid value in %s", strings.Join(Outputs, ","))
+		},
+	}
+}
+
+// Structured json or yaml output, stdout must stay parseable
+func Structured(output string) bool {
+	return output == OutputJSON || output == OutputYAML
+}
+
+// ErrorOutput structured error written to stdout with json or yaml output
+type ErrorOutput struct {
+	Error string `json:"error"`
+}
+
+// PrintedError error already shown to the user by PrintError, main exits without printing it again
+type PrintedError struct {
+	Err error
+}
+
+func (e *PrintedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PrintedError) Unwrap() error {
+	return e.Err
+}
+
+// PrintError prints err for humans, with json or yaml output it is written in the same format.
+// errors already printed are returned as is
+func PrintError(output string, err error) error {
+	var printedErr *PrintedError
+	if errors.As(err, &printedErr) {
+		return err
+	}
+	if Structured(output) {
+		_ = WriteOutput(os.Stdout, output, ErrorOutput{Error: err.Error()}, nil, nil)
+	} else {
+		fmt.Printf("%v\n", err)
+	}
+	return &PrintedError{Err: err}
+}
+
+// WriteOutput writes value as json or yaml, table is rendered from headers and rows.
+// yaml is converted from json so both use json field names
+func WriteOutput(w io.Writer, output string, value any, headers []string, rows [][]string) error {
+	switch output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case OutputYAML:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		// json is valid yaml, node keeps field order
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		_, err := fmt.Fprintln(w, RenderTable(headers, rows))
+		return err
+	}
+}
+
+// blockStyle drops json flow style and quoting, strings that would change type stay quoted
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// RenderTable table with alternating row colors
+func RenderTable(headers []string, rows [][]string) string {
+	return RenderHighlightTable(headers, rows, nil)
+}
+
+// RenderHighlightTable table with alternating row colors, rows where highlight is true render in red
+func RenderHighlightTable(headers []string, rows [][]string, highlight func(row int) bool) string {
+	var (
+		// HeaderStyle is the lipgloss style used for the table headers.
+		HeaderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#929292")).Bold(true).Align(lipgloss.Center)
+		// CellStyle is the base lipgloss style used for the table rows.
+		CellStyle = lipgloss.NewStyle().Padding(0, 1)
+		// OddRowStyle is the lipgloss style used for odd-numbered table rows.
+		OddRowStyle = CellStyle.Foreground(lipgloss.Color("#FDFF90"))
+		// EvenRowStyle is the lipgloss style used for even-numbered table rows.
+		EvenRowStyle = CellStyle.Foreground(lipgloss.Color("#97AD64"))
+		// HighlightRowStyle is the lipgloss style used for highlighted table rows.
+		HighlightRowStyle = CellStyle.Foreground(lipgloss.Color("#FF6060"))
+		// BorderStyle is the lipgloss style used for the table border.
+		BorderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#97AD64"))
+	)
+
+	return table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(BorderStyle).
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return HeaderStyle
+			case highlight != nil && highlight(row):
+				return HighlightRowStyle
+			case row%2 == 0:
+				return EvenRowStyle
+			default:
+				return OddRowStyle
+			}
+		}).
+		String()
+}
+
+// MatchAny name matches any of the glob patterns, eg: org/* or org/service-*
+func MatchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGlob pattern has glob meta characters
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
@@ -2,9 +2,9 @@ package orgs
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 
 	"github.com/urfave/cli/v3"
 )
@@ -17,10 +17,16 @@ func Command() *cli.Command {
 			{
 				Name:  "list",
 				Usage: "list orgs for authenticated user",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "org",
+						Usage:    "only orgs matching glob, --org 'team-*'",
+						Required: false,
+					},
+				},
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := RunListOrgs(github.DefaultClient); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := RunListOrgs(github.DefaultClient, c.StringSlice("org"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -2,11 +2,13 @@ package orgs
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 )
 
 var (
@@ -55,14 +57,35 @@ func Run(items []list.Item) error {
 	return nil
 }
 
-func RunListOrgs(c github.Client) error {
+type orgOutput struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Url   string `json:"url"`
+}
+
+// RunListOrgs opens interactive list unless output or filters are provided
+func RunListOrgs(c github.Client, patterns []string, output string) error {
 	orgItems, err := c.ListOrgsForUser()
 	if err != nil {
 		return err
 	}
-	var listItems []list.Item
+
+	if output == "" && len(patterns) <= 0 {
+		var listItems []list.Item
+		for _, orgItem := range orgItems {
+			listItems = append(listItems, orgItem)
+		}
+		return Run(listItems)
+	}
+
+	orgOutputs := []orgOutput{}
+	rows := [][]string{}
 	for _, orgItem := range orgItems {
-		listItems = append(listItems, orgItem)
+		if len(patterns) > 0 && !helpers.MatchAny(patterns, orgItem.Login) {
+			continue
+		}
+		orgOutputs = append(orgOutputs, orgOutput{Login: orgItem.Login, Name: orgItem.Name, Url: orgItem.Url})
+		rows = append(rows, []string{orgItem.Login, orgItem.Name, orgItem.Url})
 	}
-	return Run(listItems)
+	return helpers.WriteOutput(os.Stdout, output, orgOutputs, []string{"ORG", "NAME", "URL"}, rows)
 }
@@ -75,12 +75,12 @@ func approvePipelineRun(name, id string, pipeline *Pipeline, pipelineRun *Pipeli
 		return nil
 	}
 
-	cachedStore, err := users.GetCachedTokens()
+	currentUser, err := users.CurrentUser()
 	if err != nil {
 		return err
 	}
 
-	approvedBy := fmt.Sprintf("%s(%s)", cachedStore.GithubUser.Name, cachedStore.GithubUser.Login)
+	approvedBy := fmt.Sprintf("%s(%s)", currentUser.Name, currentUser.Login)
 
 	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
 	for _, i := range approvals {
@@ -91,10 +91,10 @@ func approvePipelineRun(name, id string, pipeline *Pipeline, pipelineRun *Pipeli
 
 		fmt.Println(s)
 
-		pipelineRun.Stages[stageNum].Metadata.Approval = StageRunApproval{Name: cachedStore.GithubUser.Name, Login: cachedStore.GithubUser.Login, Email: cachedStore.GithubUser.Email}
+		pipelineRun.Stages[stageNum].Metadata.Approval = StageRunApproval{Name: currentUser.Name, Login: currentUser.Login, Email: currentUser.Email}
 
 		reason := fmt.Sprintf("Stage Approved %d - %s", stageNum, stage.Name)
-		if err := audit.Save(context.Background(), AUDIT_APPROVED, resource, cachedStore.GithubUser.Login, cachedStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_APPROVED, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 	}
@@ -163,7 +163,7 @@ func cancelApprovePipelineRun(name, id string, pipeline *Pipeline, pipelineRun *
 		return nil
 	}
 
-	cachedStore, err := users.GetCachedTokens()
+	currentUser, err := users.CurrentUser()
 	if err != nil {
 		return err
 	}
@@ -180,7 +180,7 @@ func cancelApprovePipelineRun(name, id string, pipeline *Pipeline, pipelineRun *
 		pipelineRun.Stages[stageNum].Metadata.Approval = StageRunApproval{}
 
 		reason := fmt.Sprintf("Canceled Approval for stage %d - %s", stageNum, stage.Name)
-		if err := audit.Save(context.Background(), AUDIT_CANCEL_APPROVAL, resource, cachedStore.GithubUser.Login, cachedStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_CANCEL_APPROVAL, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 	}
@@ -244,9 +244,9 @@ func ApprovePipelineRun(ctx context.Context, name, id string, stageNum int) erro
 		resource := map[string]string{"Pipeline": pipeline.Name}
 		latestAudit, err := audit.Latest(ctx, AUDIT_LOCKED, resource)
 		if err != nil {
-			return fmt.Errorf("Pipeline locked at %s, by %s(%s) due to %s", latestAudit.Time.String(), latestAudit.Actor, latestAudit.Email, latestAudit.Message)
+			return err
 		}
-		return nil
+		return fmt.Errorf("Pipeline locked at %s, by %s(%s) due to %s", latestAudit.Time.String(), latestAudit.Actor, latestAudit.Email, latestAudit.Message)
 	}
 
 	pipelineRun, err := GetPipelineRun(ctx, name, id)
@@ -254,22 +254,27 @@ func ApprovePipelineRun(ctx context.Context, name, id string, stageNum int) erro
 		return err
 	}
 
-	if stageNum < 0 && stageNum >= len(pipelineRun.Stages) {
+	if stageNum < 0 || stageNum >= len(pipelineRun.Stages) {
 		return fmt.Errorf("%d invalid stage, choose between 0 and %d", stageNum, len(pipelineRun.Stages)-1)
 	}
 
+	if stageNum >= len(pipeline.Stages) || !pipeline.Stages[stageNum].Approval {
+		return fmt.Errorf("stage %d does not require approval", stageNum)
+	}
+
 	if pipelineRun.Stages[stageNum].Metadata.Approval.Name != "" {
 		return nil
 	}
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 
 	pipelineRun.Stages[stageNum].Metadata.Approval = StageRunApproval{Name: userName, Email: userEmail}
 
 	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
 	reason := fmt.Sprintf("Stage Approved %d - %s", stageNum, pipelineRun.Stages[stageNum].Name)
-	if err := audit.Save(ctx, AUDIT_APPROVED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_APPROVED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 
@@ -282,7 +287,7 @@ func CancelApprovePipelineRun(ctx context.Context, name, id string, stageNum int
 		return err
 	}
 
-	if stageNum < 0 && stageNum >= len(pipelineRun.Stages) {
+	if stageNum < 0 || stageNum >= len(pipelineRun.Stages) {
 		return fmt.Errorf("%d invalid stage, choose between 0 and %d", stageNum, len(pipelineRun.Stages)-1)
 	}
 
@@ -296,10 +301,11 @@ func CancelApprovePipelineRun(ctx context.Context, name, id string, stageNum int
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 
 	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
 	reason := fmt.Sprintf("Canceled Approval for stage %d - %s", stageNum, pipelineRun.Stages[stageNum].Name)
-	if err := audit.Save(ctx, AUDIT_APPROVED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_APPROVED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 
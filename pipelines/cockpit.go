@@ -0,0 +1,301 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/users"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/urfave/cli/v3"
+)
+
+// CockpitRunLimit latest runs of pipeline listed by cockpit
+const CockpitRunLimit = 50
+
+// browseURL opens stage urls from cockpit, replaced in tests
+var browseURL = helpers.OpenBrowser
+
+func UICommand() *cli.Command {
+	return &cli.Command{
+		Name:  "ui",
+		Usage: "full screen cockpit to browse pipelines and runs, approve, pause, lock and start runs",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if err := RunCockpit(c.Duration("refresh")); err != nil {
+				fmt.Printf("%v\n", err)
+				return err
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:     "refresh",
+				Usage:    "interval to reload pipelines and runs",
+				Value:    2 * time.Second,
+				Required: false,
+			},
+		},
+	}
+}
+
+// RunCockpit full screen ui across all pipelines, runs started from it are orchestrated until ui exits
+func RunCockpit(refresh time.Duration) error {
+	if _, err := tea.NewProgram(newCockpit(refresh), tea.WithAltScreen()).Run(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// activeRuns runs orchestrated by cockpit process
+type activeRuns struct {
+	m    map[string]bool
+	lock sync.Mutex
+}
+
+func (a *activeRuns) Add(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.m[id] = true
+}
+
+func (a *activeRuns) Remove(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.m, id)
+}
+
+func (a *activeRuns) Has(id string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.m[id]
+}
+
+func (a *activeRuns) Count() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return len(a.m)
+}
+
+type cockpitTickMsg time.Time
+
+type cockpitPipelinesMsg struct {
+	pipelines []*Pipeline
+	latest    map[string]*PipelineRun
+	err       error
+}
+
+type cockpitRunsMsg struct {
+	name     string
+	pipeline *Pipeline
+	runs     []*PipelineRun
+	err      error
+}
+
+type cockpitRunMsg struct {
+	name, id           string
+	pipeline           *Pipeline
+	run                *PipelineRun
+	pausedBy, lockedBy string
+	err                error
+}
+
+type cockpitActionMsg struct {
+	message string
+	err     error
+}
+
+type cockpitRunDoneMsg struct {
+	name, id string
+	err      error
+}
+
+func loadCockpitPipelines() tea.Msg {
+	ctx := context.Background()
+	pipelines, err := ListPipelines(ctx)
+	if err != nil {
+		return cockpitPipelinesMsg{err: err}
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+
+	latest := make(map[string]*PipelineRun)
+	for _, pipeline := range pipelines {
+		pipelineRuns, err := GetPipelineRunsN(ctx, pipeline.Name, 1)
+		if err != nil {
+			return cockpitPipelinesMsg{err: err}
+		}
+		if len(pipelineRuns) > 0 {
+			latest[pipeline.Name] = pipelineRuns[0]
+		}
+	}
+
+	return cockpitPipelinesMsg{pipelines: pipelines, latest: latest}
+}
+
+func loadCockpitRuns(name string) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		pipeline, err := GetPipeline(ctx, name)
+		if err != nil {
+			return cockpitRunsMsg{name: name, err: err}
+		}
+		pipelineRuns, err := GetPipelineRunsN(ctx, name, CockpitRunLimit)
+		return cockpitRunsMsg{name: name, pipeline: pipeline, runs: pipelineRuns, err: err}
+	}
+}
+
+func loadCockpitRun(name, id string) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		msg := cockpitRunMsg{name: name, id: id}
+		msg.pipeline, msg.err = GetPipeline(ctx, name)
+		if msg.err != nil {
+			return msg
+		}
+		msg.run, msg.err = GetPipelineRun(ctx, name, id)
+		if msg.err != nil {
+			return msg
+		}
+
+		if msg.pipeline.Locked {
+			if latestAudit, err := audit.Latest(ctx, AUDIT_LOCKED, map[string]string{"Pipeline": name}); err == nil {
+				msg.lockedBy = fmt.Sprintf("at %s by %s(%s) due to %s", latestAudit.Time.String(), latestAudit.Actor, latestAudit.Email, latestAudit.Message)
+			}
+		}
+		if msg.run.Paused {
+			resource := map[string]string{"Pipeline": name, "PipelineRun": id}
+			if latestAudit, err := audit.Latest(ctx, AUDIT_PAUSED, resource); err == nil {
+				msg.pausedBy = fmt.Sprintf("at %s by %s(%s) - \"%s\"", latestAudit.Time.String(), latestAudit.Actor, latestAudit.Email, latestAudit.Message)
+			}
+		}
+		return msg
+	}
+}
+
+// approveCockpitStage approves stage with the checks of ApprovePipelineRunUI, locked pipelines are rejected
+// and only stages requiring approval not yet approved are approved
+func approveCockpitStage(name, id string, stageNum int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, err := users.ActorContext(context.Background())
+		if err != nil {
+			return cockpitActionMsg{err: err}
+		}
+
+		pipeline, err := GetPipeline(ctx, name)
+		if err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		pipelineRun, err := GetPipelineRun(ctx, name, id)
+		if err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		if stageNum < 0 || stageNum >= len(pipelineRun.Stages) || stageNum >= len(pipeline.Stages) {
+			return cockpitActionMsg{err: fmt.Errorf("stage %d not found", stageNum+1)}
+		}
+
+		stageRun := pipelineRun.Stages[stageNum]
+		if !pipeline.Stages[stageNum].Approval {
+			return cockpitActionMsg{err: fmt.Errorf("stage %d - %s does not require approval", stageNum+1, stageRun.Name)}
+		}
+		if approval := stageRun.Metadata.Approval; approval.Name != "" || approval.Login != "" {
+			return cockpitActionMsg{message: fmt.Sprintf("Stage %d - %s already approved by %s(%s)", stageNum+1, stageRun.Name, approval.Name, approval.Login)}
+		}
+
+		if err := ApprovePipelineRun(ctx, name, id, stageNum); err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		return cockpitActionMsg{message: fmt.Sprintf("Stage %d - %s approved by %s, continue run with c", stageNum+1, stageRun.Name, ctx.Value(users.NameCtx))}
+	}
+}
+
+func pauseResumeCockpitRun(name, id, reason string, pause bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, err := users.ActorContext(context.Background())
+		if err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		if pause {
+			if err := PausePipelineRun(ctx, name, id, reason); err != nil {
+				return cockpitActionMsg{err: err}
+			}
+			return cockpitActionMsg{message: fmt.Sprintf("Paused pipeline %s run %s", name, id)}
+		}
+		if err := ResumePipelineRun(ctx, name, id, reason); err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		return cockpitActionMsg{message: fmt.Sprintf("Resumed pipeline %s run %s, continue run with c", name, id)}
+	}
+}
+
+func lockUnlockCockpitPipeline(name, reason string, lock bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, err := users.ActorContext(context.Background())
+		if err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		if lock {
+			if err := LockPipeline(ctx, name, reason); err != nil {
+				return cockpitActionMsg{err: err}
+			}
+			return cockpitActionMsg{message: fmt.Sprintf("Locked pipeline %s", name)}
+		}
+		if err := UnlockPipeline(ctx, name, reason); err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		return cockpitActionMsg{message: fmt.Sprintf("Unlocked pipeline %s", name)}
+	}
+}
+
+// orchestrateCockpitRun orchestrates new or existing run until it completes, pauses or waits for approval.
+// Inputs of existing runs are loaded from store
+func orchestrateCockpitRun(active *activeRuns, name, id string, inputs map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		defer active.Remove(id)
+
+		trigger, err := manualTrigger()
+		if err != nil {
+			return cockpitRunDoneMsg{name: name, id: id, err: err}
+		}
+		err = RunPipeline(context.Background(), name, id, inputs, nil, trigger, false)
+		return cockpitRunDoneMsg{name: name, id: id, err: err}
+	}
+}
+
+func openCockpitURL(url string) tea.Cmd {
+	return func() tea.Msg {
+		if err := browseURL(url); err != nil {
+			return cockpitActionMsg{err: err}
+		}
+		return cockpitActionMsg{message: fmt.Sprintf("Opened %s", url)}
+	}
+}
+
+// runInputKeys inputs left empty in any stage, they are provided when starting a run
+func runInputKeys(pipeline *Pipeline) []string {
+	var keys []string
+	for _, stage := range pipeline.Stages {
+		for key, value := range stage.Input {
+			if value == "" && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runCompleted runs which are not orchestrated again
+func runCompleted(state string) bool {
+	switch State(state) {
+	case SUCCESS, FAILED, ROLLBACK, CANCELED:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github/githubtest"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cockpitUpdate feeds msg to cockpit followed by results of commands it returns, ticks and cursor blinks are dropped
+func cockpitUpdate(m cockpit, msg tea.Msg) cockpit {
+	model, cmd := m.Update(msg)
+	m = model.(cockpit)
+	for _, msg := range cockpitMsgs(cmd) {
+		m = cockpitUpdate(m, msg)
+	}
+	return m
+}
+
+func cockpitMsgs(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		var msgs []tea.Msg
+		for _, cmd := range msg {
+			msgs = append(msgs, cockpitMsgs(cmd)...)
+		}
+		return msgs
+	case cockpitPipelinesMsg, cockpitRunsMsg, cockpitRunMsg, cockpitActionMsg, cockpitRunDoneMsg:
+		return []tea.Msg{msg}
+	}
+	return nil
+}
+
+func cockpitKeys(m cockpit, keys ...string) cockpit {
+	for _, key := range keys {
+		switch key {
+		case "enter":
+			m = cockpitUpdate(m, tea.KeyMsg{Type: tea.KeyEnter})
+		case "esc":
+			m = cockpitUpdate(m, tea.KeyMsg{Type: tea.KeyEsc})
+		case "down":
+			m = cockpitUpdate(m, tea.KeyMsg{Type: tea.KeyDown})
+		default:
+			if m.prompt != nil {
+				// typing into prompt only returns cursor blinks which sleep before they are delivered
+				model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+				m = model.(cockpit)
+				continue
+			}
+			m = cockpitUpdate(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		}
+	}
+	return m
+}
+
+func TestCockpitNavigate(t *testing.T) {
+	_, pipelineRun := setupOutputTest(t)
+
+	browsed := ""
+	openBrowser := browseURL
+	browseURL = func(url string) error {
+		browsed = url
+		return nil
+	}
+	t.Cleanup(func() {
+		browseURL = openBrowser
+	})
+
+	m := cockpitUpdate(newCockpit(time.Millisecond), loadCockpitPipelines())
+	assert.Contains(t, m.View(), "Output")
+	assert.Contains(t, m.View(), "Rollback")
+
+	m = cockpitKeys(m, "enter")
+	assert.Equal(t, runsView, m.view)
+	assert.Contains(t, m.View(), "Pipelines › Output")
+	assert.Contains(t, m.View(), "run1")
+
+	m = cockpitKeys(m, "enter")
+	assert.Equal(t, runView, m.view)
+	view := m.View()
+	assert.Contains(t, view, "Pipelines › Output › run1")
+	assert.Contains(t, view, "Approved by Octo Cat(octocat)")
+	assert.Contains(t, view, "Rollback Success")
+	assert.Contains(t, view, "Notify-1")
+
+	// rolled back stage opens rollback workflow run
+	m = cockpitKeys(m, "o")
+	assert.Equal(t, pipelineRun.Stages[0].Rollback.Url, browsed)
+
+	m = cockpitKeys(m, "down", "o")
+	assert.EqualError(t, m.err, "stage 2 - Notify-1 has no workflow run yet")
+
+	m = cockpitKeys(m, "c")
+	assert.EqualError(t, m.err, "pipeline run run1 already completed with Rollback")
+
+	m = cockpitKeys(m, "esc", "esc")
+	assert.Equal(t, pipelinesView, m.view)
+	assert.Nil(t, m.err)
+}
+
+func TestCockpitActions(t *testing.T) {
+	githubtest.NewServer(t)
+	_, pipelineRun := setupOutputTest(t)
+	ctx := context.Background()
+
+	pipelineRun.State = string(PENDING_APPROVAL)
+	pipelineRun.Stages[0].State = "PendingApproval"
+	pipelineRun.Stages[0].Metadata.Approval = StageRunApproval{}
+	pipelineRun.Stages[0].Rollback = nil
+	require.NoError(t, savePipelineRun(ctx, pipelineRun))
+
+	m := cockpitUpdate(newCockpit(time.Millisecond), loadCockpitPipelines())
+	m = cockpitKeys(m, "enter", "enter")
+	assert.Contains(t, m.View(), "Approval required, approve with a")
+
+	// locked pipeline rejects approvals, reason is required
+	m = cockpitKeys(m, "l", "enter")
+	assert.EqualError(t, m.err, "reason is required")
+	m = cockpitKeys(m, "f", "r", "e", "e", "z", "e", "enter")
+	require.NoError(t, m.err)
+	assert.Equal(t, "Locked pipeline Output", m.message)
+	assert.Contains(t, m.View(), "due to freeze")
+
+	m = cockpitKeys(m, "a")
+	assert.ErrorContains(t, m.err, "Pipeline locked at")
+
+	m = cockpitKeys(m, "l", "o", "k", "enter", "a")
+	require.NoError(t, m.err)
+	assert.Equal(t, "Stage 1 - Deploy-0 approved by Github Test, continue run with c", m.message)
+	assert.Contains(t, m.View(), "Approved by Github Test")
+
+	saved, err := GetPipelineRun(ctx, "Output", "run1")
+	require.NoError(t, err)
+	assert.Equal(t, "Github Test", saved.Stages[0].Metadata.Approval.Name)
+
+	m = cockpitKeys(m, "a")
+	assert.Equal(t, "Stage 1 - Deploy-0 already approved by Github Test()", m.message)
+
+	m = cockpitKeys(m, "down", "a")
+	assert.EqualError(t, m.err, "stage 2 - Notify-1 does not require approval")
+
+	// paused runs are not orchestrated until resumed
+	m = cockpitKeys(m, "p", "h", "o", "l", "d", "enter")
+	require.NoError(t, m.err)
+	assert.Contains(t, m.View(), "by Github Test(githubtest@example.com) - \"hold\"")
+	m = cockpitKeys(m, "c")
+	assert.EqualError(t, m.err, "pipeline run run1 is paused, resume it with p")
+
+	m = cockpitKeys(m, "p", "g", "o", "enter")
+	require.NoError(t, m.err)
+	saved, err = GetPipelineRun(ctx, "Output", "run1")
+	require.NoError(t, err)
+	assert.False(t, saved.Paused)
+}
+
+func TestCockpitRun(t *testing.T) {
+	setup := setupE2E(t, []Stage{{Input: map[string]string{"version": ""}}})
+
+	m := cockpitUpdate(newCockpit(time.Millisecond), loadCockpitPipelines())
+	pipeline := m.selectedPipeline()
+	require.NotNil(t, pipeline)
+	assert.Equal(t, []string{"version"}, runInputKeys(pipeline))
+
+	model, _ := m.newRun()
+	m = model.(cockpit)
+	require.NotNil(t, m.form)
+	assert.Contains(t, m.form.inputs, "version")
+	m = cockpitUpdate(m, tea.KeyMsg{Type: tea.KeyCtrlC})
+	assert.Nil(t, m.form)
+	assert.Equal(t, "Run not started", m.message)
+
+	// runs started from cockpit are orchestrated in process
+	id := uuid.NewString()
+	m.active.Add(id)
+	msg := orchestrateCockpitRun(m.active, "E2E", id, map[string]string{"version": "v1"})()
+	assert.Equal(t, cockpitRunDoneMsg{name: "E2E", id: id}, msg)
+	assert.Zero(t, m.active.Count())
+
+	pipelineRun, err := GetPipelineRun(setup.ctx, "E2E", id)
+	require.NoError(t, err)
+	assert.Equal(t, string(SUCCESS), pipelineRun.State)
+	assert.Equal(t, "githubtest", pipelineRun.Trigger.Login)
+
+	// quitting with runs orchestrated here asks again
+	m.active.Add(uuid.NewString())
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	m = model.(cockpit)
+	assert.Nil(t, cmd)
+	assert.Contains(t, m.message, "1 runs orchestrated here stop when ui exits")
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	require.NotNil(t, cmd)
+	assert.Equal(t, tea.QuitMsg{}, cmd())
+}
@@ -0,0 +1,662 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/store"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+type cockpitView int
+
+const (
+	pipelinesView cockpitView = iota
+	runsView
+	runView
+)
+
+var selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+
+// cockpitPrompt asks reason for audited actions
+type cockpitPrompt struct {
+	title  string
+	input  textinput.Model
+	submit func(reason string) tea.Cmd
+}
+
+// cockpitForm inputs of run started from cockpit
+type cockpitForm struct {
+	form   *huh.Form
+	name   string
+	inputs map[string]*string
+	start  *bool
+}
+
+type cockpit struct {
+	refresh time.Duration
+	view    cockpitView
+	active  *activeRuns
+	height  int
+
+	pipelines      []*Pipeline
+	latest         map[string]*PipelineRun
+	pipelineCursor int
+
+	// name pipeline selected for runs and run views
+	name      string
+	pipeline  *Pipeline
+	runs      []*PipelineRun
+	runCursor int
+
+	// id run selected for run view
+	id          string
+	run         *PipelineRun
+	pausedBy    string
+	lockedBy    string
+	stageCursor int
+	expand      bool
+
+	prompt *cockpitPrompt
+	form   *cockpitForm
+
+	message     string
+	err         error
+	confirmQuit bool
+}
+
+func newCockpit(refresh time.Duration) cockpit {
+	return cockpit{refresh: refresh, active: &activeRuns{m: make(map[string]bool)}}
+}
+
+func (m cockpit) Init() tea.Cmd {
+	return tea.Batch(loadCockpitPipelines, m.tick())
+}
+
+func (m cockpit) tick() tea.Cmd {
+	return tea.Tick(m.refresh, func(t time.Time) tea.Msg {
+		return cockpitTickMsg(t)
+	})
+}
+
+// load reloads data of current view
+func (m cockpit) load() tea.Cmd {
+	switch m.view {
+	case runsView:
+		return loadCockpitRuns(m.name)
+	case runView:
+		return loadCockpitRun(m.name, m.id)
+	default:
+		return loadCockpitPipelines
+	}
+}
+
+func (m cockpit) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case cockpitTickMsg:
+		return m, tea.Batch(m.load(), m.tick())
+	case cockpitPipelinesMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.pipelines, m.latest = msg.pipelines, msg.latest
+		m.pipelineCursor = clampCursor(m.pipelineCursor, len(m.pipelines))
+		return m, nil
+	case cockpitRunsMsg:
+		if msg.name != m.name {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.pipeline, m.runs = msg.pipeline, msg.runs
+		m.runCursor = clampCursor(m.runCursor, len(m.runs))
+		return m, nil
+	case cockpitRunMsg:
+		if msg.name != m.name || msg.id != m.id {
+			return m, nil
+		}
+		if msg.err != nil {
+			// run started from cockpit is saved on first orchestrator tick
+			if errors.Is(msg.err, store.ErrKeyNotFound) && m.active.Has(m.id) {
+				return m, nil
+			}
+			m.err = msg.err
+			return m, nil
+		}
+		m.pipeline, m.run, m.pausedBy, m.lockedBy = msg.pipeline, msg.run, msg.pausedBy, msg.lockedBy
+		m.stageCursor = clampCursor(m.stageCursor, len(m.run.Stages))
+		return m, nil
+	case cockpitActionMsg:
+		m.message, m.err = msg.message, msg.err
+		return m, m.load()
+	case cockpitRunDoneMsg:
+		m.message, m.err = "", msg.err
+		if msg.err == nil {
+			m.message = fmt.Sprintf("Stopped orchestrating pipeline %s run %s", msg.name, msg.id)
+		}
+		return m, m.load()
+	}
+
+	if m.form != nil {
+		return m.updateForm(msg)
+	}
+	if m.prompt != nil {
+		return m.updatePrompt(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		return m.updateKey(msg)
+	}
+	return m, nil
+}
+
+func (m cockpit) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	if key != "q" && key != "ctrl+c" {
+		m.confirmQuit = false
+	}
+
+	switch key {
+	case "ctrl+c", "q":
+		if count := m.active.Count(); count > 0 && !m.confirmQuit {
+			m.confirmQuit = true
+			m.message, m.err = fmt.Sprintf("%d runs orchestrated here stop when ui exits, continue them using pippy pipeline run execute --id, press q again to quit", count), nil
+			return m, nil
+		}
+		return m, tea.Quit
+	case "esc", "backspace", "left":
+		return m.back()
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "enter", "right":
+		return m.open()
+	case "n":
+		return m.newRun()
+	case "l":
+		return m.lock()
+	case "p":
+		return m.pause()
+	case "c":
+		return m.orchestrate()
+	case "a":
+		return m.approve()
+	case "o":
+		return m.browse()
+	case "e":
+		if m.view == runView {
+			m.expand = !m.expand
+		}
+	}
+	return m, nil
+}
+
+func (m cockpit) back() (tea.Model, tea.Cmd) {
+	m.message, m.err = "", nil
+	switch m.view {
+	case runView:
+		m.view, m.id, m.run = runsView, "", nil
+	case runsView:
+		m.view, m.name, m.pipeline, m.runs, m.runCursor = pipelinesView, "", nil, nil, 0
+	}
+	return m, m.load()
+}
+
+func (m *cockpit) move(delta int) {
+	switch m.view {
+	case pipelinesView:
+		m.pipelineCursor = clampCursor(m.pipelineCursor+delta, len(m.pipelines))
+	case runsView:
+		m.runCursor = clampCursor(m.runCursor+delta, len(m.runs))
+	case runView:
+		if m.run != nil {
+			m.stageCursor = clampCursor(m.stageCursor+delta, len(m.run.Stages))
+		}
+	}
+}
+
+func (m cockpit) open() (tea.Model, tea.Cmd) {
+	switch m.view {
+	case pipelinesView:
+		if len(m.pipelines) <= 0 {
+			return m, nil
+		}
+		m.view, m.name, m.message, m.err = runsView, m.pipelines[m.pipelineCursor].Name, "", nil
+	case runsView:
+		if len(m.runs) <= 0 {
+			return m, nil
+		}
+		m.view, m.id, m.run, m.stageCursor, m.message, m.err = runView, m.runs[m.runCursor].Id, nil, 0, "", nil
+	default:
+		return m, nil
+	}
+	return m, m.load()
+}
+
+// selectedPipeline pipeline under cursor or pipeline of runs and run views
+func (m cockpit) selectedPipeline() *Pipeline {
+	if m.view == pipelinesView {
+		if len(m.pipelines) <= 0 {
+			return nil
+		}
+		return m.pipelines[m.pipelineCursor]
+	}
+	return m.pipeline
+}
+
+// selectedRun run under cursor or run of run view
+func (m cockpit) selectedRun() *PipelineRun {
+	switch m.view {
+	case runsView:
+		if len(m.runs) <= 0 {
+			return nil
+		}
+		return m.runs[m.runCursor]
+	case runView:
+		return m.run
+	}
+	return nil
+}
+
+func (m cockpit) newRun() (tea.Model, tea.Cmd) {
+	pipeline := m.selectedPipeline()
+	if pipeline == nil {
+		return m, nil
+	}
+
+	form := &cockpitForm{name: pipeline.Name, inputs: make(map[string]*string), start: new(bool)}
+	var fields []huh.Field
+	for _, key := range runInputKeys(pipeline) {
+		value := ""
+		form.inputs[key] = &value
+		fields = append(fields, huh.NewInput().Title(key).Value(&value))
+	}
+	fields = append(fields, huh.NewConfirm().Title(fmt.Sprintf("Start run of pipeline %s?", pipeline.Name)).Value(form.start))
+	form.form = huh.NewForm(huh.NewGroup(fields...))
+
+	m.form, m.message, m.err = form, "", nil
+	return m, form.form.Init()
+}
+
+func (m cockpit) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.form.form.Update(msg)
+	if form, ok := model.(*huh.Form); ok {
+		m.form.form = form
+	}
+
+	switch m.form.form.State {
+	case huh.StateAborted:
+		m.form, m.message = nil, "Run not started"
+		return m, nil
+	case huh.StateCompleted:
+		form := m.form
+		m.form = nil
+		if !*form.start {
+			m.message = "Run not started"
+			return m, nil
+		}
+
+		inputs := make(map[string]string)
+		for key, value := range form.inputs {
+			if *value != "" {
+				inputs[key] = *value
+			}
+		}
+		id := uuid.NewString()
+		m.active.Add(id)
+		m.view, m.name, m.id, m.run, m.stageCursor = runView, form.name, id, nil, 0
+		m.message = fmt.Sprintf("Started pipeline %s run %s", form.name, id)
+		return m, tea.Batch(cmd, orchestrateCockpitRun(m.active, form.name, id, inputs), m.load())
+	}
+	return m, cmd
+}
+
+func (m cockpit) newPrompt(title string, submit func(reason string) tea.Cmd) (tea.Model, tea.Cmd) {
+	input := textinput.New()
+	input.Placeholder = "reason"
+	input.Focus()
+	m.prompt = &cockpitPrompt{title: title, input: input, submit: submit}
+	m.message, m.err = "", nil
+	return m, textinput.Blink
+}
+
+func (m cockpit) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc", "ctrl+c":
+			m.prompt = nil
+			return m, nil
+		case "enter":
+			reason := strings.TrimSpace(m.prompt.input.Value())
+			if reason == "" {
+				m.err = errors.New("reason is required")
+				return m, nil
+			}
+			submit := m.prompt.submit
+			m.prompt, m.err = nil, nil
+			return m, submit(reason)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	return m, cmd
+}
+
+func (m cockpit) lock() (tea.Model, tea.Cmd) {
+	pipeline := m.selectedPipeline()
+	if pipeline == nil {
+		return m, nil
+	}
+	name, lock := pipeline.Name, !pipeline.Locked
+	title := fmt.Sprintf("Lock pipeline %s, approvals are rejected while locked", name)
+	if !lock {
+		title = fmt.Sprintf("Unlock pipeline %s", name)
+	}
+	return m.newPrompt(title, func(reason string) tea.Cmd {
+		return lockUnlockCockpitPipeline(name, reason, lock)
+	})
+}
+
+func (m cockpit) pause() (tea.Model, tea.Cmd) {
+	pipelineRun := m.selectedRun()
+	if pipelineRun == nil {
+		return m, nil
+	}
+	if runCompleted(pipelineRun.State) {
+		m.message, m.err = "", fmt.Errorf("pipeline run %s already completed with %s", pipelineRun.Id, pipelineRun.State)
+		return m, nil
+	}
+	name, id, pause := pipelineRun.PipelineName, pipelineRun.Id, !pipelineRun.Paused
+	title := fmt.Sprintf("Pause pipeline %s run %s", name, id)
+	if !pause {
+		title = fmt.Sprintf("Resume pipeline %s run %s", name, id)
+	}
+	return m.newPrompt(title, func(reason string) tea.Cmd {
+		return pauseResumeCockpitRun(name, id, reason, pause)
+	})
+}
+
+// orchestrate continues run waiting after approval or resume, like pipeline run execute --id
+func (m cockpit) orchestrate() (tea.Model, tea.Cmd) {
+	pipelineRun := m.selectedRun()
+	if pipelineRun == nil {
+		return m, nil
+	}
+	switch {
+	case runCompleted(pipelineRun.State):
+		m.message, m.err = "", fmt.Errorf("pipeline run %s already completed with %s", pipelineRun.Id, pipelineRun.State)
+		return m, nil
+	case m.active.Has(pipelineRun.Id):
+		m.message, m.err = fmt.Sprintf("Pipeline run %s is already orchestrated here", pipelineRun.Id), nil
+		return m, nil
+	case pipelineRun.Paused:
+		m.message, m.err = "", fmt.Errorf("pipeline run %s is paused, resume it with p", pipelineRun.Id)
+		return m, nil
+	}
+
+	m.active.Add(pipelineRun.Id)
+	m.message, m.err = fmt.Sprintf("Continuing pipeline %s run %s", pipelineRun.PipelineName, pipelineRun.Id), nil
+	return m, orchestrateCockpitRun(m.active, pipelineRun.PipelineName, pipelineRun.Id, map[string]string{})
+}
+
+func (m cockpit) approve() (tea.Model, tea.Cmd) {
+	if m.view != runView || m.run == nil || len(m.run.Stages) <= 0 {
+		return m, nil
+	}
+	return m, approveCockpitStage(m.name, m.id, m.stageCursor)
+}
+
+func (m cockpit) browse() (tea.Model, tea.Cmd) {
+	if m.view != runView || m.run == nil || len(m.run.Stages) <= 0 {
+		return m, nil
+	}
+	stageRun := m.run.Stages[m.stageCursor]
+	url := stageRun.Url
+	if stageRun.Rollback != nil && stageRun.Rollback.Url != "" && m.run.State == string(ROLLBACK) {
+		url = stageRun.Rollback.Url
+	}
+	if url == "" {
+		m.message, m.err = "", fmt.Errorf("stage %d - %s has no workflow run yet", m.stageCursor+1, stageRun.Name)
+		return m, nil
+	}
+	return m, openCockpitURL(url)
+}
+
+func clampCursor(cursor, n int) int {
+	return max(0, min(cursor, n-1))
+}
+
+// visible rows around cursor which fit in window, header and footer take remaining lines
+func (m cockpit) visible(cursor, n, linesPerRow int) (int, int) {
+	rows := n
+	if m.height > 0 {
+		rows = max(1, (m.height-10)/linesPerRow)
+	}
+	if n <= rows {
+		return 0, n
+	}
+	start := max(0, min(cursor-rows/2, n-rows))
+	return start, start + rows
+}
+
+func (m cockpit) View() string {
+	crumbs := []string{"Pipelines"}
+	if m.view >= runsView {
+		crumbs = append(crumbs, m.name)
+	}
+	if m.view == runView {
+		crumbs = append(crumbs, m.id)
+	}
+	s := currentStyle.Bold(true).Render("pippy") + " " + descriptionStyle.Render(strings.Join(crumbs, " › ")) + "\n\n"
+
+	if m.form != nil {
+		return s + m.form.form.View() + "\n" + descriptionStyle.Faint(true).Render("ctrl+c cancel")
+	}
+
+	switch m.view {
+	case pipelinesView:
+		s += m.pipelinesView()
+	case runsView:
+		s += m.runsView()
+	case runView:
+		s += m.runView()
+	}
+
+	if m.prompt != nil {
+		s += "\n" + warningStyle.Render(m.prompt.title) + "\n" + m.prompt.input.View() + "\n"
+	}
+
+	if m.err != nil {
+		s += "\n" + crossMark.Render() + " " + failedStyle.Render(m.err.Error()) + "\n"
+	} else if m.message != "" {
+		s += "\n" + checkMark.Render() + " " + doneStyle.Render(m.message) + "\n"
+	}
+
+	return s + "\n" + descriptionStyle.Faint(true).Render(m.help())
+}
+
+func (m cockpit) help() string {
+	if m.prompt != nil {
+		return "enter submit • esc cancel"
+	}
+	switch m.view {
+	case runsView:
+		return "↑/↓ move • enter details • n new run • p pause/resume • c continue • l lock/unlock • esc back • q quit"
+	case runView:
+		return "↑/↓ stage • a approve • o open • p pause/resume • c continue • e expand jobs • n new run • l lock/unlock • esc back • q quit"
+	default:
+		return "↑/↓ move • enter runs • n new run • l lock/unlock • q quit"
+	}
+}
+
+func (m cockpit) pipelinesView() string {
+	if len(m.pipelines) <= 0 {
+		return waitStyle.Render("No pipelines, create one using pippy pipeline create") + "\n"
+	}
+
+	s := ""
+	start, end := m.visible(m.pipelineCursor, len(m.pipelines), 1)
+	for i := start; i < end; i++ {
+		pipeline := m.pipelines[i]
+		line := fmt.Sprintf("%-32s %2d stages", pipeline.Name, len(pipeline.Stages))
+		if latest := m.latest[pipeline.Name]; latest != nil {
+			line += "  " + stateMark(latest.State) + " " + stateStyle(latest.State).Render(fmt.Sprintf("%-15s", latest.State)) + " " + waitStyle.Render(latest.Created.Format(time.RFC3339))
+		} else {
+			line += "  " + waitStyle.Render("no runs")
+		}
+		if pipeline.Locked {
+			line += " " + warningStyle.Render("locked")
+		}
+		s += cursorLine(i == m.pipelineCursor, line) + "\n"
+	}
+	return s
+}
+
+func (m cockpit) runsView() string {
+	s := ""
+	if m.pipeline != nil && m.pipeline.Locked {
+		s += warningStyle.Render("Pipeline locked, approvals are rejected") + "\n\n"
+	}
+	if len(m.runs) <= 0 {
+		return s + waitStyle.Render("No runs, start one with n") + "\n"
+	}
+
+	start, end := m.visible(m.runCursor, len(m.runs), 1)
+	for i := start; i < end; i++ {
+		pipelineRun := m.runs[i]
+		line := waitStyle.Render(pipelineRun.Created.Format(time.RFC3339)) + " " + pipelineRun.Id + " " +
+			stateMark(pipelineRun.State) + " " + stateStyle(pipelineRun.State).Render(fmt.Sprintf("%-15s", pipelineRun.State)) + " " +
+			pipelineRun.Updated.Sub(pipelineRun.Created).Truncate(time.Second).String()
+		if pipelineRun.Paused {
+			line += " " + warningStyle.Render("paused")
+		}
+		if m.active.Has(pipelineRun.Id) {
+			line += " " + currentStyle.Render("orchestrating")
+		}
+		if inputs := displayInputs(pipelineRun.Inputs); inputs != "" {
+			line += " " + descriptionStyle.Faint(true).Render(inputs)
+		}
+		s += cursorLine(i == m.runCursor, line) + "\n"
+	}
+	return s
+}
+
+func (m cockpit) runView() string {
+	if m.run == nil {
+		if m.active.Has(m.id) {
+			return currentStyle.Render("Starting pipeline run...") + "\n"
+		}
+		return waitStyle.Render("Loading pipeline run...") + "\n"
+	}
+
+	pipelineRun := m.run
+	s := stateMark(pipelineRun.State) + " " + stateStyle(pipelineRun.State).Render(pipelineRun.State) +
+		descriptionStyle.Render(fmt.Sprintf(" started at %s", pipelineRun.Created.Format(time.RFC3339)))
+	if trigger := pipelineRun.Trigger; trigger.Login != "" || trigger.Name != "" {
+		s += descriptionStyle.Render(fmt.Sprintf(" by %s(%s)", trigger.Name, trigger.Login))
+	}
+	if inputs := displayInputs(pipelineRun.Inputs); inputs != "" {
+		s += descriptionStyle.Faint(true).Render(" " + inputs)
+	}
+	if m.active.Has(pipelineRun.Id) {
+		s += " " + currentStyle.Render("orchestrating")
+	}
+	s += "\n"
+	if m.lockedBy != "" {
+		s += warningStyle.Render("Pipeline locked "+m.lockedBy) + "\n"
+	}
+	if pipelineRun.Paused {
+		s += clockMark.Render() + " " + warningStyle.Render(strings.TrimSpace("Paused "+m.pausedBy)) + "\n"
+	}
+	s += "\n"
+
+	for i, stageRun := range pipelineRun.Stages {
+		title := stageRun.Name
+		if stageRun.Title != "" {
+			title = stageRun.Title
+		}
+		line := stateMark(stageRun.State) + " " + stateStyle(stageRun.State).Render(title)
+		if !stageRun.Started.IsZero() {
+			completed := stageRun.Completed
+			if completed.IsZero() {
+				completed = time.Now().UTC()
+			}
+			line += " " + stateStyle(stageRun.State).Render(completed.Sub(stageRun.Started).Truncate(time.Second).String())
+		}
+		s += cursorLine(i == m.stageCursor, line)
+		if stageRun.Url != "" {
+			s += descriptionStyle.Faint(true).Render("\n    " + stageRun.Url)
+		}
+		approval := stageRun.Metadata.Approval
+		if approval.Name != "" || approval.Login != "" {
+			s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(fmt.Sprintf("%s(%s)", approval.Name, approval.Login))
+		} else if m.pipeline != nil && i < len(m.pipeline.Stages) && m.pipeline.Stages[i].Approval {
+			s += descriptionStyle.Faint(true).Render("\n    ") + warningStyle.Render("Approval required, approve with a")
+		}
+		if stageRun.Reason != "" && !strings.EqualFold(stageRun.State, "Success") {
+			s += descriptionStyle.Faint(true).Render("\n    Reason ") + failedStyle.Render(stageRun.Reason)
+		}
+		if failure := stageRun.MonitorFailure; failure != nil {
+			s += descriptionStyle.Faint(true).Render("\n    Monitor ") + failedStyle.Render(fmt.Sprintf("%s %s %s %s", failure.Monitor, failure.Name, failure.Group, failure.State))
+		}
+		s += renderJobs(stageRun.Jobs, m.expand)
+		if rollback := stageRun.Rollback; rollback != nil {
+			s += warningStyle.Faint(true).Render("\n    Rollback " + rollback.State + " " + rollback.Title)
+			if rollback.Url != "" {
+				s += warningStyle.Faint(true).Render("\n    	" + rollback.Url)
+			}
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func cursorLine(selected bool, line string) string {
+	if selected {
+		return selectedStyle.Render("› ") + line
+	}
+	return "  " + line
+}
+
+func stateStyle(state string) lipgloss.Style {
+	switch state {
+	case "Success", "Workflow_Success":
+		return doneStyle
+	case "Failed", "Workflow_Failed", "Rollback", "Canceled", "ConcurrentError":
+		return failedStyle
+	case "PendingApproval", "Paused", "Locked":
+		return warningStyle
+	case "InProgress":
+		return currentStyle
+	default:
+		return waitStyle
+	}
+}
+
+func stateMark(state string) string {
+	switch state {
+	case "Success", "Workflow_Success":
+		return checkMark.Render()
+	case "Failed", "Workflow_Failed", "Canceled", "ConcurrentError":
+		return crossMark.Render()
+	case "Rollback":
+		return rollbackMark.Render()
+	case "PendingApproval", "Paused":
+		return clockMark.Render()
+	default:
+		return bulletMark.Render()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/nixmade/pippy/github"
@@ -17,8 +18,10 @@ import (
 type Pipeline struct {
 	Name string `json:"name"`
 	//GroupStages []GroupStage `json:"group_stages"`
-	Stages []Stage `json:"stages"`
-	Locked bool    `json:"locked"`
+	Stages        []Stage        `json:"stages"`
+	Locked        bool           `json:"locked"`
+	Triggers      []Trigger      `json:"triggers,omitempty"`
+	Notifications []Notification `json:"notifications,omitempty"`
 }
 
 // type GroupStage struct {
@@ -32,6 +35,12 @@ type DatadogInfo struct {
 	ApiKey         string   `json:"api_key"`
 	ApplicationKey string   `json:"application_key"`
 	Rollback       bool     `json:"rollback"`
+	// Groups evaluated instead of overall state, run inputs are templated eg: env:prod,service:${{ inputs.service }}
+	Groups []string `json:"groups,omitempty"`
+	// FailureStates fail the stage, defaults to Alert
+	FailureStates []string `json:"failure_states,omitempty"`
+	// Events posts datadog events when stage is dispatched, completes or rolls back
+	Events bool `json:"events,omitempty"`
 }
 
 type WorkflowInfo struct {
@@ -41,8 +50,10 @@ type WorkflowInfo struct {
 
 type MonitorInfo struct {
 	// Monitor workflow state
-	Workflow WorkflowInfo `json:"workflow,omitempty"`
-	Datadog  *DatadogInfo `json:"datadog,omitempty"`
+	Workflow   WorkflowInfo    `json:"workflow,omitempty"`
+	Datadog    *DatadogInfo    `json:"datadog,omitempty"`
+	Prometheus *PrometheusInfo `json:"prometheus,omitempty"`
+	Http       *HttpInfo       `json:"http,omitempty"`
 }
 
 type Stage struct {
@@ -51,6 +62,8 @@ type Stage struct {
 	Approval bool              `json:"approval"`
 	Monitor  MonitorInfo       `json:"monitor,omitempty"`
 	Input    map[string]string `json:"input,omitempty"`
+	// Environment reports stage runs as github deployments when set
+	Environment string `json:"environment,omitempty"`
 }
 
 func GetRepos(repoType string) ([]string, error) {
@@ -161,6 +174,11 @@ func GetPipeline(ctx context.Context, name string) (*Pipeline, error) {
 }
 
 func SavePipeline(ctx context.Context, pipeline *Pipeline) error {
+	// never persist plaintext credentials
+	if _, err := externalizeSecrets(ctx, pipeline); err != nil {
+		return err
+	}
+
 	dbStore, err := store.Get(ctx)
 	if err != nil {
 		return err
@@ -235,13 +253,16 @@ func CreatePipeline(name, repoType string) error {
 			return err
 		}
 
-		var datadogMonitoring bool
+		var datadogMonitoring, prometheusMonitoring, httpMonitoring bool
 		var input string
 		if err := huh.NewForm(
 			huh.NewGroup(
 				huh.NewInput().
 					Title("Provide input override? eg: key=value,key1=value1").
 					Value(&input),
+				huh.NewInput().
+					Title("Github deployment environment? leave empty to skip eg: production").
+					Value(&stage.Environment),
 				huh.NewConfirm().
 					Title("Approval required?").
 					Affirmative("Yes!").
@@ -262,34 +283,39 @@ func CreatePipeline(name, repoType string) error {
 					Affirmative("Yes!").
 					Negative("No.").
 					Value(&datadogMonitoring),
+				huh.NewConfirm().
+					Title("Prometheus monitoring?").
+					Affirmative("Yes!").
+					Negative("No.").
+					Value(&prometheusMonitoring),
+				huh.NewConfirm().
+					Title("HTTP health check monitoring?").
+					Affirmative("Yes!").
+					Negative("No.").
+					Value(&httpMonitoring),
 			)).Run(); err != nil {
 			return err
 		}
 
 		if datadogMonitoring {
-			var monitorIds, apiKey, applicationKey string
-			var rollback bool
+			var monitorIds, apiKey, applicationKey, groups string
+			var rollback, events bool
 			site := "datadoghq.com"
+			failureStates := []string{DATADOG_ALERT}
 			if err := huh.NewForm(
 				huh.NewGroup(
 					huh.NewNote().
 						Title(fmt.Sprintf("Datadog setup %s", name)).
-						Description("provide monitor ids, api and application key"),
+						Description("provide monitor ids, api and application key, monitor ids are optional when events are posted"),
 					huh.NewInput().
-						Title("Monitor ids? eg: monitor_id1,monitor_id2").
-						Value(&monitorIds).
-						Validate(func(t string) error {
-							if t == "" {
-								return errors.New("monitor Ids cannot be empty")
-							}
-							return nil
-						}),
+						Title("Monitor ids? leave empty to only post events eg: monitor_id1,monitor_id2").
+						Value(&monitorIds),
 					huh.NewInput().
 						Title("Site").
 						Value(&site).
 						Placeholder("datadoghq.com"),
 					huh.NewInput().
-						Title("API Key").
+						Title("API Key, secret:NAME or env:VAR, keys are saved encrypted as secrets").
 						Value(&apiKey).
 						Validate(func(t string) error {
 							if t == "" {
@@ -298,7 +324,7 @@ func CreatePipeline(name, repoType string) error {
 							return nil
 						}),
 					huh.NewInput().
-						Title("Application Key").
+						Title("Application Key, secret:NAME or env:VAR, keys are saved encrypted as secrets").
 						Value(&applicationKey).
 						Validate(func(t string) error {
 							if t == "" {
@@ -306,6 +332,84 @@ func CreatePipeline(name, repoType string) error {
 							}
 							return nil
 						}),
+					huh.NewInput().
+						Title("Groups to evaluate instead of overall state? leave empty to skip eg: env:prod;env:prod,service:${{ inputs.service }}").
+						Value(&groups),
+					huh.NewMultiSelect[string]().
+						Title("Failure states").
+						Options(huh.NewOptions(DatadogStates...)...).
+						Value(&failureStates),
+					huh.NewConfirm().
+						Title("Rollback on failure?").
+						Affirmative("Yes!").
+						Negative("No.").
+						Value(&rollback),
+					huh.NewConfirm().
+						Title("Post datadog events when stage is dispatched, completes or rolls back?").
+						Affirmative("Yes!").
+						Negative("No.").
+						Value(&events),
+				)).Run(); err != nil {
+				return err
+			}
+			var ids []string
+			for _, id := range strings.Split(monitorIds, ",") {
+				if strings.TrimSpace(id) != "" {
+					ids = append(ids, strings.TrimSpace(id))
+				}
+			}
+			stage.Monitor.Datadog = &DatadogInfo{Monitors: ids, Site: site, ApiKey: apiKey, ApplicationKey: applicationKey, Rollback: rollback, FailureStates: failureStates, Events: events}
+			if groups != "" {
+				stage.Monitor.Datadog.Groups = strings.Split(groups, ";")
+			}
+			if err := stage.Monitor.Datadog.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if prometheusMonitoring {
+			var prometheusUrl, query, threshold string
+			var rollback bool
+			comparison := ">"
+			window := "15m"
+			if err := huh.NewForm(
+				huh.NewGroup(
+					huh.NewNote().
+						Title(fmt.Sprintf("Prometheus setup %s", name)).
+						Description("stage fails when query value compared with threshold is true"),
+					huh.NewInput().
+						Title("Prometheus url eg: http://prometheus:9090").
+						Value(&prometheusUrl).
+						Validate(func(t string) error {
+							if t == "" {
+								return errors.New("prometheus url cannot be empty")
+							}
+							return nil
+						}),
+					huh.NewInput().
+						Title("PromQL query, $window is replaced with window").
+						Value(&query).
+						Validate(func(t string) error {
+							if t == "" {
+								return errors.New("query cannot be empty")
+							}
+							return nil
+						}),
+					huh.NewSelect[string]().
+						Title("Comparison").
+						Options(huh.NewOptions(PrometheusComparisons...)...).
+						Value(&comparison),
+					huh.NewInput().
+						Title("Threshold").
+						Value(&threshold).
+						Validate(func(t string) error {
+							_, err := strconv.ParseFloat(t, 64)
+							return err
+						}),
+					huh.NewInput().
+						Title("Window").
+						Value(&window).
+						Placeholder("15m"),
 					huh.NewConfirm().
 						Title("Rollback on failure?").
 						Affirmative("Yes!").
@@ -314,8 +418,78 @@ func CreatePipeline(name, repoType string) error {
 				)).Run(); err != nil {
 				return err
 			}
-			ids := strings.Split(monitorIds, ",")
-			stage.Monitor.Datadog = &DatadogInfo{Monitors: ids, Site: site, ApiKey: apiKey, ApplicationKey: applicationKey, Rollback: rollback}
+			thresholdValue, err := strconv.ParseFloat(threshold, 64)
+			if err != nil {
+				return err
+			}
+			stage.Monitor.Prometheus = &PrometheusInfo{
+				Url:      prometheusUrl,
+				Queries:  []PrometheusQuery{{Query: query, Comparison: comparison, Threshold: thresholdValue}},
+				Window:   window,
+				Rollback: rollback,
+			}
+			if err := stage.Monitor.Prometheus.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if httpMonitoring {
+			var urls, statusCodes, jsonPath, expected string
+			var rollback bool
+			if err := huh.NewForm(
+				huh.NewGroup(
+					huh.NewNote().
+						Title(fmt.Sprintf("HTTP health check setup %s", name)).
+						Description("urls are checked every 10s, stage fails after 3 consecutive failures"),
+					huh.NewInput().
+						Title("Urls? eg: https://service/healthz,https://service/version").
+						Value(&urls).
+						Validate(func(t string) error {
+							if t == "" {
+								return errors.New("urls cannot be empty")
+							}
+							return nil
+						}),
+					huh.NewInput().
+						Title("Expected status codes? eg: 200,204").
+						Value(&statusCodes).
+						Placeholder("200"),
+					huh.NewInput().
+						Title("JSONPath assertion on last url? leave empty to skip eg: $.version").
+						Value(&jsonPath),
+					huh.NewInput().
+						Title("Expected value at JSONPath? eg: ${{ inputs.version }}").
+						Value(&expected),
+					huh.NewConfirm().
+						Title("Rollback on failure?").
+						Affirmative("Yes!").
+						Negative("No.").
+						Value(&rollback),
+				)).Run(); err != nil {
+				return err
+			}
+			var codes []int
+			for _, statusCode := range strings.Split(statusCodes, ",") {
+				if strings.TrimSpace(statusCode) == "" {
+					continue
+				}
+				code, err := strconv.Atoi(strings.TrimSpace(statusCode))
+				if err != nil {
+					return fmt.Errorf("invalid status code %s", statusCode)
+				}
+				codes = append(codes, code)
+			}
+			stage.Monitor.Http = &HttpInfo{Rollback: rollback}
+			for _, checkUrl := range strings.Split(urls, ",") {
+				stage.Monitor.Http.Checks = append(stage.Monitor.Http.Checks, HttpCheck{Url: strings.TrimSpace(checkUrl), StatusCodes: codes})
+			}
+			// assertion applies to the last url eg: version endpoint
+			lastCheck := &stage.Monitor.Http.Checks[len(stage.Monitor.Http.Checks)-1]
+			lastCheck.JsonPath = jsonPath
+			lastCheck.Expected = expected
+			if err := stage.Monitor.Http.Validate(); err != nil {
+				return err
+			}
 		}
 
 		stage.Input = make(map[string]string)
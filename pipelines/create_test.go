@@ -56,6 +56,15 @@ func (t *createGithubClient) ListWorkflows(org, repo string) ([]github.Workflow,
 func (t *createGithubClient) ListWorkflowRuns(org, repo string, workflowID int64, created string) ([]github.WorkflowRun, error) {
 	return nil, nil
 }
+func (t *createGithubClient) FindWorkflowRun(org, repo string, workflowID int64, created, match string) (*github.WorkflowRun, error) {
+	return nil, nil
+}
+func (t *createGithubClient) CurrentRateLimit() *github.RateLimit {
+	return nil
+}
+func (t *createGithubClient) ListRateLimits() ([]github.RateLimit, error) {
+	return nil, nil
+}
 func (t *createGithubClient) CreateWorkflowDispatch(org, repo string, workflowID int64, ref string, inputs map[string]interface{}) error {
 	return nil
 }
@@ -68,6 +77,30 @@ func (t *createGithubClient) ValidateWorkflowFull(org, repo, path string) (strin
 	return "", "", nil
 }
 
+func (t *createGithubClient) ListWorkflowJobs(org, repo string, runID int64) ([]github.WorkflowJob, error) {
+	return nil, nil
+}
+
+func (t *createGithubClient) GetWorkflowRunLogs(org, repo string, runID int64) ([]github.WorkflowLog, error) {
+	return nil, nil
+}
+
+func (t *createGithubClient) GetWorkflowJobLogs(org, repo string, jobID int64) (string, error) {
+	return "", nil
+}
+
+func (t *createGithubClient) ListWorkflowInputs(org, repo, path string) ([]github.WorkflowInput, error) {
+	return nil, nil
+}
+
+func (t *createGithubClient) FindPullRequest(org, repo, branch string) (*github.PullRequest, error) {
+	return nil, nil
+}
+
+func (t *createGithubClient) CreatePullRequest(org, repo, branch, title, body string, files map[string]string) (*github.PullRequest, error) {
+	return nil, nil
+}
+
 func (t *createGithubClient) GetWorkflow(org, repo string, id int64) (*github.Workflow, error) {
 	return nil, nil
 }
@@ -76,6 +109,14 @@ func (t *createGithubClient) ListOrgsForUser() ([]github.Org, error) {
 	return nil, nil
 }
 
+func (t *createGithubClient) CreateDeployment(org, repo, ref, environment, description string, payload map[string]interface{}) (int64, error) {
+	return 0, nil
+}
+
+func (t *createGithubClient) CreateDeploymentStatus(org, repo string, deploymentID int64, state, environment, logUrl, description string) error {
+	return nil
+}
+
 func TestGetRepos(t *testing.T) {
 	github.DefaultClient = &createGithubClient{}
 
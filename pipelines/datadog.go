@@ -0,0 +1,300 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/secrets"
+
+	"github.com/nixmade/orchestrator/core"
+)
+
+const (
+	DATADOG_ALERT   = "Alert"
+	DATADOG_WARN    = "Warn"
+	DATADOG_NO_DATA = "No Data"
+)
+
+var (
+	DatadogStates = []string{DATADOG_ALERT, DATADOG_WARN, DATADOG_NO_DATA, "OK", "Ignored", "Skipped", "Unknown"}
+
+	// datadogApiUrl returns api base url for datadog site
+	datadogApiUrl = func(site string) string {
+		return fmt.Sprintf("https://api.%s", site)
+	}
+)
+
+type MonitoringController struct {
+	*DatadogInfo
+	// ResolvedGroups are Groups templated with run inputs
+	ResolvedGroups []string `json:"resolved_groups,omitempty"`
+}
+
+func newDatadogMonitoringController(info *DatadogInfo, inputs map[string]string) *MonitoringController {
+	controller := &MonitoringController{DatadogInfo: info}
+	for _, group := range info.Groups {
+		controller.ResolvedGroups = append(controller.ResolvedGroups, templateInputs(group, inputs))
+	}
+	return controller
+}
+
+type MonitorGroup struct {
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	LastTriggeredTs int64  `json:"last_triggered_ts"`
+}
+
+type MonitorState struct {
+	Name                 string `json:"name"`
+	OverallState         string `json:"overall_state"`
+	OverallStateModified string `json:"overall_state_modified"`
+	State                struct {
+		Groups map[string]MonitorGroup `json:"groups"`
+	} `json:"state"`
+}
+
+// MonitorFailure is the monitor state which failed the stage
+type MonitorFailure struct {
+	Monitor string `json:"monitor"`
+	Name    string `json:"name,omitempty"`
+	Group   string `json:"group,omitempty"`
+	State   string `json:"state"`
+	Since   string `json:"since,omitempty"`
+}
+
+type monitorError struct {
+	failure MonitorFailure
+}
+
+func (e *monitorError) Error() string {
+	monitor := e.failure.Monitor
+	if e.failure.Name != "" {
+		monitor = fmt.Sprintf("%s(%s)", e.failure.Monitor, e.failure.Name)
+	}
+	group := ""
+	if e.failure.Group != "" {
+		group = fmt.Sprintf(" group %s", e.failure.Group)
+	}
+	return fmt.Sprintf("%v, monitor %s%s in %s state since %s", ErrMonitoringFailed, monitor, group, e.failure.State, e.failure.Since)
+}
+
+func (e *monitorError) Unwrap() error {
+	return ErrMonitoringFailed
+}
+
+func (m *MonitoringController) failureStates() []string {
+	if len(m.FailureStates) <= 0 {
+		return []string{DATADOG_ALERT}
+	}
+	return m.FailureStates
+}
+
+func (m *MonitoringController) isFailure(state string) bool {
+	return slices.ContainsFunc(m.failureStates(), func(failureState string) bool {
+		return strings.EqualFold(failureState, state)
+	})
+}
+
+// groupMatches returns true if group has every tag of any resolved group, eg: env:prod matches env:prod,service:api
+func (m *MonitoringController) groupMatches(group string) bool {
+	groupTags := strings.Split(group, ",")
+	for _, resolvedGroup := range m.ResolvedGroups {
+		matches := true
+		for _, tag := range strings.Split(resolvedGroup, ",") {
+			if !slices.Contains(groupTags, strings.TrimSpace(tag)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks monitor ids, keys and failure states, monitor ids are optional when only events are posted
+func (d *DatadogInfo) Validate() error {
+	if len(d.Monitors) <= 0 && !d.Events {
+		return fmt.Errorf("datadog monitor ids cannot be empty unless events are enabled")
+	}
+	for _, monitor := range d.Monitors {
+		if _, err := strconv.ParseInt(strings.TrimSpace(monitor), 10, 64); err != nil {
+			return fmt.Errorf("invalid datadog monitor id %s", monitor)
+		}
+	}
+	if d.ApiKey == "" || d.ApplicationKey == "" {
+		return fmt.Errorf("datadog api and application keys cannot be empty")
+	}
+	for _, state := range d.FailureStates {
+		if !slices.ContainsFunc(DatadogStates, func(datadogState string) bool { return strings.EqualFold(datadogState, state) }) {
+			return fmt.Errorf("invalid datadog state %s, choose from %s", state, strings.Join(DatadogStates, ","))
+		}
+	}
+	return nil
+}
+
+func (m *MonitoringController) ExternalMonitoring(targets []*core.ClientState) error {
+	// nothing is deployed until workflow is dispatched, monitors in alert before that are not caused by this run
+	if len(targets) <= 0 {
+		return nil
+	}
+
+	// credentials are resolved only while monitoring, pipeline holds secret or env references
+	apiKey, err := secrets.Resolve(context.Background(), m.ApiKey)
+	if err != nil {
+		return fmt.Errorf("failed to resolve datadog api key, %v", err)
+	}
+	applicationKey, err := secrets.Resolve(context.Background(), m.ApplicationKey)
+	if err != nil {
+		return fmt.Errorf("failed to resolve datadog application key, %v", err)
+	}
+
+	for _, monitor := range m.Monitors {
+		monitorID, err := strconv.ParseInt(monitor, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse monitor %s", monitor)
+		}
+
+		url := fmt.Sprintf("%s/api/v1/monitor/%d?group_states=all&with_downtimes=true", datadogApiUrl(m.Site), monitorID)
+		headers := map[string]string{
+			"Accept":             "application/json",
+			"DD-API-KEY":         apiKey,
+			"DD-APPLICATION-KEY": applicationKey,
+		}
+		response, err := helpers.HttpGet(url, headers)
+		if err != nil {
+			return fmt.Errorf("received error from datadog %s", err)
+		}
+
+		state := MonitorState{}
+		if err := json.Unmarshal([]byte(response), &state); err != nil {
+			return fmt.Errorf("failed to unmarshal datadog response %s", err)
+		}
+
+		if len(m.ResolvedGroups) <= 0 {
+			if m.isFailure(state.OverallState) {
+				return &monitorError{failure: MonitorFailure{Monitor: monitor, Name: state.Name, State: state.OverallState, Since: state.OverallStateModified}}
+			}
+			continue
+		}
+
+		groupNames := make([]string, 0, len(state.State.Groups))
+		for groupName := range state.State.Groups {
+			groupNames = append(groupNames, groupName)
+		}
+		slices.Sort(groupNames)
+
+		for _, groupName := range groupNames {
+			group := state.State.Groups[groupName]
+			if !m.groupMatches(groupName) || !m.isFailure(group.Status) {
+				continue
+			}
+			since := ""
+			if group.LastTriggeredTs > 0 {
+				since = time.Unix(group.LastTriggeredTs, 0).UTC().Format(time.RFC3339)
+			}
+			return &monitorError{failure: MonitorFailure{Monitor: monitor, Name: state.Name, Group: groupName, State: group.Status, Since: since}}
+		}
+	}
+
+	return nil
+}
+
+// monitoring datadog monitors configured, datadog can be configured only for events
+func (d *DatadogInfo) monitoring() bool {
+	return d != nil && len(d.Monitors) > 0
+}
+
+const (
+	DATADOG_EVENT_INFO    = "info"
+	DATADOG_EVENT_SUCCESS = "success"
+	DATADOG_EVENT_WARNING = "warning"
+	DATADOG_EVENT_ERROR   = "error"
+)
+
+type DatadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Tags           []string `json:"tags"`
+	AlertType      string   `json:"alert_type"`
+	AggregationKey string   `json:"aggregation_key"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+}
+
+func (o *orchestrator) datadogEvent(stage Stage, currentRun *run, title, alertType string) DatadogEvent {
+	inputs := o.inputs
+	if o.rollback != nil {
+		inputs = o.rollback.inputs
+	}
+	version := inputs["version"]
+	if version == "" {
+		version = o.targetVersion
+	}
+
+	text := []string{title, "Pipeline run " + PipelineRunUrl(o.pipeline.Name, o.pipelineRunId)}
+	if currentRun.runUrl != "" {
+		text = append(text, "Workflow run "+currentRun.runUrl)
+	}
+	if currentRun.reason != "" {
+		text = append(text, "Reason "+currentRun.reason)
+	}
+
+	return DatadogEvent{
+		Title: title,
+		Text:  strings.Join(text, "\n"),
+		Tags: []string{
+			"pipeline:" + o.pipeline.Name,
+			"pippy_run_id:" + o.pipelineRunId,
+			"stage:" + stage.Workflow.Name,
+			"version:" + version,
+			"repo:" + stage.Repo,
+		},
+		AlertType:      alertType,
+		AggregationKey: o.pipelineRunId,
+		SourceTypeName: APP_NAME,
+	}
+}
+
+// postDatadogEvent posts stage event asynchronously when enabled, failures are only logged
+func (o *orchestrator) postDatadogEvent(i int, stage Stage, currentRun *run, title, alertType string) {
+	datadog := stage.Monitor.Datadog
+	if datadog == nil || !datadog.Events || currentRun == nil {
+		return
+	}
+
+	logger := o.logger.With().Str("Stage", getStageName(i, stage.Workflow.Name)).Str("Event", title).Logger()
+	event := o.datadogEvent(stage, currentRun, title, alertType)
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to marshal datadog event")
+		return
+	}
+
+	url := fmt.Sprintf("%s/api/v1/events", datadogApiUrl(datadog.Site))
+
+	o.notifications.Add(1)
+	go func() {
+		defer o.notifications.Done()
+		apiKey, err := secrets.Resolve(context.Background(), datadog.ApiKey)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to resolve datadog api key")
+			return
+		}
+		headers := map[string]string{
+			"Accept":     "application/json",
+			"DD-API-KEY": apiKey,
+		}
+		if _, err := helpers.HttpPostJSON(url, body, headers); err != nil {
+			logger.Error().Err(err).Msg("failed to post datadog event")
+			return
+		}
+		logger.Info().Msg("posted datadog event")
+	}()
+}
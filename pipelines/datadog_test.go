@@ -0,0 +1,297 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/google/uuid"
+	"github.com/nixmade/orchestrator/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const datadogMonitorResponse = `{
+  "id": 1234,
+  "name": "High error rate",
+  "overall_state": "Alert",
+  "overall_state_modified": "2024-01-08T10:15:30+00:00",
+  "state": {
+    "groups": {
+      "env:dev,service:api": {"name": "env:dev,service:api", "status": "Alert", "last_triggered_ts": 1704708930},
+      "env:prod,service:api": {"name": "env:prod,service:api", "status": "%s", "last_triggered_ts": 1704708930},
+      "env:prod,service:web": {"name": "env:prod,service:web", "status": "OK", "last_triggered_ts": 0}
+    }
+  }
+}`
+
+func setupDatadog(t *testing.T, prodStatus *string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/monitor/1234" || r.URL.Query().Get("group_states") != "all" || r.Header.Get("DD-API-KEY") != "api" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, datadogMonitorResponse, *prodStatus)
+	}))
+	t.Cleanup(server.Close)
+
+	apiUrl := datadogApiUrl
+	datadogApiUrl = func(site string) string {
+		return server.URL
+	}
+	t.Cleanup(func() {
+		datadogApiUrl = apiUrl
+	})
+}
+
+func TestDatadogMonitoringGroups(t *testing.T) {
+	prodStatus := "OK"
+	setupDatadog(t, &prodStatus)
+
+	info := &DatadogInfo{Monitors: []string{"1234"}, ApiKey: "api", ApplicationKey: "app"}
+
+	// monitors are not evaluated before anything is deployed
+	require.NoError(t, newDatadogMonitoringController(info, nil).ExternalMonitoring(nil))
+
+	// overall state is evaluated without groups
+	targets := []*core.ClientState{{Name: "org1/repo1/0/1234"}}
+	err := newDatadogMonitoringController(info, nil).ExternalMonitoring(targets)
+	require.ErrorIs(t, err, ErrMonitoringFailed)
+	var monitorErr *monitorError
+	require.ErrorAs(t, err, &monitorErr)
+	assert.Equal(t, MonitorFailure{Monitor: "1234", Name: "High error rate", State: "Alert", Since: "2024-01-08T10:15:30+00:00"}, monitorErr.failure)
+
+	// dev alert does not fail production
+	info.Groups = []string{"env:${{ inputs.env }}"}
+	controller := newDatadogMonitoringController(info, map[string]string{"env": "prod"})
+	assert.Equal(t, []string{"env:prod"}, controller.ResolvedGroups)
+	require.NoError(t, controller.ExternalMonitoring(targets))
+
+	// warn is ignored unless configured
+	prodStatus = "Warn"
+	require.NoError(t, controller.ExternalMonitoring(targets))
+
+	info.FailureStates = []string{DATADOG_ALERT, DATADOG_WARN, DATADOG_NO_DATA}
+	require.NoError(t, info.Validate())
+	err = controller.ExternalMonitoring(targets)
+	require.ErrorAs(t, err, &monitorErr)
+	assert.Equal(t, MonitorFailure{Monitor: "1234", Name: "High error rate", Group: "env:prod,service:api", State: "Warn", Since: "2024-01-08T10:15:30Z"}, monitorErr.failure)
+	assert.Contains(t, err.Error(), "monitor 1234(High error rate) group env:prod,service:api in Warn state")
+
+	prodStatus = "No Data"
+	err = controller.ExternalMonitoring(targets)
+	require.ErrorAs(t, err, &monitorErr)
+	assert.Equal(t, "No Data", monitorErr.failure.State)
+
+	// every tag of a group must match
+	info.Groups = []string{"env:prod,service:web"}
+	require.NoError(t, newDatadogMonitoringController(info, nil).ExternalMonitoring(targets))
+
+	info.FailureStates = []string{"Critical"}
+	require.Error(t, info.Validate())
+
+	info.FailureStates = nil
+	info.Monitors = []string{"monitor1"}
+	require.ErrorContains(t, info.Validate(), "invalid datadog monitor id monitor1")
+	info.Monitors = []string{"1234"}
+	info.ApiKey = ""
+	require.ErrorContains(t, info.Validate(), "keys cannot be empty")
+
+	// events only
+	eventsOnly := &DatadogInfo{ApiKey: "api", ApplicationKey: "app", Events: true}
+	require.NoError(t, eventsOnly.Validate())
+	assert.Nil(t, stageMonitoringController(Stage{Monitor: MonitorInfo{Datadog: eventsOnly}}, "", nil))
+	eventsOnly.Events = false
+	require.ErrorContains(t, eventsOnly.Validate(), "monitor ids cannot be empty unless events are enabled")
+}
+
+func TestOrchestrateDatadogMonitorFailure(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateDatadogMonitorFailure*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
+	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
+
+	prodStatus := "Alert"
+	setupDatadog(t, &prodStatus)
+
+	o.pipeline = &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Monitor:  MonitorInfo{Datadog: &DatadogInfo{Monitors: []string{"1234"}, ApiKey: "api", Groups: []string{"env:prod"}}},
+				Input:    map[string]string{"version": ""}},
+		},
+	}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, o.pipeline.Stages[0]))
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	o.githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed", Conclusion: "success"}}, afterDispatch: true}
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+
+	assert.Equal(t, FAILED, o.stageStatus.GetState())
+	pipelineRun, err := GetPipelineRun(context.Background(), o.pipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+	assert.Equal(t, "Failed", pipelineRun.Stages[0].State)
+	require.NotNil(t, pipelineRun.Stages[0].MonitorFailure)
+	assert.Equal(t, "env:prod,service:api", pipelineRun.Stages[0].MonitorFailure.Group)
+	assert.Equal(t, "Alert", pipelineRun.Stages[0].MonitorFailure.State)
+}
+
+type datadogEvents struct {
+	lock   sync.Mutex
+	events []DatadogEvent
+}
+
+func (d *datadogEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if r.URL.Path != "/api/v1/events" || r.Header.Get("DD-API-KEY") != "api" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	event := DatadogEvent{}
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d.events = append(d.events, event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (d *datadogEvents) get(title string) DatadogEvent {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, event := range d.events {
+		if event.Title == title {
+			return event
+		}
+	}
+	return DatadogEvent{}
+}
+
+func (d *datadogEvents) titles() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var titles []string
+	for _, event := range d.events {
+		titles = append(titles, event.Title)
+	}
+	return titles
+}
+
+func TestOrchestrateDatadogEvents(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateDatadogEvents*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
+	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
+
+	events := &datadogEvents{}
+	server := httptest.NewServer(events)
+	defer server.Close()
+
+	apiUrl := datadogApiUrl
+	datadogApiUrl = func(site string) string {
+		return server.URL
+	}
+	defer func() {
+		datadogApiUrl = apiUrl
+	}()
+
+	o.pipeline = &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Monitor: MonitorInfo{
+					Workflow: WorkflowInfo{Rollback: true},
+					Datadog:  &DatadogInfo{ApiKey: "api", Events: true},
+				},
+				Input: map[string]string{"version": ""}},
+		},
+	}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, o.pipeline.Stages[0]))
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	o.githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed"}}, afterDispatch: true}
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+	require.Equal(t, SUCCESS, o.stageStatus.GetState())
+	o.waitNotifications()
+
+	assert.ElementsMatch(t, []string{"Pippy Pipeline1 stage Workflow1 dispatched", "Pippy Pipeline1 stage Workflow1 completed"}, events.titles())
+	assert.ElementsMatch(t, []string{"pipeline:Pipeline1", "pippy_run_id:" + o.pipelineRunId, "stage:Workflow1", "version:dummy2", "repo:org1/repo1"}, events.get("Pippy Pipeline1 stage Workflow1 dispatched").Tags)
+	assert.Equal(t, DATADOG_EVENT_SUCCESS, events.get("Pippy Pipeline1 stage Workflow1 completed").AlertType)
+
+	// failed rollout posts failure and rollback events
+	prevRunId := o.pipelineRunId
+	runId := uuid.New().String()
+	o.pipelineRunId = runId
+	o.targetVersion = runId
+	o.stageStatus = &status{m: make(map[string]*run)}
+	o.inputs = map[string]string{"version": "dummy4"}
+	events.events = nil
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, o.pipeline.Stages[0]))
+	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	o.githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed", Conclusion: "failure"}}, afterDispatch: true, stageStatus: o.stageStatus}
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+	o.waitNotifications()
+
+	assert.ElementsMatch(t, []string{
+		"Pippy Pipeline1 stage Workflow1 dispatched",
+		"Pippy Pipeline1 stage Workflow1 dispatched rollback to " + prevRunId,
+		"Pippy Pipeline1 stage Workflow1 rolled back to " + prevRunId,
+		"Pippy Pipeline1 stage Workflow1 rollout failed, rolled back to " + prevRunId,
+	}, events.titles())
+	assert.Contains(t, events.get("Pippy Pipeline1 stage Workflow1 dispatched rollback to "+prevRunId).Tags, "version:dummy2")
+	assert.Equal(t, DATADOG_EVENT_WARNING, events.get("Pippy Pipeline1 stage Workflow1 rolled back to "+prevRunId).AlertType)
+	assert.Equal(t, DATADOG_EVENT_ERROR, events.get("Pippy Pipeline1 stage Workflow1 rollout failed, rolled back to "+prevRunId).AlertType)
+}
+
+func TestDatadogEventFailureIgnored(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	apiUrl := datadogApiUrl
+	datadogApiUrl = func(site string) string {
+		return "http://127.0.0.1:0"
+	}
+	defer func() {
+		datadogApiUrl = apiUrl
+	}()
+
+	stage := Stage{Repo: "org1/repo1", Workflow: github.Workflow{Name: "Workflow1"}, Monitor: MonitorInfo{Datadog: &DatadogInfo{ApiKey: "api", Events: true}}}
+	o.postDatadogEvent(0, stage, &run{}, "Pippy Pipeline1 stage Workflow1 dispatched", DATADOG_EVENT_INFO)
+	o.waitNotifications()
+}
@@ -9,6 +9,14 @@ import (
 )
 
 func DeletePipeline(ctx context.Context, name string) error {
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+
 	// confirm here that users intention was to delete
 	dbStore, err := store.Get(ctx)
 	if err != nil {
@@ -31,7 +39,8 @@ func DeletePipeline(ctx context.Context, name string) error {
 		return err
 	}
 
-	return nil
+	// credentials moved into secrets are deleted with pipeline
+	return deleteOwnedSecrets(ctx, pipeline, nil)
 }
 
 func DeletePipelineRun(ctx context.Context, name, id string) error {
@@ -0,0 +1,70 @@
+package pipelines
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DEPLOYMENT_IN_PROGRESS = "in_progress"
+	DEPLOYMENT_SUCCESS     = "success"
+	DEPLOYMENT_FAILURE     = "failure"
+	DEPLOYMENT_ERROR       = "error"
+
+	// github limits deployment and status descriptions to 140 characters
+	deploymentDescriptionLimit = 140
+)
+
+func deploymentDescription(description string) string {
+	if len(description) > deploymentDescriptionLimit {
+		return description[:deploymentDescriptionLimit-3] + "..."
+	}
+	return description
+}
+
+// createDeployment creates a github deployment for stages with an environment, failures are only logged
+func (o *orchestrator) createDeployment(i int, stage Stage, currentRun *run, ref string) {
+	if stage.Environment == "" {
+		return
+	}
+
+	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
+	logger := o.logger.With().Str("Stage", getStageName(i, stage.Workflow.Name)).Str("Environment", stage.Environment).Logger()
+
+	description := fmt.Sprintf("pippy pipeline %s run %s", o.pipeline.Name, o.pipelineRunId)
+	if o.rollback != nil {
+		description = fmt.Sprintf("pippy pipeline %s run %s rollback to %s", o.pipeline.Name, o.pipelineRunId, o.targetVersion)
+	}
+	payload := map[string]interface{}{
+		"pipeline":        o.pipeline.Name,
+		"pipeline_run_id": o.pipelineRunId,
+		"pippy_run_id":    currentRun.runId,
+		"pipeline_url":    PipelineRunUrl(o.pipeline.Name, o.pipelineRunId),
+	}
+
+	deploymentId, err := o.githubClient.CreateDeployment(orgRepoSlice[0], orgRepoSlice[1], ref, stage.Environment, deploymentDescription(description), payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create github deployment")
+		return
+	}
+	currentRun.deploymentId = deploymentId
+	logger.Info().Int64("DeploymentId", deploymentId).Msg("created github deployment")
+
+	o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_IN_PROGRESS, "workflow dispatched")
+}
+
+// setDeploymentStatus updates github deployment created for stage run, failures are only logged
+func (o *orchestrator) setDeploymentStatus(i int, stage Stage, currentRun *run, state, description string) {
+	if stage.Environment == "" || currentRun == nil || currentRun.deploymentId == 0 {
+		return
+	}
+
+	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
+	logger := o.logger.With().Str("Stage", getStageName(i, stage.Workflow.Name)).Str("Environment", stage.Environment).Int64("DeploymentId", currentRun.deploymentId).Str("DeploymentState", state).Logger()
+
+	if err := o.githubClient.CreateDeploymentStatus(orgRepoSlice[0], orgRepoSlice[1], currentRun.deploymentId, state, stage.Environment, currentRun.runUrl, deploymentDescription(description)); err != nil {
+		logger.Error().Err(err).Msg("failed to create github deployment status")
+		return
+	}
+	logger.Info().Msg("updated github deployment status")
+}
@@ -0,0 +1,216 @@
+package pipelines
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+	"github.com/nixmade/pippy/store"
+	"github.com/nixmade/pippy/users"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	e2eWorkflow = `name: Deploy
+run-name: Deploy ${{ inputs.version }} - ${{ inputs.pippy_run_id }}
+on:
+  workflow_dispatch:
+    inputs:
+      version:
+        type: string
+      pippy_run_id:
+        type: string
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo deploying ${{ inputs.version }}
+`
+)
+
+type e2eSetup struct {
+	server    *githubtest.Server
+	workflows []*githubtest.Workflow
+	ctx       context.Context
+}
+
+// setupE2E fake github with org1/repo1 deploy workflows and a pipeline using them
+func setupE2E(t *testing.T, stages []Stage) *e2eSetup {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestE2E*")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	})
+	store.HomeDir = tempDir
+	t.Cleanup(holdStore(t))
+	skipRolloutSettle(t)
+
+	tickInterval := TickInterval
+	TickInterval = 10
+	t.Cleanup(func() {
+		TickInterval = tickInterval
+	})
+
+	server := githubtest.NewServer(t)
+	server.AddRepo("org1/repo1", true)
+
+	setup := &e2eSetup{server: server, ctx: context.Background()}
+	setup.ctx = context.WithValue(setup.ctx, users.NameCtx, "approver1")
+	setup.ctx = context.WithValue(setup.ctx, users.EmailCtx, "approver1@example.com")
+	setup.ctx = context.WithValue(setup.ctx, users.SourceCtx, users.SOURCE_USER)
+
+	// stage workflows are discovered through github api like pipeline create does
+	for i := range stages {
+		workflow, err := server.AddWorkflow("org1/repo1", ".github/workflows/deploy"+string(rune('a'+i))+".yml", e2eWorkflow, githubtest.Steps(githubtest.Succeeds...))
+		require.NoError(t, err)
+		setup.workflows = append(setup.workflows, workflow)
+	}
+
+	workflows, err := GetWorkflows("org1/repo1")
+	require.NoError(t, err)
+	require.Len(t, workflows, len(stages))
+	for i := range stages {
+		stages[i].Repo = "org1/repo1"
+		stages[i].Workflow = workflows[i]
+		require.NoError(t, ValidateWorkflow("org1/repo1", workflows[i]))
+	}
+
+	require.NoError(t, SavePipeline(setup.ctx, &Pipeline{Name: "E2E", Stages: stages}))
+	return setup
+}
+
+func (s *e2eSetup) run(t *testing.T, runId string, inputs map[string]string) *PipelineRun {
+	require.NoError(t, RunPipeline(s.ctx, "E2E", runId, inputs, nil, TriggerMetadata{Name: "e2e", Reason: "end to end test"}, false))
+	pipelineRun, err := GetPipelineRun(s.ctx, "E2E", runId)
+	require.NoError(t, err)
+	return pipelineRun
+}
+
+func TestE2ERunPipeline(t *testing.T) {
+	setup := setupE2E(t, []Stage{
+		{Input: map[string]string{"version": ""}, Environment: "staging"},
+		{Input: map[string]string{"version": ""}},
+	})
+
+	runId := uuid.NewString()
+	pipelineRun := setup.run(t, runId, map[string]string{"version": "v1"})
+	assert.Equal(t, string(SUCCESS), pipelineRun.State)
+
+	for i, workflow := range setup.workflows {
+		runs := setup.server.Runs(workflow)
+		require.Len(t, runs, 1)
+		assert.Equal(t, "main", runs[0].Ref)
+		assert.Equal(t, "v1", runs[0].Inputs["version"])
+		assert.Equal(t, "Deploy v1 - "+pipelineRun.Stages[i].RunId, runs[0].Name)
+		assert.Equal(t, "completed", runs[0].Step().Status)
+		assert.Equal(t, runs[0].Name, pipelineRun.Stages[i].Title)
+	}
+
+	deployments := setup.server.Deployments("org1/repo1")
+	require.Len(t, deployments, 1)
+	assert.Equal(t, "staging", deployments[0].Environment)
+	require.NotEmpty(t, deployments[0].Statuses)
+	assert.Equal(t, DEPLOYMENT_SUCCESS, deployments[0].Statuses[len(deployments[0].Statuses)-1].State)
+}
+
+func TestE2EApproval(t *testing.T) {
+	setup := setupE2E(t, []Stage{
+		{Input: map[string]string{"version": ""}},
+		{Input: map[string]string{"version": ""}, Approval: true},
+	})
+
+	runId := uuid.NewString()
+	pipelineRun := setup.run(t, runId, map[string]string{"version": "v1"})
+	assert.Equal(t, string(PENDING_APPROVAL), pipelineRun.State)
+	assert.Len(t, setup.server.Runs(setup.workflows[0]), 1)
+	assert.Empty(t, setup.server.Runs(setup.workflows[1]))
+
+	require.NoError(t, ApprovePipelineRun(setup.ctx, "E2E", runId, 1))
+
+	pipelineRun = setup.run(t, runId, map[string]string{"version": "v1"})
+	assert.Equal(t, string(SUCCESS), pipelineRun.State)
+	assert.Equal(t, "approver1", pipelineRun.Stages[1].Metadata.Approval.Name)
+	assert.Len(t, setup.server.Runs(setup.workflows[0]), 1)
+	assert.Len(t, setup.server.Runs(setup.workflows[1]), 1)
+}
+
+func TestE2ERollback(t *testing.T) {
+	setup := setupE2E(t, []Stage{
+		{Input: map[string]string{"version": ""}, Monitor: MonitorInfo{Workflow: WorkflowInfo{Rollback: true}}},
+	})
+	workflow := setup.workflows[0]
+
+	// last known good version
+	pipelineRun := setup.run(t, uuid.NewString(), map[string]string{"version": "v1"})
+	require.Equal(t, string(SUCCESS), pipelineRun.State)
+
+	// v2 fails and is rolled back to v1
+	setup.server.SetScript(workflow, func(inputs map[string]string) []githubtest.Step {
+		if inputs["version"] == "v2" {
+			return githubtest.Fails
+		}
+		return githubtest.Succeeds
+	})
+
+	pipelineRun = setup.run(t, uuid.NewString(), map[string]string{"version": "v2"})
+	assert.Equal(t, string(ROLLBACK), pipelineRun.State)
+	require.NotNil(t, pipelineRun.Stages[0].Rollback)
+	assert.Equal(t, "v1", pipelineRun.Stages[0].Rollback.Input["version"])
+
+	runs := setup.server.Runs(workflow)
+	require.Len(t, runs, 3)
+	assert.Equal(t, "v2", runs[1].Inputs["version"])
+	assert.Equal(t, "failure", runs[1].Step().Conclusion)
+	assert.Equal(t, "v1", runs[2].Inputs["version"])
+	assert.Equal(t, "Deploy v1 - "+pipelineRun.Stages[0].Rollback.RunId, runs[2].Name)
+}
+
+func TestE2EDispatchFailure(t *testing.T) {
+	setup := setupE2E(t, []Stage{
+		{Input: map[string]string{"version": ""}},
+	})
+
+	setup.server.Fail(http.MethodPost, "/repos/org1/repo1/actions/workflows/"+strconv.FormatInt(setup.workflows[0].Id, 10)+"/dispatches", http.StatusUnprocessableEntity, 1)
+
+	runId := uuid.NewString()
+	err := RunPipeline(setup.ctx, "E2E", runId, map[string]string{"version": "v1"}, nil, TriggerMetadata{Name: "e2e"}, false)
+	require.Error(t, err)
+	assert.Empty(t, setup.server.Runs(setup.workflows[0]))
+
+	pipelineRun, err := GetPipelineRun(setup.ctx, "E2E", runId)
+	require.NoError(t, err)
+	assert.Equal(t, "Workflow_Failed", pipelineRun.Stages[0].State)
+	assert.Contains(t, pipelineRun.Stages[0].Reason, "scripted failure")
+}
+
+func TestE2EGithubClient(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.AddRepo("org1/public1", false)
+	server.AddRepo("org2/private1", true)
+	workflow, err := server.AddWorkflow("org1/public1", ".github/workflows/build.yml", "name: Build\non: [push]\n", nil)
+	require.NoError(t, err)
+
+	repos, err := GetRepos("private")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org2/private1"}, repos)
+
+	orgs, err := github.DefaultClient.ListOrgsForUser()
+	require.NoError(t, err)
+	require.Len(t, orgs, 2)
+
+	// workflow without workflow_dispatch needs changes and cannot be dispatched
+	changes, _, err := github.DefaultClient.ValidateWorkflow("org1", "public1", workflow.Path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, changes)
+
+	err = github.DefaultClient.CreateWorkflowDispatch("org1", "public1", workflow.Id, "main", nil)
+	require.Error(t, err)
+}
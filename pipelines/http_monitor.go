@@ -0,0 +1,265 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/orchestrator/core"
+	"github.com/ohler55/ojg/jp"
+)
+
+const (
+	DefaultHttpCheckInterval         = 10 * time.Second
+	DefaultHttpCheckFailureThreshold = 3
+
+	// response body recorded in stage run reason is truncated
+	httpCheckResponseLimit = 256
+)
+
+var (
+	inputTemplateRegex = regexp.MustCompile(`\$\{\{\s*inputs\.([A-Za-z0-9_\-]+)\s*\}\}`)
+
+	httpCheckClient = &http.Client{Timeout: 10 * time.Second}
+
+	// httpChecks keeps consecutive failures across ticks, controllers are deserialized on every tick
+	httpChecks = struct {
+		sync.Mutex
+		m map[string]*httpCheckState
+	}{m: make(map[string]*httpCheckState)}
+)
+
+type HttpCheck struct {
+	Url string `json:"url"`
+	// StatusCodes expected, defaults to 200
+	StatusCodes []int `json:"status_codes,omitempty"`
+	// JsonPath optional assertion on json response eg: $.version
+	JsonPath string `json:"json_path,omitempty"`
+	// Expected value at json path, run inputs are templated eg: ${{ inputs.version }}
+	Expected string `json:"expected,omitempty"`
+}
+
+type HttpInfo struct {
+	Checks []HttpCheck `json:"checks"`
+	// Interval between checks eg: 30s, defaults to 10s
+	Interval string `json:"interval,omitempty"`
+	// FailureThreshold consecutive failed checks before stage fails, defaults to 3
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+	// Window stage is monitored after workflow success eg: 15m, defaults to 15m
+	Window   string `json:"window,omitempty"`
+	Rollback bool   `json:"rollback"`
+}
+
+func (h *HttpInfo) window() time.Duration {
+	window, err := time.ParseDuration(h.Window)
+	if err != nil || window <= 0 {
+		return DefaultMonitoringWindowSecs * time.Second
+	}
+	return window
+}
+
+func (h *HttpInfo) windowSecs() int {
+	return int(h.window().Seconds())
+}
+
+func (h *HttpInfo) interval() time.Duration {
+	interval, err := time.ParseDuration(h.Interval)
+	if err != nil || interval < 0 {
+		return DefaultHttpCheckInterval
+	}
+	return interval
+}
+
+func (h *HttpInfo) failureThreshold() int {
+	if h.FailureThreshold <= 0 {
+		return DefaultHttpCheckFailureThreshold
+	}
+	return h.FailureThreshold
+}
+
+// Validate checks urls, json path and durations
+func (h *HttpInfo) Validate() error {
+	if len(h.Checks) <= 0 {
+		return fmt.Errorf("http checks cannot be empty")
+	}
+	for _, check := range h.Checks {
+		if _, err := url.ParseRequestURI(check.Url); err != nil {
+			return fmt.Errorf("invalid http check url %s, %v", check.Url, err)
+		}
+		if check.JsonPath != "" {
+			if _, err := jp.ParseString(check.JsonPath); err != nil {
+				return fmt.Errorf("invalid json path %s, %v", check.JsonPath, err)
+			}
+		}
+	}
+	for name, duration := range map[string]string{"interval": h.Interval, "window": h.Window} {
+		if duration == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(duration); err != nil {
+			return fmt.Errorf("invalid http check %s %s, %v", name, duration, err)
+		}
+	}
+	return nil
+}
+
+// templateInputs replaces ${{ inputs.key }} with run inputs
+func templateInputs(value string, inputs map[string]string) string {
+	return inputTemplateRegex.ReplaceAllStringFunc(value, func(match string) string {
+		return inputs[inputTemplateRegex.FindStringSubmatch(match)[1]]
+	})
+}
+
+type httpCheckState struct {
+	lastChecked time.Time
+	failures    int
+	lastErr     error
+}
+
+type HttpMonitoringController struct {
+	*HttpInfo
+	// Key identifies stage run to keep consecutive failures
+	Key string `json:"key"`
+	// ExpectedValues are Expected for every check templated with run inputs
+	ExpectedValues []string `json:"expected_values,omitempty"`
+}
+
+func newHttpMonitoringController(info *HttpInfo, key string, inputs map[string]string) *HttpMonitoringController {
+	controller := &HttpMonitoringController{HttpInfo: info, Key: key}
+	for _, check := range info.Checks {
+		controller.ExpectedValues = append(controller.ExpectedValues, templateInputs(check.Expected, inputs))
+	}
+	return controller
+}
+
+func responseSnippet(body []byte) string {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > httpCheckResponseLimit {
+		snippet = snippet[:httpCheckResponseLimit] + "..."
+	}
+	return snippet
+}
+
+func (m *HttpMonitoringController) check(check HttpCheck, expected string) error {
+	checkUrl := check.Url
+	resp, err := httpCheckClient.Get(checkUrl)
+	if err != nil {
+		return fmt.Errorf("http check %s failed %v", checkUrl, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("http check %s failed reading response %v", checkUrl, err)
+	}
+
+	statusCodes := check.StatusCodes
+	if len(statusCodes) <= 0 {
+		statusCodes = []int{http.StatusOK}
+	}
+	if !slices.Contains(statusCodes, resp.StatusCode) {
+		return fmt.Errorf("http check %s returned %d, expected %v, response: %s", checkUrl, resp.StatusCode, statusCodes, responseSnippet(body))
+	}
+
+	if check.JsonPath == "" {
+		return nil
+	}
+
+	expr, err := jp.ParseString(check.JsonPath)
+	if err != nil {
+		return fmt.Errorf("invalid json path %s, %v", check.JsonPath, err)
+	}
+
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Errorf("http check %s returned invalid json, response: %s", checkUrl, responseSnippet(body))
+	}
+
+	results := expr.Get(data)
+	if len(results) <= 0 {
+		return fmt.Errorf("http check %s json path %s not found, response: %s", checkUrl, check.JsonPath, responseSnippet(body))
+	}
+
+	if check.Expected == "" {
+		return nil
+	}
+
+	for _, result := range results {
+		if fmt.Sprint(result) != expected {
+			return fmt.Errorf("http check %s json path %s is %v, expected %s, response: %s", checkUrl, check.JsonPath, result, expected, responseSnippet(body))
+		}
+	}
+
+	return nil
+}
+
+// ExternalMonitoring checks every url once per interval, stage fails after consecutive failures reach threshold
+func (m *HttpMonitoringController) ExternalMonitoring(_ []*core.ClientState) error {
+	httpChecks.Lock()
+	state, ok := httpChecks.m[m.Key]
+	if !ok {
+		state = &httpCheckState{}
+		httpChecks.m[m.Key] = state
+	}
+	if !state.lastChecked.IsZero() && time.Since(state.lastChecked) < m.interval() {
+		httpChecks.Unlock()
+		return nil
+	}
+	state.lastChecked = time.Now()
+	httpChecks.Unlock()
+
+	var checkErr error
+	for i, check := range m.Checks {
+		expected := ""
+		if i < len(m.ExpectedValues) {
+			expected = m.ExpectedValues[i]
+		}
+		if checkErr = m.check(check, expected); checkErr != nil {
+			break
+		}
+	}
+
+	httpChecks.Lock()
+	defer httpChecks.Unlock()
+	if checkErr == nil {
+		state.failures = 0
+		state.lastErr = nil
+		return nil
+	}
+
+	state.failures++
+	state.lastErr = checkErr
+	if state.failures < m.failureThreshold() {
+		return nil
+	}
+
+	delete(httpChecks.m, m.Key)
+	return fmt.Errorf("%w, %d consecutive failures, %w", ErrMonitoringFailed, state.failures, checkErr)
+}
+
+// endHttpCheck drops consecutive failures of stage run once its monitoring window ends, whatever the outcome
+func endHttpCheck(key string) {
+	httpChecks.Lock()
+	defer httpChecks.Unlock()
+	delete(httpChecks.m, key)
+}
+
+// endHttpChecks drops consecutive failures of every stage of pipeline run when its orchestrator stops
+func endHttpChecks(pipelineRunId string) {
+	httpChecks.Lock()
+	defer httpChecks.Unlock()
+	for key := range httpChecks.m {
+		if strings.HasSuffix(key, "/"+pipelineRunId) {
+			delete(httpChecks.m, key)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type healthService struct {
+	lock    sync.Mutex
+	status  int
+	version string
+}
+
+func (h *healthService) set(status int, version string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.status = status
+	h.version = version
+}
+
+func (h *healthService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	switch r.URL.Path {
+	case "/healthz":
+		w.WriteHeader(h.status)
+		_, _ = fmt.Fprint(w, "unhealthy: db connection refused")
+	case "/version":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"build":{"version":"%s"}}`, h.version)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHttpMonitoring(t *testing.T) {
+	service := &healthService{status: http.StatusOK, version: "44ffae"}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	info := &HttpInfo{
+		Checks: []HttpCheck{
+			{Url: server.URL + "/healthz", StatusCodes: []int{http.StatusOK, http.StatusNoContent}},
+			{Url: server.URL + "/version", JsonPath: "$.build.version", Expected: "${{ inputs.version }}"},
+		},
+		Interval:         "0s",
+		FailureThreshold: 2,
+	}
+	require.NoError(t, info.Validate())
+
+	controller := newHttpMonitoringController(info, t.Name(), map[string]string{"version": "44ffae"})
+	assert.Equal(t, []string{"", "44ffae"}, controller.ExpectedValues)
+	require.NoError(t, controller.ExternalMonitoring(nil))
+
+	// single failure is below threshold, success resets consecutive failures
+	service.set(http.StatusServiceUnavailable, "44ffae")
+	require.NoError(t, controller.ExternalMonitoring(nil))
+	service.set(http.StatusOK, "44ffae")
+	require.NoError(t, controller.ExternalMonitoring(nil))
+
+	service.set(http.StatusServiceUnavailable, "44ffae")
+	require.NoError(t, controller.ExternalMonitoring(nil))
+	err := controller.ExternalMonitoring(nil)
+	require.ErrorIs(t, err, ErrMonitoringFailed)
+	assert.Contains(t, err.Error(), server.URL+"/healthz returned 503, expected [200 204]")
+	assert.Contains(t, err.Error(), "unhealthy: db connection refused")
+
+	service.set(http.StatusOK, "e3d0bea")
+	require.NoError(t, controller.ExternalMonitoring(nil))
+	err = controller.ExternalMonitoring(nil)
+	require.ErrorIs(t, err, ErrMonitoringFailed)
+	assert.Contains(t, err.Error(), "$.build.version is e3d0bea, expected 44ffae")
+
+	info.Checks[1].JsonPath = "$.build.["
+	require.Error(t, info.Validate())
+}
+
+func TestHttpMonitoringInterval(t *testing.T) {
+	service := &healthService{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	controller := newHttpMonitoringController(&HttpInfo{Checks: []HttpCheck{{Url: server.URL + "/healthz"}}, Interval: "1h", FailureThreshold: 1}, t.Name(), nil)
+	require.ErrorIs(t, controller.ExternalMonitoring(nil), ErrMonitoringFailed)
+
+	controller = newHttpMonitoringController(&HttpInfo{Checks: []HttpCheck{{Url: server.URL + "/healthz"}}, Interval: "1h", FailureThreshold: 2}, t.Name()+"2", nil)
+	require.NoError(t, controller.ExternalMonitoring(nil))
+	// next check is only after interval
+	require.NoError(t, controller.ExternalMonitoring(nil))
+}
+
+func TestHttpMonitoringWindowEnd(t *testing.T) {
+	service := &healthService{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	info := &HttpInfo{Checks: []HttpCheck{{Url: server.URL + "/healthz"}}, Interval: "0s", FailureThreshold: 3}
+	keys := []string{"stage1/run1", "stage2/run1", "stage1/run2"}
+	for _, key := range keys {
+		require.NoError(t, newHttpMonitoringController(info, key, nil).ExternalMonitoring(nil))
+	}
+	hasCheck := func(key string) bool {
+		httpChecks.Lock()
+		defer httpChecks.Unlock()
+		_, ok := httpChecks.m[key]
+		return ok
+	}
+
+	// stage window ended with consecutive failures below threshold
+	endHttpCheck("stage1/run1")
+	assert.False(t, hasCheck("stage1/run1"))
+	assert.True(t, hasCheck("stage2/run1"))
+
+	endHttpChecks("run1")
+	assert.False(t, hasCheck("stage2/run1"))
+	assert.True(t, hasCheck("stage1/run2"))
+	endHttpChecks("run2")
+}
+
+func TestOrchestrateHttpMonitorFailure(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateHttpMonitorFailure*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
+	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
+
+	// previous version is still deployed
+	service := &healthService{status: http.StatusOK, version: "dummy1"}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	o.pipeline = &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Monitor: MonitorInfo{Http: &HttpInfo{
+					Checks:           []HttpCheck{{Url: server.URL + "/version", JsonPath: "$.build.version", Expected: "${{ inputs.version }}"}},
+					Interval:         "0s",
+					FailureThreshold: 1,
+				}},
+				Input: map[string]string{"version": ""}},
+		},
+	}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, o.pipeline.Stages[0]))
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	o.githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed", Conclusion: "success"}}, afterDispatch: true}
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+
+	assert.Equal(t, FAILED, o.stageStatus.GetState())
+	pipelineRun, err := GetPipelineRun(context.Background(), o.pipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+	assert.Equal(t, "Failed", pipelineRun.Stages[0].State)
+	assert.Contains(t, pipelineRun.Stages[0].Reason, server.URL+"/version json path $.build.version is dummy1, expected dummy2")
+	assert.Contains(t, pipelineRun.Stages[0].Reason, `{"build":{"version":"dummy1"}}`)
+
+	httpChecks.Lock()
+	defer httpChecks.Unlock()
+	for key := range httpChecks.m {
+		assert.NotContains(t, key, o.pipelineRunId)
+	}
+}
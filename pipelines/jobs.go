@@ -0,0 +1,150 @@
+package pipelines
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+
+	"github.com/rs/zerolog"
+)
+
+// JobsPollInterval is how often jobs of a workflow run in progress are listed when its status does not change
+var JobsPollInterval = 30 * time.Second
+
+// JobSummary job of stage workflow run, failed step is the first step that did not succeed
+type JobSummary struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	Conclusion  string    `json:"conclusion,omitempty"`
+	Url         string    `json:"url,omitempty"`
+	Started     time.Time `json:"started"`
+	Completed   time.Time `json:"completed"`
+	CurrentStep string    `json:"current_step,omitempty"`
+	FailedStep  string    `json:"failed_step,omitempty"`
+}
+
+func (j JobSummary) Duration() time.Duration {
+	if j.Started.IsZero() {
+		return 0
+	}
+	if j.Completed.IsZero() {
+		return time.Now().UTC().Sub(j.Started).Truncate(time.Second)
+	}
+	return j.Completed.Sub(j.Started)
+}
+
+func (j JobSummary) Failed() bool {
+	return j.Status == "completed" && !jobSucceeded(j.Conclusion)
+}
+
+func jobSucceeded(conclusion string) bool {
+	return conclusion == "success" || conclusion == "skipped" || conclusion == "neutral"
+}
+
+func jobSummaries(jobs []github.WorkflowJob) []JobSummary {
+	var summaries []JobSummary
+	for _, job := range jobs {
+		summary := JobSummary{
+			Name:       job.Name,
+			Status:     job.Status,
+			Conclusion: job.Conclusion,
+			Url:        job.Url,
+			Started:    job.StartedAt,
+			Completed:  job.CompletedAt,
+		}
+		for _, step := range job.Steps {
+			if step.Status == "in_progress" && summary.CurrentStep == "" {
+				summary.CurrentStep = step.Name
+			}
+			if step.Status == "completed" && !jobSucceeded(step.Conclusion) && summary.FailedStep == "" {
+				summary.FailedStep = step.Name
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// jobsPoll workflow run status jobs were last listed for
+type jobsPoll struct {
+	status string
+	polled time.Time
+}
+
+// updateJobs lists jobs of stage workflow run when its status changes, jobs of runs in progress are listed again
+// every JobsPollInterval and are skipped while github quota is low.
+// Jobs are only a summary, failing to list them does not fail the stage
+func (o *orchestrator) updateJobs(stage Stage, workflowRun github.WorkflowRun, currentRun *run, logger *zerolog.Logger) {
+	if o.jobsPolled == nil {
+		o.jobsPolled = make(map[int64]jobsPoll)
+	}
+	last, ok := o.jobsPolled[workflowRun.Id]
+	if ok && last.status == workflowRun.Status && (currentRun.state != "InProgress" || time.Since(last.polled) < JobsPollInterval) {
+		return
+	}
+
+	if currentRun.state == "InProgress" {
+		if rateLimit := o.githubClient.CurrentRateLimit(); rateLimit != nil && rateLimit.Low() {
+			return
+		}
+	}
+
+	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
+	jobs, err := o.githubClient.ListWorkflowJobs(orgRepoSlice[0], orgRepoSlice[1], workflowRun.Id)
+	if err != nil {
+		logger.Warn().Err(err).Int64("WorkflowRunId", workflowRun.Id).Msg("failed to list github workflow run jobs")
+		return
+	}
+	currentRun.jobs = jobSummaries(jobs)
+	o.jobsPolled[workflowRun.Id] = jobsPoll{status: workflowRun.Status, polled: time.Now()}
+}
+
+// renderJobs job tree of stage, collapsed tree only has jobs in progress or failed
+func renderJobs(jobs []JobSummary, expand bool) string {
+	var shown []JobSummary
+	succeeded := 0
+	for _, job := range jobs {
+		if !expand && job.Status == "completed" && !job.Failed() {
+			succeeded++
+			continue
+		}
+		shown = append(shown, job)
+	}
+
+	s := ""
+	for i, job := range shown {
+		branch := "├─"
+		if i == len(shown)-1 && succeeded == 0 {
+			branch = "└─"
+		}
+		s += descriptionStyle.Faint(true).Render("\n    "+branch+" ") + renderJob(job)
+	}
+	if succeeded > 0 {
+		s += descriptionStyle.Faint(true).Render(fmt.Sprintf("\n    └─ %d of %d jobs succeeded", succeeded, len(jobs)))
+	}
+	return s
+}
+
+func renderJob(job JobSummary) string {
+	duration := job.Duration().String()
+	switch {
+	case job.Status == "completed" && job.Failed():
+		s := crossMark.Render() + " " + failedStyle.Render(job.Name+" "+duration)
+		if job.FailedStep != "" {
+			s += failedStyle.Faint(true).Render(" step " + job.FailedStep + " " + job.Conclusion)
+		}
+		return s
+	case job.Status == "completed":
+		return checkMark.Render() + " " + doneStyle.Render(job.Name+" "+duration)
+	case job.Status == "in_progress":
+		s := bulletMark.Render() + " " + currentStyle.Render(job.Name+" "+duration)
+		if job.CurrentStep != "" {
+			s += currentStyle.Faint(true).Render(" step " + job.CurrentStep)
+		}
+		return s
+	default:
+		return bulletMark.Render() + " " + waitStyle.Render(job.Name+" "+job.Status)
+	}
+}
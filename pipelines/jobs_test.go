@@ -0,0 +1,110 @@
+package pipelines
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobSummaries(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	summaries := jobSummaries([]github.WorkflowJob{
+		{Name: "build", Status: "completed", Conclusion: "success", StartedAt: started, CompletedAt: started.Add(time.Minute), Steps: []github.WorkflowStep{
+			{Name: "Run make", Status: "completed", Conclusion: "success"},
+			{Name: "Upload", Status: "completed", Conclusion: "skipped"},
+		}},
+		{Name: "deploy", Status: "in_progress", StartedAt: started, Steps: []github.WorkflowStep{
+			{Name: "Checkout", Status: "completed", Conclusion: "success"},
+			{Name: "Run deploy", Status: "in_progress"},
+			{Name: "Verify", Status: "queued"},
+		}},
+		{Name: "smoke", Status: "completed", Conclusion: "failure", Steps: []github.WorkflowStep{
+			{Name: "Run tests", Status: "completed", Conclusion: "failure"},
+			{Name: "Cleanup", Status: "completed", Conclusion: "cancelled"},
+		}},
+	})
+
+	require.Len(t, summaries, 3)
+	assert.Equal(t, JobSummary{Name: "build", Status: "completed", Conclusion: "success", Started: started, Completed: started.Add(time.Minute)}, summaries[0])
+	assert.Equal(t, time.Minute, summaries[0].Duration())
+	assert.False(t, summaries[0].Failed())
+	assert.Equal(t, "Run deploy", summaries[1].CurrentStep)
+	assert.False(t, summaries[1].Failed())
+	assert.Equal(t, "Run tests", summaries[2].FailedStep)
+	assert.True(t, summaries[2].Failed())
+	assert.Equal(t, "smoke/Run tests", failedJobs(summaries))
+}
+
+func TestE2EStageJobs(t *testing.T) {
+	setup := setupE2E(t, []Stage{{Input: map[string]string{"version": ""}}})
+	setup.server.SetScript(setup.workflows[0], githubtest.Steps(githubtest.Fails...))
+	setup.server.SetJobs(setup.workflows[0], logsJobs...)
+
+	runId := uuid.NewString()
+	pipelineRun := setup.run(t, runId, map[string]string{"version": "v1"})
+	assert.Equal(t, string(FAILED), pipelineRun.State)
+
+	jobs := pipelineRun.Stages[0].Jobs
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "build", jobs[0].Name)
+	assert.Equal(t, "success", jobs[0].Conclusion)
+	assert.Equal(t, "deploy", jobs[1].Name)
+	assert.Equal(t, "completed", jobs[1].Status)
+	assert.Equal(t, "failure", jobs[1].Conclusion)
+	assert.Equal(t, "Run deploy", jobs[1].FailedStep)
+	assert.NotZero(t, jobs[1].Completed)
+
+	// collapsed tree only shows failed jobs
+	out, err := captureStdout(t, func() error { return ShowPipelineRun("E2E", runId, "", false) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "deploy 1s step Run deploy failure")
+	assert.Contains(t, out, "1 of 2 jobs succeeded")
+	assert.NotContains(t, out, "build")
+
+	out, err = captureStdout(t, func() error { return ShowPipelineRun("E2E", runId, "", true) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "├─ ✓ build 2s")
+	assert.Contains(t, out, "└─ x deploy 1s step Run deploy failure")
+}
+
+func TestUpdateJobsPolling(t *testing.T) {
+	o := setupOrchestrator(t)
+	client := newTestGithubClient()
+	o.githubClient = client
+
+	interval := JobsPollInterval
+	defer func() {
+		JobsPollInterval = interval
+	}()
+
+	stage := Stage{Repo: "org1/repo1"}
+	currentRun := &run{state: "InProgress"}
+	workflowRun := github.WorkflowRun{Id: 1, Status: "queued"}
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	assert.Equal(t, 1, client.jobsCalls)
+
+	// status changes are listed right away
+	workflowRun.Status = "in_progress"
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	assert.Equal(t, 2, client.jobsCalls)
+
+	// runs in progress are listed again after poll interval
+	JobsPollInterval = 0
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	assert.Equal(t, 3, client.jobsCalls)
+
+	JobsPollInterval = time.Hour
+	workflowRun.Status = "completed"
+	currentRun.state = "Workflow_Success"
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	o.updateJobs(stage, workflowRun, currentRun, o.logger)
+	assert.Equal(t, 4, client.jobsCalls)
+}
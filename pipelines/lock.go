@@ -14,7 +14,7 @@ const (
 )
 
 func lockUnlockPipelineRun(pipeline *Pipeline, reason string, lock bool) error {
-	userStore, err := users.GetCachedTokens()
+	currentUser, err := users.CurrentUser()
 	if err != nil {
 		return err
 	}
@@ -22,13 +22,13 @@ func lockUnlockPipelineRun(pipeline *Pipeline, reason string, lock bool) error {
 	resource := map[string]string{"Pipeline": pipeline.Name}
 	if lock {
 		pipeline.Locked = true
-		if err := audit.Save(context.Background(), AUDIT_LOCKED, resource, userStore.GithubUser.Login, userStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_LOCKED, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 		fmt.Println("\n" + checkMark.Render() + " " + doneStyle.Render("Successfully locked pipeline\n"))
 	} else {
 		pipeline.Locked = false
-		if err := audit.Save(context.Background(), AUDIT_UNLOCKED, resource, userStore.GithubUser.Login, userStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_UNLOCKED, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 		fmt.Println("\n" + checkMark.Render() + " " + doneStyle.Render("Successfully unlocked pipeline\n"))
@@ -85,10 +85,11 @@ func LockPipeline(ctx context.Context, name, reason string) error {
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 
 	resource := map[string]string{"Pipeline": pipeline.Name}
 	pipeline.Locked = true
-	if err := audit.Save(ctx, AUDIT_LOCKED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_LOCKED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 
@@ -107,10 +108,11 @@ func UnlockPipeline(ctx context.Context, name, reason string) error {
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 
 	resource := map[string]string{"Pipeline": pipeline.Name}
 	pipeline.Locked = false
-	if err := audit.Save(ctx, AUDIT_UNLOCKED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_UNLOCKED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 
@@ -0,0 +1,261 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+)
+
+// LogsPollInterval is how often jobs of stage workflow run are listed with --follow
+var LogsPollInterval = 5 * time.Second
+
+// logPrinter prints workflow run logs with job and step prefixes
+type logPrinter struct {
+	client    github.Client
+	w         io.Writer
+	org, repo string
+	runID     int64
+}
+
+func RunPipelineRunLogs(name, id string, stage int, follow bool) error {
+	return PipelineRunLogs(context.Background(), github.DefaultClient, os.Stdout, name, id, stage, follow)
+}
+
+// PipelineRunLogs prints logs of workflow run dispatched for stage, stage 0 picks failed or in progress stage.
+// With follow, logs of jobs are printed as they complete while stage is in progress
+func PipelineRunLogs(ctx context.Context, client github.Client, w io.Writer, name, id string, stage int, follow bool) error {
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pipelineRun, err := GetPipelineRun(ctx, name, id)
+	if err != nil {
+		return err
+	}
+
+	i, err := logsStage(pipelineRun, stage)
+	if err != nil {
+		return err
+	}
+	if i >= len(pipeline.Stages) {
+		return fmt.Errorf("stage %d is no longer part of pipeline %s", i+1, name)
+	}
+	stageRun := pipelineRun.Stages[i]
+	if stageRun.RunId == "" {
+		return fmt.Errorf("stage %d %s has not dispatched a workflow run", i+1, stageRun.Name)
+	}
+
+	orgRepoSlice := strings.SplitN(pipeline.Stages[i].Repo, "/", 2)
+	if len(orgRepoSlice) != 2 {
+		return fmt.Errorf("repo %s is not in org/repo format", pipeline.Stages[i].Repo)
+	}
+	org, repo := orgRepoSlice[0], orgRepoSlice[1]
+	workflowID := pipeline.Stages[i].Workflow.Id
+	created := ">=" + pipelineRun.Created.Format(time.RFC3339)
+
+	workflowRun, err := client.FindWorkflowRun(org, repo, workflowID, created, stageRun.RunId)
+	if err != nil {
+		return err
+	}
+	if workflowRun == nil {
+		return fmt.Errorf("workflow run with pippy_run_id %s not found for stage %d %s", stageRun.RunId, i+1, stageRun.Name)
+	}
+
+	l := &logPrinter{client: client, w: w, org: org, repo: repo, runID: workflowRun.Id}
+	if workflowRun.Status == "completed" {
+		return l.printArchive()
+	}
+
+	printed := make(map[int64]bool)
+	for {
+		jobs, err := client.ListWorkflowJobs(org, repo, workflowRun.Id)
+		if err != nil {
+			return err
+		}
+		if err := l.printCompletedJobs(jobs, printed); err != nil {
+			return err
+		}
+
+		if workflowRun.Status == "completed" {
+			return nil
+		}
+
+		if !follow {
+			for _, job := range jobs {
+				if !printed[job.Id] {
+					l.println(job.Name, "", fmt.Sprintf("job is %s, follow logs using --follow", job.Status))
+				}
+			}
+			return nil
+		}
+
+		pipelineRun, err := GetPipelineRun(ctx, name, id)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(pipelineRun.Stages[i].State, "InProgress") {
+			// stage completes in the same tick workflow run completes, jobs completed since last poll are printed once more
+			jobs, err := client.ListWorkflowJobs(org, repo, workflowRun.Id)
+			if err != nil {
+				return err
+			}
+			return l.printCompletedJobs(jobs, printed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(LogsPollInterval):
+		}
+
+		workflowRun, err = client.FindWorkflowRun(org, repo, workflowID, created, stageRun.RunId)
+		if err != nil {
+			return err
+		}
+		if workflowRun == nil {
+			return fmt.Errorf("workflow run with pippy_run_id %s not found for stage %d %s", stageRun.RunId, i+1, stageRun.Name)
+		}
+	}
+}
+
+// logsStage index of stage, first failed stage then in progress stage then last dispatched stage when stage is 0
+func logsStage(pipelineRun *PipelineRun, stage int) (int, error) {
+	if stage > 0 {
+		if stage > len(pipelineRun.Stages) {
+			return 0, fmt.Errorf("stage %d not found, pipeline run has %d stages", stage, len(pipelineRun.Stages))
+		}
+		return stage - 1, nil
+	}
+
+	for _, state := range []string{"Failed", "InProgress"} {
+		for i, stageRun := range pipelineRun.Stages {
+			if strings.EqualFold(stageRun.State, state) {
+				return i, nil
+			}
+		}
+	}
+	for i := len(pipelineRun.Stages) - 1; i >= 0; i-- {
+		if pipelineRun.Stages[i].RunId != "" {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no stage of pipeline run %s has dispatched a workflow run", pipelineRun.Id)
+}
+
+// printArchive prints step logs from log archive, jobs with only a job log are split into steps by line timestamps
+func (l *logPrinter) printArchive() error {
+	logs, err := l.client.GetWorkflowRunLogs(l.org, l.repo, l.runID)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := l.client.ListWorkflowJobs(l.org, l.repo, l.runID)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	for _, workflowLog := range logs {
+		if !slices.Contains(names, workflowLog.Job) {
+			names = append(names, workflowLog.Job)
+		}
+	}
+
+	for _, name := range names {
+		var jobLog *github.WorkflowLog
+		stepLogs := false
+		for _, workflowLog := range logs {
+			if workflowLog.Job != name {
+				continue
+			}
+			if workflowLog.Step == "" {
+				jobLog = &workflowLog
+				continue
+			}
+			stepLogs = true
+			for _, line := range splitLines(workflowLog.Content) {
+				l.println(name, workflowLog.Step, line)
+			}
+		}
+		if stepLogs || jobLog == nil {
+			continue
+		}
+
+		job := github.WorkflowJob{Name: name}
+		for _, workflowJob := range jobs {
+			if workflowJob.Name == name {
+				job = workflowJob
+			}
+		}
+		l.printJobLog(job, jobLog.Content)
+	}
+
+	return nil
+}
+
+// printCompletedJobs prints logs of jobs completed since last call, github has no logs for jobs in progress
+func (l *logPrinter) printCompletedJobs(jobs []github.WorkflowJob, printed map[int64]bool) error {
+	for _, job := range jobs {
+		if printed[job.Id] || job.Status != "completed" {
+			continue
+		}
+		content, err := l.client.GetWorkflowJobLogs(l.org, l.repo, job.Id)
+		if err != nil {
+			return err
+		}
+		l.printJobLog(job, content)
+		printed[job.Id] = true
+	}
+	return nil
+}
+
+// printJobLog prints job log with step each line was logged in, github prefixes lines with timestamps
+func (l *logPrinter) printJobLog(job github.WorkflowJob, content string) {
+	step := ""
+	for _, line := range splitLines(content) {
+		step = stepOf(job.Steps, line, step)
+		l.println(job.Name, step, line)
+	}
+}
+
+func (l *logPrinter) println(job, step, line string) {
+	prefix := job
+	if step != "" {
+		prefix += " | " + step
+	}
+	fmt.Fprintln(l.w, currentStyle.Render(prefix+" |")+" "+line)
+}
+
+// stepOf step line was logged in, lines without timestamp belong to current step
+func stepOf(steps []github.WorkflowStep, line, current string) string {
+	timestamp, _, _ := strings.Cut(line, " ")
+	logged, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return current
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		// step timestamps are in seconds
+		if !steps[i].StartedAt.IsZero() && !logged.Before(steps[i].StartedAt.Truncate(time.Second)) {
+			return steps[i].Name
+		}
+	}
+	return current
+}
+
+func splitLines(content string) []string {
+	content = strings.TrimRight(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
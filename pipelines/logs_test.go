@@ -0,0 +1,160 @@
+package pipelines
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logsJobs = []githubtest.Job{
+	{Name: "build", Steps: []githubtest.JobStep{
+		{Name: "Set up job", Log: []string{"Runner ubuntu-latest"}},
+		{Name: "Run make", Log: []string{"make build", "build ok"}},
+	}},
+	{Name: "deploy", Steps: []githubtest.JobStep{
+		{Name: "Run deploy", Conclusion: "failure", Log: []string{"deploying v1", "error: rollout timed out"}},
+	}},
+}
+
+// logLines log lines without timestamps
+func logLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		prefix, message, _ := strings.Cut(line, "| 20")
+		if _, text, ok := strings.Cut(message, "Z "); ok {
+			line = prefix + "| " + text
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestPipelineRunLogs(t *testing.T) {
+	setup := setupE2E(t, []Stage{{Input: map[string]string{"version": ""}}})
+	setup.server.SetJobs(setup.workflows[0], logsJobs...)
+
+	runId := uuid.NewString()
+	setup.run(t, runId, map[string]string{"version": "v1"})
+
+	var out bytes.Buffer
+	require.NoError(t, PipelineRunLogs(setup.ctx, github.DefaultClient, &out, "E2E", runId, 0, false))
+	assert.Equal(t, []string{
+		"build | Set up job | Runner ubuntu-latest",
+		"build | Run make | make build",
+		"build | Run make | build ok",
+		"deploy | Run deploy | deploying v1",
+		"deploy | Run deploy | error: rollout timed out",
+	}, logLines(out.String()))
+
+	err := PipelineRunLogs(setup.ctx, github.DefaultClient, &out, "E2E", runId, 2, false)
+	assert.EqualError(t, err, "stage 2 not found, pipeline run has 1 stages")
+}
+
+func TestPipelineRunLogsFollow(t *testing.T) {
+	setup := setupE2E(t, []Stage{{Input: map[string]string{"version": ""}}})
+	setup.server.SetJobs(setup.workflows[0], logsJobs...)
+
+	pollInterval := LogsPollInterval
+	LogsPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		LogsPollInterval = pollInterval
+	})
+
+	// stage run in progress, dispatched by orchestrator running elsewhere
+	stageRunId := uuid.NewString()
+	require.NoError(t, github.DefaultClient.CreateWorkflowDispatch("org1", "repo1", setup.workflows[0].Id, "main", map[string]interface{}{"version": "v2", "pippy_run_id": stageRunId}))
+	pipelineRun := &PipelineRun{
+		Id:           uuid.NewString(),
+		PipelineName: "E2E",
+		State:        string(IN_PROGRESS),
+		Created:      time.Now().UTC().Add(-time.Minute),
+		Stages:       []StageRun{{Name: getStageName(0, "Deploy"), State: "InProgress", RunId: stageRunId}},
+	}
+	require.NoError(t, savePipelineRun(setup.ctx, pipelineRun))
+
+	// build completes while run is in progress, deploy has no logs until it completes
+	var out bytes.Buffer
+	require.NoError(t, PipelineRunLogs(setup.ctx, github.DefaultClient, &out, "E2E", pipelineRun.Id, 1, false))
+	assert.Equal(t, []string{
+		"build | Set up job | Runner ubuntu-latest",
+		"build | Run make | make build",
+		"build | Run make | build ok",
+		"deploy | job is in_progress, follow logs using --follow",
+	}, logLines(out.String()))
+
+	// deploy completes with run
+	out.Reset()
+	require.NoError(t, PipelineRunLogs(setup.ctx, github.DefaultClient, &out, "E2E", pipelineRun.Id, 1, true))
+	assert.Equal(t, []string{
+		"build | Set up job | Runner ubuntu-latest",
+		"build | Run make | make build",
+		"build | Run make | build ok",
+		"deploy | Run deploy | deploying v1",
+		"deploy | Run deploy | error: rollout timed out",
+	}, logLines(out.String()))
+}
+
+// completingJobsClient completes stage after first jobs are listed, like orchestrator saving stage in the tick its workflow run completes
+type completingJobsClient struct {
+	github.Client
+	complete func()
+	listed   int
+}
+
+func (c *completingJobsClient) ListWorkflowJobs(org, repo string, runID int64) ([]github.WorkflowJob, error) {
+	jobs, err := c.Client.ListWorkflowJobs(org, repo, runID)
+	c.listed++
+	if c.listed == 1 {
+		c.complete()
+	}
+	return jobs, err
+}
+
+func TestPipelineRunLogsFollowStageCompleted(t *testing.T) {
+	setup := setupE2E(t, []Stage{{Input: map[string]string{"version": ""}}})
+	setup.server.SetJobs(setup.workflows[0], logsJobs...)
+
+	pollInterval := LogsPollInterval
+	LogsPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		LogsPollInterval = pollInterval
+	})
+
+	stageRunId := uuid.NewString()
+	require.NoError(t, github.DefaultClient.CreateWorkflowDispatch("org1", "repo1", setup.workflows[0].Id, "main", map[string]interface{}{"version": "v2", "pippy_run_id": stageRunId}))
+	pipelineRun := &PipelineRun{
+		Id:           uuid.NewString(),
+		PipelineName: "E2E",
+		State:        string(IN_PROGRESS),
+		Created:      time.Now().UTC().Add(-time.Minute),
+		Stages:       []StageRun{{Name: getStageName(0, "Deploy"), State: "InProgress", RunId: stageRunId}},
+	}
+	require.NoError(t, savePipelineRun(setup.ctx, pipelineRun))
+
+	// workflow run and stage complete between polls, deploy log is printed before follow stops
+	client := &completingJobsClient{Client: github.DefaultClient, complete: func() {
+		workflowRun, err := github.DefaultClient.FindWorkflowRun("org1", "repo1", setup.workflows[0].Id, ">="+pipelineRun.Created.Format(time.RFC3339), stageRunId)
+		require.NoError(t, err)
+		require.NotNil(t, workflowRun)
+		pipelineRun.Stages[0].State = "Failed"
+		require.NoError(t, savePipelineRun(setup.ctx, pipelineRun))
+	}}
+	var out bytes.Buffer
+	require.NoError(t, PipelineRunLogs(setup.ctx, client, &out, "E2E", pipelineRun.Id, 1, true))
+	assert.Equal(t, []string{
+		"build | Set up job | Runner ubuntu-latest",
+		"build | Run make | make build",
+		"build | Run make | build ok",
+		"deploy | Run deploy | deploying v1",
+		"deploy | Run deploy | error: rollout timed out",
+	}, logLines(out.String()))
+	assert.Equal(t, 2, client.listed)
+}
@@ -0,0 +1,91 @@
+package pipelines
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/nixmade/orchestrator/core"
+)
+
+const (
+	// DefaultMonitoringWindowSecs stage is monitored after workflow success, before it is marked successful
+	DefaultMonitoringWindowSecs = 900
+)
+
+var (
+	// ErrMonitoringFailed wraps external monitoring failures, stage is failed instead of aborting pipeline run
+	ErrMonitoringFailed = errors.New("external monitoring failed")
+
+	registerMonitoringControllers sync.Once
+)
+
+// MonitoringControllers evaluates multiple monitors configured for a stage, first failure fails the stage
+type MonitoringControllers struct {
+	Controllers []core.SerializedEntityMonitoringController `json:"controllers"`
+}
+
+func (m *MonitoringControllers) ExternalMonitoring(targets []*core.ClientState) error {
+	for _, controller := range m.Controllers {
+		if controller.EntityMonitoringController == nil {
+			continue
+		}
+		if err := controller.ExternalMonitoring(targets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerControllers() {
+	registerMonitoringControllers.Do(func() {
+		core.RegisteredMonitoringControllers = append(core.RegisteredMonitoringControllers,
+			&MonitoringController{},
+			&PrometheusMonitoringController{},
+			&HttpMonitoringController{},
+			&MonitoringControllers{},
+		)
+	})
+}
+
+// stageMonitoringController returns monitoring controller for all monitors configured on stage, nil if none
+// key identifies the stage run and inputs are used for templating monitors
+func stageMonitoringController(stage Stage, key string, inputs map[string]string) core.EntityMonitoringController {
+	var controllers []core.EntityMonitoringController
+	if stage.Monitor.Datadog.monitoring() {
+		controllers = append(controllers, newDatadogMonitoringController(stage.Monitor.Datadog, inputs))
+	}
+	if stage.Monitor.Prometheus != nil {
+		controllers = append(controllers, &PrometheusMonitoringController{PrometheusInfo: stage.Monitor.Prometheus})
+	}
+	if stage.Monitor.Http != nil {
+		controllers = append(controllers, newHttpMonitoringController(stage.Monitor.Http, key, inputs))
+	}
+
+	switch len(controllers) {
+	case 0:
+		return nil
+	case 1:
+		return controllers[0]
+	}
+
+	multi := &MonitoringControllers{}
+	for _, controller := range controllers {
+		multi.Controllers = append(multi.Controllers, core.SerializedEntityMonitoringController{EntityMonitoringController: controller})
+	}
+	return multi
+}
+
+// stageMonitoringWindowSecs longest evaluation window across monitors configured on stage
+func stageMonitoringWindowSecs(stage Stage) int {
+	windowSecs := 0
+	if stage.Monitor.Datadog.monitoring() {
+		windowSecs = DefaultMonitoringWindowSecs
+	}
+	if stage.Monitor.Prometheus != nil {
+		windowSecs = max(windowSecs, stage.Monitor.Prometheus.windowSecs())
+	}
+	if stage.Monitor.Http != nil {
+		windowSecs = max(windowSecs, stage.Monitor.Http.windowSecs())
+	}
+	return windowSecs
+}
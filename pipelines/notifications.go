@@ -0,0 +1,400 @@
+package pipelines
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/secrets"
+)
+
+const (
+	NOTIFICATION_WEBHOOK = "webhook"
+	NOTIFICATION_SLACK   = "slack"
+	NOTIFICATION_EMAIL   = "email"
+
+	EVENT_RUN_STARTED      = "run_started"
+	EVENT_STAGE_SUCCEEDED  = "stage_succeeded"
+	EVENT_STAGE_FAILED     = "stage_failed"
+	EVENT_APPROVAL_PENDING = "approval_pending"
+	EVENT_ROLLBACK         = "rollback"
+	EVENT_RUN_COMPLETED    = "run_completed"
+
+	NotificationSignatureHeader = "X-Pippy-Signature-256"
+	DefaultPippyUrl             = "http://localhost:8080"
+)
+
+var (
+	NotificationTypes  = []string{NOTIFICATION_WEBHOOK, NOTIFICATION_SLACK, NOTIFICATION_EMAIL}
+	NotificationEvents = []string{EVENT_RUN_STARTED, EVENT_STAGE_SUCCEEDED, EVENT_STAGE_FAILED, EVENT_APPROVAL_PENDING, EVENT_ROLLBACK, EVENT_RUN_COMPLETED}
+
+	// NotificationRetries attempts per sink before giving up, backoff doubles after every attempt
+	NotificationRetries = 4
+	NotificationBackoff = 2 * time.Second
+	// NotificationWait upper bound to wait for pending deliveries when a pipeline run exits
+	NotificationWait = 30 * time.Second
+)
+
+type SmtpInfo struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Notification sink for pipeline run and stage state changes
+type Notification struct {
+	// Type is one of webhook, slack or email
+	Type string `json:"type"`
+	// Url for webhook and slack incoming webhook
+	Url string `json:"url,omitempty"`
+	// Secret signs webhook payload with HMAC SHA256
+	Secret string    `json:"secret,omitempty"`
+	Smtp   *SmtpInfo `json:"smtp,omitempty"`
+	// Events to notify, all events when empty
+	Events []string `json:"events,omitempty"`
+}
+
+type NotificationStage struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Url   string `json:"url,omitempty"`
+}
+
+// NotificationEvent is the payload delivered to webhook sinks
+type NotificationEvent struct {
+	Event    string              `json:"event"`
+	Pipeline string              `json:"pipeline"`
+	RunId    string              `json:"run_id"`
+	State    string              `json:"state"`
+	Url      string              `json:"url"`
+	Stage    *NotificationStage  `json:"stage,omitempty"`
+	Reason   string              `json:"reason,omitempty"`
+	Stages   []NotificationStage `json:"stages"`
+	Trigger  TriggerMetadata     `json:"trigger_metadata"`
+	Time     time.Time           `json:"time"`
+}
+
+// PipelineRunUrl dashboard url for pipeline run, base url is set with PIPPY_URL
+func PipelineRunUrl(name, runId string) string {
+	baseUrl := os.Getenv("PIPPY_URL")
+	if baseUrl == "" {
+		baseUrl = DefaultPippyUrl
+	}
+	return fmt.Sprintf("%s/#/pipelines/%s/runs/%s", strings.TrimRight(baseUrl, "/"), url.PathEscape(name), runId)
+}
+
+func (n Notification) wants(event string) bool {
+	return len(n.Events) <= 0 || slices.Contains(n.Events, event)
+}
+
+// notificationEvents compares previously saved pipeline run with current and returns events for state changes
+func notificationEvents(previous, current *PipelineRun) []NotificationEvent {
+	var stages []NotificationStage
+	for _, stageRun := range current.Stages {
+		stages = append(stages, NotificationStage{Name: stageRun.Name, State: stageRun.State, Url: stageRun.Url})
+	}
+
+	newEvent := func(event string, stage *NotificationStage, reason string) NotificationEvent {
+		return NotificationEvent{
+			Event:    event,
+			Pipeline: current.PipelineName,
+			RunId:    current.Id,
+			State:    current.State,
+			Url:      PipelineRunUrl(current.PipelineName, current.Id),
+			Stage:    stage,
+			Reason:   reason,
+			Stages:   stages,
+			Trigger:  current.Trigger,
+			Time:     time.Now().UTC(),
+		}
+	}
+
+	var events []NotificationEvent
+	if previous == nil {
+		events = append(events, newEvent(EVENT_RUN_STARTED, nil, current.Trigger.Reason))
+	}
+
+	for i, stageRun := range current.Stages {
+		previousState := ""
+		if previous != nil && i < len(previous.Stages) {
+			previousState = previous.Stages[i].State
+		}
+		if previousState == stageRun.State {
+			continue
+		}
+		stage := &stages[i]
+		switch stageRun.State {
+		case "Success":
+			events = append(events, newEvent(EVENT_STAGE_SUCCEEDED, stage, stageRun.Reason))
+		case "Failed", "ConcurrentError":
+			events = append(events, newEvent(EVENT_STAGE_FAILED, stage, stageRun.Reason))
+		case "PendingApproval":
+			events = append(events, newEvent(EVENT_APPROVAL_PENDING, stage, ""))
+		}
+	}
+
+	previousState := ""
+	if previous != nil {
+		previousState = previous.State
+	}
+	if previousState != current.State {
+		switch State(current.State) {
+		case ROLLBACK:
+			events = append(events, newEvent(EVENT_ROLLBACK, nil, ""))
+		case SUCCESS, FAILED, CANCELED:
+			events = append(events, newEvent(EVENT_RUN_COMPLETED, nil, ""))
+		}
+	}
+
+	return events
+}
+
+// notify delivers events to pipeline notification sinks asynchronously, deliveries are tracked per run
+func (o *orchestrator) notify(events []NotificationEvent) {
+	for _, notification := range o.pipeline.Notifications {
+		for _, event := range events {
+			if !notification.wants(event.Event) {
+				continue
+			}
+			o.notifications.Add(1)
+			go func(notification Notification, event NotificationEvent) {
+				defer o.notifications.Done()
+				deliverNotification(notification, event)
+			}(notification, event)
+		}
+	}
+}
+
+// waitNotifications waits for pending deliveries of this run, bounded by NotificationWait
+func (o *orchestrator) waitNotifications() {
+	done := make(chan struct{})
+	go func() {
+		o.notifications.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(NotificationWait):
+		o.logger.Warn().Msg("timed out waiting for pending notifications")
+	}
+}
+
+func deliverNotification(notification Notification, event NotificationEvent) {
+	logger := log.Get().With().Str("Pipeline", event.Pipeline).Str("RunId", event.RunId).Str("Event", event.Event).Str("Notification", notification.Type).Logger()
+
+	backoff := NotificationBackoff
+	for attempt := 1; ; attempt++ {
+		err := sendNotification(notification, event)
+		if err == nil {
+			logger.Info().Int("Attempt", attempt).Msg("notification delivered")
+			return
+		}
+		if attempt >= NotificationRetries {
+			logger.Error().Err(err).Int("Attempt", attempt).Msg("failed to deliver notification, giving up")
+			return
+		}
+		logger.Warn().Err(err).Int("Attempt", attempt).Dur("Backoff", backoff).Msg("failed to deliver notification, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func sendNotification(notification Notification, event NotificationEvent) error {
+	switch notification.Type {
+	case NOTIFICATION_WEBHOOK:
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		headers := map[string]string{"X-Pippy-Event": event.Event}
+		if notification.Secret != "" {
+			// secret is resolved only while signing, pipeline holds secret or env reference
+			secret, err := secrets.Resolve(context.Background(), notification.Secret)
+			if err != nil {
+				return fmt.Errorf("failed to resolve webhook secret, %v", err)
+			}
+			headers[NotificationSignatureHeader] = signNotification(body, secret)
+		}
+		_, err = helpers.HttpPostJSON(notification.Url, body, headers)
+		return err
+	case NOTIFICATION_SLACK:
+		body, err := json.Marshal(map[string]string{"text": notificationText(event, true)})
+		if err != nil {
+			return err
+		}
+		_, err = helpers.HttpPostJSON(notification.Url, body, nil)
+		return err
+	case NOTIFICATION_EMAIL:
+		return sendEmail(notification.Smtp, event)
+	}
+
+	return fmt.Errorf("unknown notification type %s", notification.Type)
+}
+
+func signNotification(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func notificationTitle(event NotificationEvent) string {
+	switch event.Event {
+	case EVENT_RUN_STARTED:
+		return fmt.Sprintf("Pipeline %s run %s started", event.Pipeline, event.RunId)
+	case EVENT_STAGE_SUCCEEDED:
+		return fmt.Sprintf("Pipeline %s stage %s succeeded", event.Pipeline, event.Stage.Name)
+	case EVENT_STAGE_FAILED:
+		return fmt.Sprintf("Pipeline %s stage %s failed", event.Pipeline, event.Stage.Name)
+	case EVENT_APPROVAL_PENDING:
+		return fmt.Sprintf("Pipeline %s stage %s pending approval", event.Pipeline, event.Stage.Name)
+	case EVENT_ROLLBACK:
+		return fmt.Sprintf("Pipeline %s run %s rolling back", event.Pipeline, event.RunId)
+	case EVENT_RUN_COMPLETED:
+		return fmt.Sprintf("Pipeline %s run %s completed with %s", event.Pipeline, event.RunId, event.State)
+	}
+	return fmt.Sprintf("Pipeline %s run %s %s", event.Pipeline, event.RunId, event.Event)
+}
+
+func notificationText(event NotificationEvent, slackLinks bool) string {
+	link := func(text, url string) string {
+		if url == "" {
+			return text
+		}
+		if slackLinks {
+			return fmt.Sprintf("<%s|%s>", url, text)
+		}
+		return fmt.Sprintf("%s %s", text, url)
+	}
+
+	lines := []string{notificationTitle(event)}
+	if event.Reason != "" {
+		lines = append(lines, "Reason: "+event.Reason)
+	}
+	lines = append(lines, link("Pipeline run", event.Url))
+	for _, stage := range event.Stages {
+		lines = append(lines, fmt.Sprintf("• %s: %s", link(stage.Name, stage.Url), stage.State))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sendEmail(smtpInfo *SmtpInfo, event NotificationEvent) error {
+	if smtpInfo == nil {
+		return fmt.Errorf("smtp settings missing for email notification")
+	}
+
+	addr := net.JoinHostPort(smtpInfo.Host, strconv.Itoa(smtpInfo.Port))
+	var auth smtp.Auth
+	if smtpInfo.Username != "" {
+		password, err := secrets.Resolve(context.Background(), smtpInfo.Password)
+		if err != nil {
+			return fmt.Errorf("failed to resolve smtp password, %v", err)
+		}
+		auth = smtp.PlainAuth("", smtpInfo.Username, password, smtpInfo.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + smtpInfo.From,
+		"To: " + strings.Join(smtpInfo.To, ", "),
+		"Subject: " + notificationTitle(event),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		notificationText(event, false),
+	}, "\r\n")
+
+	return smtp.SendMail(addr, auth, smtpInfo.From, smtpInfo.To, []byte(message))
+}
+
+func AddPipelineNotification(ctx context.Context, name string, notification Notification) error {
+	if !slices.Contains(NotificationTypes, notification.Type) {
+		return fmt.Errorf("please provide a valid type in %s", strings.Join(NotificationTypes, ","))
+	}
+
+	for _, event := range notification.Events {
+		if !slices.Contains(NotificationEvents, event) {
+			return fmt.Errorf("please provide valid events in %s", strings.Join(NotificationEvents, ","))
+		}
+	}
+
+	if notification.Type == NOTIFICATION_EMAIL {
+		if notification.Smtp == nil || notification.Smtp.Host == "" || notification.Smtp.From == "" || len(notification.Smtp.To) <= 0 {
+			return fmt.Errorf("please provide smtp host, from and to for email notifications")
+		}
+	} else if _, err := url.ParseRequestURI(notification.Url); err != nil {
+		return fmt.Errorf("please provide a valid url, %v", err)
+	}
+
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pipeline.Notifications = append(pipeline.Notifications, notification)
+	return SavePipeline(ctx, pipeline)
+}
+
+func DeletePipelineNotification(ctx context.Context, name string, index int) error {
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(pipeline.Notifications) {
+		return fmt.Errorf("%d invalid notification, choose between 1 and %d", index+1, len(pipeline.Notifications))
+	}
+
+	// webhook secret and smtp password of removed notification are deleted once it is no longer saved
+	removed := &Pipeline{Name: pipeline.Name, Notifications: []Notification{pipeline.Notifications[index]}}
+	pipeline.Notifications = slices.Delete(pipeline.Notifications, index, index+1)
+	if err := SavePipeline(ctx, pipeline); err != nil {
+		return err
+	}
+	return deleteOwnedSecrets(ctx, removed, pipeline)
+}
+
+func ShowPipelineNotifications(name, output string) error {
+	pipeline, err := GetPipeline(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	if len(pipeline.Notifications) <= 0 && !helpers.Structured(output) {
+		fmt.Println("\n" + currentStyle.Render(fmt.Sprintf("No notifications for pipeline %s\n", name)))
+		return nil
+	}
+
+	// urls and smtp credentials stay out of structured output, same as pipeline show
+	notifications := []NotificationOutput{}
+	rows := [][]string{}
+	for i, notification := range pipeline.Notifications {
+		notifications = append(notifications, NotificationOutput{Type: notification.Type, Events: notification.Events})
+		destination := notification.Url
+		if notification.Smtp != nil {
+			destination = strings.Join(notification.Smtp.To, ",")
+		}
+		events := strings.Join(notification.Events, ",")
+		if events == "" {
+			events = "all"
+		}
+		rows = append(rows, []string{strconv.Itoa(i + 1), notification.Type, destination, events})
+	}
+
+	return helpers.WriteOutput(os.Stdout, output, notifications, []string{"#", "TYPE", "DESTINATION", "EVENTS"}, rows)
+}
@@ -0,0 +1,255 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/secrets"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationReceiver struct {
+	lock     sync.Mutex
+	requests int
+	failures int
+	events   []NotificationEvent
+	bodies   []map[string]string
+	headers  []http.Header
+}
+
+func (n *notificationReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.requests++
+	if n.requests <= n.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	event := NotificationEvent{}
+	if err := json.Unmarshal(body, &event); err == nil && event.Event != "" {
+		if r.Header.Get(NotificationSignatureHeader) != signNotification(body, "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n.events = append(n.events, event)
+		n.headers = append(n.headers, r.Header.Clone())
+		return
+	}
+	text := map[string]string{}
+	_ = json.Unmarshal(body, &text)
+	n.bodies = append(n.bodies, text)
+}
+
+func (n *notificationReceiver) eventNames() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	var names []string
+	for _, event := range n.events {
+		names = append(names, event.Event)
+	}
+	return names
+}
+
+func TestNotificationEvents(t *testing.T) {
+	t.Setenv("PIPPY_URL", "https://pippy.example.com/")
+
+	current := &PipelineRun{Id: "run1", PipelineName: "Pipeline 1", State: string(IN_PROGRESS), Stages: []StageRun{{Name: "Workflow1", State: "InProgress", Url: "https://github.com/org1/repo1/actions/runs/1"}}}
+	events := notificationEvents(nil, current)
+	require.Len(t, events, 1)
+	assert.Equal(t, EVENT_RUN_STARTED, events[0].Event)
+	assert.Equal(t, "https://pippy.example.com/#/pipelines/Pipeline%201/runs/run1", events[0].Url)
+	assert.Equal(t, "https://github.com/org1/repo1/actions/runs/1", events[0].Stages[0].Url)
+
+	previous := &PipelineRun{State: current.State, Stages: []StageRun{{Name: "Workflow1", State: "InProgress"}, {Name: "Workflow2"}}}
+	current = &PipelineRun{Id: "run1", PipelineName: "Pipeline 1", State: string(PENDING_APPROVAL), Stages: []StageRun{{Name: "Workflow1", State: "Success"}, {Name: "Workflow2", State: "PendingApproval"}}}
+	events = notificationEvents(previous, current)
+	require.Len(t, events, 2)
+	assert.Equal(t, EVENT_STAGE_SUCCEEDED, events[0].Event)
+	assert.Equal(t, "Workflow1", events[0].Stage.Name)
+	assert.Equal(t, EVENT_APPROVAL_PENDING, events[1].Event)
+	assert.Equal(t, "Workflow2", events[1].Stage.Name)
+
+	// unchanged states do not notify again
+	assert.Empty(t, notificationEvents(current, current))
+
+	previous = current
+	current = &PipelineRun{Id: "run1", PipelineName: "Pipeline 1", State: string(ROLLBACK), Stages: []StageRun{{Name: "Workflow1", State: "Success"}, {Name: "Workflow2", State: "Failed", Reason: "github workflow run failed"}}}
+	events = notificationEvents(previous, current)
+	require.Len(t, events, 2)
+	assert.Equal(t, EVENT_STAGE_FAILED, events[0].Event)
+	assert.Equal(t, "github workflow run failed", events[0].Reason)
+	assert.Equal(t, EVENT_ROLLBACK, events[1].Event)
+
+	previous = current
+	current = &PipelineRun{Id: "run1", PipelineName: "Pipeline 1", State: string(FAILED), Stages: current.Stages}
+	events = notificationEvents(previous, current)
+	require.Len(t, events, 1)
+	assert.Equal(t, EVENT_RUN_COMPLETED, events[0].Event)
+}
+
+func TestOrchestrateNotifications(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateNotifications*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	backoff := NotificationBackoff
+	NotificationBackoff = 10 * time.Millisecond
+	defer func() {
+		NotificationBackoff = backoff
+	}()
+
+	webhook := &notificationReceiver{failures: 1}
+	webhookServer := httptest.NewServer(webhook)
+	defer webhookServer.Close()
+
+	slack := &notificationReceiver{}
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+
+	o.pipeline = &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Input:    map[string]string{"version": ""}},
+		},
+		Notifications: []Notification{
+			{Type: NOTIFICATION_WEBHOOK, Url: webhookServer.URL, Secret: "secret"},
+			{Type: NOTIFICATION_SLACK, Url: slackServer.URL, Events: []string{EVENT_RUN_COMPLETED}},
+		},
+	}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, o.pipeline.Stages[0]))
+	status := o.stageStatus.Get(getStageName(0, o.pipeline.Stages[0].Workflow.Name))
+	runs := []github.WorkflowRun{{Name: status.runId, Status: "completed", Url: "https://github.com/org1/repo1/actions/runs/1"}}
+	o.githubClient = &runGithubClient{workflowRuns: runs, afterDispatch: true}
+
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+	require.Equal(t, SUCCESS, o.stageStatus.GetState())
+	o.waitNotifications()
+
+	// first delivery failed and was retried
+	assert.ElementsMatch(t, []string{EVENT_RUN_STARTED, EVENT_STAGE_SUCCEEDED, EVENT_RUN_COMPLETED}, webhook.eventNames())
+	assert.Greater(t, webhook.requests, len(webhook.events))
+	for _, headers := range webhook.headers {
+		assert.NotEmpty(t, headers.Get(NotificationSignatureHeader))
+	}
+
+	require.Len(t, slack.bodies, 1)
+	assert.Contains(t, slack.bodies[0]["text"], "completed with Success")
+	assert.Contains(t, slack.bodies[0]["text"], "<https://github.com/org1/repo1/actions/runs/1|Workflow1>")
+	assert.Contains(t, slack.bodies[0]["text"], PipelineRunUrl("Pipeline1", o.pipelineRunId))
+}
+
+func TestNotificationsPerRun(t *testing.T) {
+	release := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slowServer.Close()
+	defer close(release)
+
+	slow := setupOrchestrator(t)
+	slow.pipeline = &Pipeline{Name: "Slow", Notifications: []Notification{{Type: NOTIFICATION_WEBHOOK, Url: slowServer.URL}}}
+	slow.notify([]NotificationEvent{{Event: EVENT_RUN_STARTED, Pipeline: "Slow"}})
+
+	// another run does not wait for deliveries of the slow run
+	fast := setupOrchestrator(t)
+	waited := make(chan struct{})
+	go func() {
+		fast.waitNotifications()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run waited for notifications of another run")
+	}
+}
+
+func TestNotificationSecrets(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestNotificationSecrets*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	webhook := &notificationReceiver{}
+	webhookServer := httptest.NewServer(webhook)
+	defer webhookServer.Close()
+
+	ctx := context.Background()
+	require.NoError(t, SavePipeline(ctx, &Pipeline{Name: "Pipeline1"}))
+	require.NoError(t, AddPipelineNotification(ctx, "Pipeline1", Notification{Type: NOTIFICATION_WEBHOOK, Url: webhookServer.URL, Secret: "secret"}))
+	require.NoError(t, AddPipelineNotification(ctx, "Pipeline1", Notification{Type: NOTIFICATION_EMAIL, Smtp: &SmtpInfo{Host: "localhost", Username: "user", Password: "smtp-pass", From: "pippy@example.com", To: []string{"team@example.com"}}}))
+
+	pipeline, err := GetPipeline(ctx, "Pipeline1")
+	require.NoError(t, err)
+	require.Len(t, pipeline.Notifications, 2)
+	assert.True(t, secrets.IsRef(pipeline.Notifications[0].Secret))
+	assert.True(t, secrets.IsRef(pipeline.Notifications[1].Smtp.Password))
+
+	// deleted notification secret names are not reused by notifications added after it
+	deletedSecret := pipeline.Notifications[0].Secret
+	require.NoError(t, DeletePipelineNotification(ctx, "Pipeline1", 0))
+	_, err = secrets.Resolve(ctx, deletedSecret)
+	require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+	require.NoError(t, AddPipelineNotification(ctx, "Pipeline1", Notification{Type: NOTIFICATION_WEBHOOK, Url: webhookServer.URL, Secret: "secret"}))
+	pipeline, err = GetPipeline(ctx, "Pipeline1")
+	require.NoError(t, err)
+	password, err := secrets.Resolve(ctx, pipeline.Notifications[0].Smtp.Password)
+	require.NoError(t, err)
+	assert.Equal(t, "smtp-pass", password)
+
+	dbStore, err := store.Get(ctx)
+	require.NoError(t, err)
+	var saved string
+	require.NoError(t, dbStore.LoadValues(PipelinePrefix, func(key, value any) error {
+		saved = value.(string)
+		return nil
+	}))
+	require.NoError(t, store.Close(dbStore))
+	assert.NotContains(t, saved, `"smtp-pass"`)
+	assert.NotContains(t, saved, `:"secret"`)
+
+	// references are resolved only while signing
+	require.NoError(t, sendNotification(pipeline.Notifications[1], NotificationEvent{Event: EVENT_RUN_STARTED, Pipeline: "Pipeline1"}))
+	assert.Equal(t, []string{EVENT_RUN_STARTED}, webhook.eventNames())
+
+	// secrets set by users may be shared, they are kept when pipeline is deleted
+	require.NoError(t, secrets.Set(ctx, "shared-webhook-secret", "shared"))
+	require.NoError(t, AddPipelineNotification(ctx, "Pipeline1", Notification{Type: NOTIFICATION_WEBHOOK, Url: webhookServer.URL, Secret: secrets.Ref("shared-webhook-secret")}))
+	require.NoError(t, DeletePipeline(ctx, "Pipeline1"))
+	for _, ref := range []string{pipeline.Notifications[0].Smtp.Password, pipeline.Notifications[1].Secret} {
+		_, err = secrets.Resolve(ctx, ref)
+		require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+	}
+	shared, err := secrets.Resolve(ctx, secrets.Ref("shared-webhook-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "shared", shared)
+}
@@ -8,17 +8,33 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nixmade/pippy/github"
+
 	"github.com/google/uuid"
 	"github.com/nixmade/orchestrator/core"
+	"github.com/rs/zerolog"
 )
 
 var (
 	ErrReachedTerminalState = errors.New("pipeline rollout reached terminal state")
 	ErrStageInProgress      = errors.New("pipeline stage still in progress")
+
+	// TickInterval milliseconds between orchestrator ticks, each tick polls github for stages in progress
+	TickInterval = 5000
+	// RolloutSettleSecs seconds a stage target reports success before stage completes when it is not monitored
+	RolloutSettleSecs = 0
 )
 
 func (o *orchestrator) orchestrate(ctx context.Context, interval int) error {
-	var err error
+	// one orchestrator continues a run at a time, eg: cockpit and pipeline run execute --id
+	releaseLease, err := o.holdRunLease(ctx)
+	if err != nil {
+		return err
+	}
+	defer releaseLease()
+	// http checks of a resumed run start counting failures again
+	defer endHttpChecks(o.pipelineRunId)
+
 	if err = o.setupEngine(); err != nil {
 		return err
 	}
@@ -29,6 +45,10 @@ func (o *orchestrator) orchestrate(ctx context.Context, interval int) error {
 	}()
 
 	if err := o.tick(ctx, interval); err != nil {
+		// failed stage and reason are persisted so the run shows why it failed
+		if saveErr := o.savePipelineRun(ctx); saveErr != nil {
+			o.logger.Error().Err(saveErr).Msg("failed to save pipeline run")
+		}
 		return err
 	}
 
@@ -36,13 +56,19 @@ func (o *orchestrator) orchestrate(ctx context.Context, interval int) error {
 }
 
 func (o *orchestrator) tick(ctx context.Context, interval int) error {
-	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	tickInterval := time.Duration(interval) * time.Millisecond
+	ticker := time.NewTicker(tickInterval)
 	for {
 		select {
 		case <-o.done:
 			o.logger.Info().Msg("orchestrator tick complete")
 			ticker.Stop()
 			return nil
+		case <-ctx.Done():
+			// run stays in progress and is saved, it can be resumed later
+			o.logger.Info().Msg("orchestrator context done")
+			ticker.Stop()
+			return ctx.Err()
 		case <-ticker.C:
 			o.logger.Info().Msg("orchestrator tick")
 			if err := o.savePipelineRun(ctx); err != nil {
@@ -72,6 +98,8 @@ func (o *orchestrator) tick(ctx context.Context, interval int) error {
 					currentRun.reason = err.Error()
 					currentRun.state = "Workflow_Failed"
 					o.stageStatus.Set(stageName, currentRun)
+					o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_FAILURE, currentRun.reason)
+					o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s failed", o.pipeline.Name, stage.Workflow.Name), DATADOG_EVENT_ERROR)
 					return err
 				}
 			}
@@ -83,10 +111,27 @@ func (o *orchestrator) tick(ctx context.Context, interval int) error {
 				o.stageStatus.UpdateState(SUCCESS)
 				return nil
 			}
+
+			ticker.Reset(o.pollInterval(tickInterval))
 		}
 	}
 }
 
+// pollInterval slows ticks while github quota is low, once quota is exhausted ticks wait for reset
+func (o *orchestrator) pollInterval(interval time.Duration) time.Duration {
+	rateLimit := o.githubClient.CurrentRateLimit()
+	if rateLimit == nil || !rateLimit.Low() {
+		return interval
+	}
+
+	slowed := max(interval, LowRateLimitPollInterval)
+	if rateLimit.Remaining <= 0 {
+		slowed = max(slowed, time.Until(rateLimit.Reset))
+	}
+	o.logger.Warn().Int("Remaining", rateLimit.Remaining).Int("Limit", rateLimit.Limit).Dur("Interval", slowed).Msg("github quota low, slowing polling")
+	return slowed
+}
+
 func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error {
 	stageName := getStageName(i, stage.Workflow.Name)
 	logger := o.logger.With().Str("Stage", stageName).Logger()
@@ -106,7 +151,7 @@ func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error
 	}
 
 	// get the current state from github for above version
-	if err := o.getCurrentState(i, stage); err != nil {
+	if err := o.getCurrentState(ctx, i, stage); err != nil {
 		return err
 	}
 
@@ -123,6 +168,19 @@ func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error
 	targetName := getTargetName(i, stage)
 	logger.Info().Str("Target", targetName).Str("State", currentRun.state).Msg("orchestrating target")
 	targets, err := o.engine.Orchestrate(APP_NAME, targetName, []*core.ClientState{target})
+	if errors.Is(err, ErrMonitoringFailed) {
+		// fail the stage, next tick reports failed target and rolls back if configured
+		logger.Error().Err(err).Msg("stage failed external monitoring")
+		currentRun.completed = time.Now().UTC()
+		currentRun.reason = err.Error()
+		currentRun.state = "Workflow_Failed"
+		var monitorErr *monitorError
+		if errors.As(err, &monitorErr) {
+			currentRun.monitorFailure = &monitorErr.failure
+		}
+		o.stageStatus.Set(stageName, stageCurrentRun)
+		return ErrStageInProgress
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to orchestrate")
 		return err
@@ -133,11 +191,15 @@ func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error
 			// We dont really care much about monitoring since we are in fast rollback mode
 			currentRun.state = "Success"
 			o.stageStatus.Set(stageName, stageCurrentRun)
+			o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_SUCCESS, fmt.Sprintf("rolled back to %s", o.targetVersion))
+			o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s rolled back to %s", o.pipeline.Name, stage.Workflow.Name, o.targetVersion), DATADOG_EVENT_WARNING)
 			return nil
 		}
 		if currentRun.state == "Workflow_Failed" {
 			currentRun.state = "Failed"
 			o.stageStatus.Set(stageName, stageCurrentRun)
+			o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_ERROR, fmt.Sprintf("rollback to %s failed", o.targetVersion))
+			o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s rollback to %s failed", o.pipeline.Name, stage.Workflow.Name, o.targetVersion), DATADOG_EVENT_ERROR)
 			return nil
 		}
 		return o.rolloutExpectedState(i, stage, targets)
@@ -157,14 +219,18 @@ func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error
 		Msg("current rollout state")
 
 	if strings.EqualFold(rolloutState.LastKnownGoodVersion, o.targetVersion) {
+		endHttpCheck(targetName + "/" + o.pipelineRunId)
 		currentRun.completed = time.Now().UTC()
 		currentRun.state = "Success"
 		o.stageStatus.Set(stageName, currentRun)
+		o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_SUCCESS, "rollout completed successfully")
+		o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s completed", o.pipeline.Name, stage.Workflow.Name), DATADOG_EVENT_SUCCESS)
 		logger.Info().Str("LastKnownGoodVersion", rolloutState.LastKnownGoodVersion).Msg("rollout completed successfully")
 		return nil
 	}
 
 	if strings.EqualFold(rolloutState.LastKnownBadVersion, o.targetVersion) {
+		endHttpCheck(targetName + "/" + o.pipelineRunId)
 		if shouldRollback(stage, currentRun) && rolloutState.LastKnownGoodVersion != "" {
 			// Rollback at this point
 			logger.Info().Str("LastKnownBadVersion", rolloutState.LastKnownBadVersion).Msg("rolling back due to workflow or monitoring failure")
@@ -174,6 +240,12 @@ func (o *orchestrator) stageTick(ctx context.Context, i int, stage Stage) error
 		currentRun.completed = time.Now().UTC()
 		currentRun.state = "Failed"
 		o.stageStatus.Set(stageName, currentRun)
+		deploymentReason := "rollout failed"
+		if currentRun.rollback != nil {
+			deploymentReason = fmt.Sprintf("rollout failed, rolled back to %s", o.targetVersion)
+		}
+		o.setDeploymentStatus(i, stage, currentRun, DEPLOYMENT_FAILURE, deploymentReason)
+		o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s %s", o.pipeline.Name, stage.Workflow.Name, deploymentReason), DATADOG_EVENT_ERROR)
 		if currentRun.rollback != nil {
 			o.stageStatus.UpdateState(ROLLBACK)
 		} else {
@@ -215,10 +287,18 @@ func shouldRollback(stage Stage, currentRun *run) bool {
 		return true
 	}
 
+	if stage.Monitor.Prometheus != nil && stage.Monitor.Prometheus.Rollback {
+		return true
+	}
+
+	if stage.Monitor.Http != nil && stage.Monitor.Http.Rollback {
+		return true
+	}
+
 	return false
 }
 
-func (o *orchestrator) getCurrentState(i int, stage Stage) error {
+func (o *orchestrator) getCurrentState(ctx context.Context, i int, stage Stage) error {
 	stageName := getStageName(i, stage.Workflow.Name)
 	logger := o.logger.With().Str("Stage", stageName).Logger()
 	logger.Info().Msg("getting current stage state")
@@ -249,12 +329,8 @@ func (o *orchestrator) getCurrentState(i int, stage Stage) error {
 
 	logger = logger.With().Str("RunId", stageRunId).Logger()
 
-	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
-	logger.Info().Str("Org", orgRepoSlice[0]).Str("Repo", orgRepoSlice[1]).Int64("WorkflowId", stage.Workflow.Id).Msg("listing github workflows")
-	created := fmt.Sprintf(">=%s", o.started)
-	workflowRuns, err := o.githubClient.ListWorkflowRuns(orgRepoSlice[0], orgRepoSlice[1], stage.Workflow.Id, created)
+	workflowRuns, err := o.listWorkflowRuns(ctx, stage, stageRunId, &logger)
 	if err != nil {
-		logger.Error().Err(err).Str("Org", orgRepoSlice[0]).Str("Repo", orgRepoSlice[1]).Int64("WorkflowId", stage.Workflow.Id).Msg("error listing github workflows")
 		return err
 	}
 
@@ -263,10 +339,12 @@ func (o *orchestrator) getCurrentState(i int, stage Stage) error {
 	currentRun.version = ""
 	successStates := []string{"completed", "success", "skipped"}
 	inProgressStates := []string{"in_progress", "queued", "requested", "waiting", "pending"}
+	var matchedRun *github.WorkflowRun
 	for _, workflowRun := range workflowRuns {
 		if !strings.Contains(workflowRun.Name, stageRunId) {
 			continue
 		}
+		matchedRun = &workflowRun
 		currentRun.runUrl = workflowRun.Url
 		currentRun.title = workflowRun.Name
 		currentRun.version = o.targetVersion
@@ -289,11 +367,60 @@ func (o *orchestrator) getCurrentState(i int, stage Stage) error {
 		logger.Info().Str("WorkflowRun", workflowRun.Name).Str("WorkflowRunUrl", workflowRun.Url).Msg("github workflow run failed")
 		break
 	}
+	// jobs are listed when run status changes and at a slower interval while in progress, completed runs are not polled again
+	if matchedRun != nil {
+		o.updateJobs(stage, *matchedRun, currentRun, &logger)
+	}
+	// completed runs are not listed again, pushed workflow run is no longer needed
+	if currentRun.state == "Workflow_Success" || currentRun.state == "Workflow_Failed" {
+		delete(o.lastPolled, stageRunId)
+		if matchedRun != nil {
+			delete(o.jobsPolled, matchedRun.Id)
+		}
+		if err := deleteWorkflowRunUpdate(ctx, stageRunId); err != nil {
+			logger.Error().Err(err).Msg("failed to delete workflow run pushed by webhook")
+			return err
+		}
+	}
 	o.stageStatus.Set(stageName, currentStageRun)
 
 	return nil
 }
 
+// listWorkflowRuns prefers workflow runs pushed by webhooks, github is polled at a slower fallback interval once they arrive
+func (o *orchestrator) listWorkflowRuns(ctx context.Context, stage Stage, stageRunId string, logger *zerolog.Logger) ([]github.WorkflowRun, error) {
+	pushedRun, err := GetWorkflowRunUpdate(ctx, stageRunId)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get workflow run pushed by webhook")
+		return nil, err
+	}
+
+	if o.lastPolled == nil {
+		o.lastPolled = make(map[string]time.Time)
+	}
+
+	if pushedRun != nil && time.Since(o.lastPolled[stageRunId]) < WebhookFallbackPollInterval {
+		logger.Info().Str("WorkflowRun", pushedRun.Name).Str("Status", pushedRun.Status).Msg("using workflow run pushed by webhook")
+		return []github.WorkflowRun{*pushedRun}, nil
+	}
+
+	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
+	logger.Info().Str("Org", orgRepoSlice[0]).Str("Repo", orgRepoSlice[1]).Int64("WorkflowId", stage.Workflow.Id).Msg("listing github workflows")
+	created := fmt.Sprintf(">=%s", o.started)
+	o.lastPolled[stageRunId] = time.Now()
+	// busy workflows can push dispatched run off the first page, pages are listed until run is found
+	workflowRun, err := o.githubClient.FindWorkflowRun(orgRepoSlice[0], orgRepoSlice[1], stage.Workflow.Id, created, stageRunId)
+	if err != nil {
+		logger.Error().Err(err).Str("Org", orgRepoSlice[0]).Str("Repo", orgRepoSlice[1]).Int64("WorkflowId", stage.Workflow.Id).Msg("error listing github workflows")
+		return nil, err
+	}
+
+	if workflowRun == nil {
+		return nil, nil
+	}
+	return []github.WorkflowRun{*workflowRun}, nil
+}
+
 func (o *orchestrator) getStageTarget(ctx context.Context, i int, stage Stage) (*core.ClientState, error) {
 	stageName := getStageName(i, stage.Workflow.Name)
 	logger := o.logger.With().Str("Stage", stageName).Logger()
@@ -315,8 +442,8 @@ func (o *orchestrator) getStageTarget(ctx context.Context, i int, stage Stage) (
 		o.options = &core.RolloutOptions{
 			BatchPercent:        1,
 			SuccessPercent:      100,
-			SuccessTimeoutSecs:  0,
-			DurationTimeoutSecs: 0,
+			SuccessTimeoutSecs:  RolloutSettleSecs,
+			DurationTimeoutSecs: RolloutSettleSecs,
 		}
 
 		logger.Info().EmbedObject(o.options).Msg("resetting rollout options")
@@ -324,17 +451,25 @@ func (o *orchestrator) getStageTarget(ctx context.Context, i int, stage Stage) (
 			logger.Error().Err(err).EmbedObject(o.options).Msg("failed to set rollout options")
 			return nil, err
 		}
+
+		// stage already failed, stop external monitoring so failure is reported
+		if stage.Monitor.Datadog != nil || stage.Monitor.Prometheus != nil || stage.Monitor.Http != nil {
+			if err := o.engine.SetEntityMonitoringController(APP_NAME, targetName, &core.NoOpEntityMonitoringController{}); err != nil {
+				o.logger.Error().Err(err).Msg("failed to reset monitoring controller")
+				return nil, err
+			}
+		}
 	case "Workflow_Unknown":
 		o.options = &core.RolloutOptions{
 			BatchPercent:        1,
 			SuccessPercent:      100,
-			SuccessTimeoutSecs:  0,
+			SuccessTimeoutSecs:  RolloutSettleSecs,
 			DurationTimeoutSecs: 3600,
 		}
 
-		if stage.Monitor.Datadog != nil {
-			// just choosing 15mins for now, later add to datadog info as configurable
-			o.options.SuccessTimeoutSecs = 900
+		// monitor stage for evaluation window after workflow success
+		if windowSecs := stageMonitoringWindowSecs(stage); windowSecs > 0 {
+			o.options.SuccessTimeoutSecs = windowSecs
 		}
 
 		logger.Info().EmbedObject(o.options).Msg("setting rollout options")
@@ -367,8 +502,17 @@ func (o *orchestrator) getStageTarget(ctx context.Context, i int, stage Stage) (
 			}
 		}
 
-		if stage.Monitor.Datadog != nil {
-			controller := &MonitoringController{DatadogInfo: stage.Monitor.Datadog}
+		if stage.Monitor.Datadog.monitoring() {
+			controller := newDatadogMonitoringController(stage.Monitor.Datadog, o.stageInputs(stage))
+			if err := o.engine.SetEntityMonitoringController(APP_NAME, targetName, controller); err != nil {
+				o.logger.Error().Err(err).Msg("failed to set monitoring controller")
+				return nil, err
+			}
+		}
+	case "Workflow_Success":
+		// prometheus queries and http checks evaluate the deployed workflow, they start once it succeeds
+		if stage.Monitor.Prometheus != nil || stage.Monitor.Http != nil {
+			controller := stageMonitoringController(stage, targetName+"/"+o.pipelineRunId, o.stageInputs(stage))
 			if err := o.engine.SetEntityMonitoringController(APP_NAME, targetName, controller); err != nil {
 				o.logger.Error().Err(err).Msg("failed to set monitoring controller")
 				return nil, err
@@ -460,6 +604,12 @@ func (o *orchestrator) rolloutExpectedState(i int, stage Stage, targets []*core.
 		for key, value := range inputs {
 			currentRun.inputs[key] = value.(string)
 		}
+		o.createDeployment(i, stage, currentRun, "main")
+		if o.rollback != nil {
+			o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s dispatched rollback to %s", o.pipeline.Name, stage.Workflow.Name, o.targetVersion), DATADOG_EVENT_WARNING)
+		} else {
+			o.postDatadogEvent(i, stage, currentRun, fmt.Sprintf("Pippy %s stage %s dispatched", o.pipeline.Name, stage.Workflow.Name), DATADOG_EVENT_INFO)
+		}
 		o.stageStatus.Set(stageName, stageCurrentRun)
 		if o.rollback != nil {
 			o.stageStatus.UpdateState(IN_PROGRESS)
@@ -503,14 +653,16 @@ func (o *orchestrator) stageRollback(ctx context.Context, i int, stage Stage, ve
 	}
 }
 
-func (o *orchestrator) run(ctx context.Context) {
+// run orchestrates in background, failures are passed to fail, eg: to show them in UI
+func (o *orchestrator) run(ctx context.Context, fail func(error)) {
 	o.wg.Add(1)
 
 	go func() {
 		defer o.wg.Done()
-		if err := o.orchestrate(ctx, 5000); err != nil {
+		if err := o.orchestrate(ctx, TickInterval); err != nil {
 			o.logger.Error().Err(err).Msg("Failed to run async orchestrator")
-			panic(err)
+			fail(err)
+			return
 		}
 		o.logger.Info().Msg("orchestrator is done")
 	}()
@@ -521,3 +673,17 @@ func (o *orchestrator) wait() {
 	o.logger.Info().Msg("waiting for orchestrator to exit")
 	o.wg.Wait()
 }
+
+// stageInputs static stage inputs overridden by run inputs
+func (o *orchestrator) stageInputs(stage Stage) map[string]string {
+	inputs := make(map[string]string)
+	for key, value := range stage.Input {
+		if value != "" {
+			inputs[key] = value
+		}
+	}
+	for key, value := range o.inputs {
+		inputs[key] = value
+	}
+	return inputs
+}
@@ -0,0 +1,193 @@
+package pipelines
+
+import (
+	"time"
+)
+
+// PipelineOutput pipeline printed by pipeline list and show with --output json or yaml,
+// monitor and notification credentials are left out
+type PipelineOutput struct {
+	Name          string               `json:"name"`
+	Locked        bool                 `json:"locked"`
+	Stages        []StageOutput        `json:"stages"`
+	Triggers      []Trigger            `json:"triggers,omitempty"`
+	Notifications []NotificationOutput `json:"notifications,omitempty"`
+	// Runs count of pipeline runs by state, only set by pipeline list
+	Runs map[string]int64 `json:"runs,omitempty"`
+}
+
+// StageOutput stage of pipeline, monitors and rollback are workflow, datadog, prometheus or http
+type StageOutput struct {
+	Stage                  int               `json:"stage"`
+	Name                   string            `json:"name"`
+	Repo                   string            `json:"repo"`
+	Workflow               WorkflowOutput    `json:"workflow"`
+	Approval               bool              `json:"approval"`
+	Environment            string            `json:"environment,omitempty"`
+	Input                  map[string]string `json:"input,omitempty"`
+	IgnoreWorkflowFailures bool              `json:"ignore_workflow_failures"`
+	Monitors               []string          `json:"monitors"`
+	Rollback               []string          `json:"rollback"`
+}
+
+type WorkflowOutput struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Url  string `json:"url"`
+}
+
+type NotificationOutput struct {
+	Type   string   `json:"type"`
+	Events []string `json:"events,omitempty"`
+}
+
+// PipelineRunOutput pipeline run printed by run list and show with --output json or yaml
+type PipelineRunOutput struct {
+	Id       string            `json:"id"`
+	Pipeline string            `json:"pipeline"`
+	State    string            `json:"state"`
+	Created  time.Time         `json:"created"`
+	Updated  time.Time         `json:"updated"`
+	Duration string            `json:"duration"`
+	Inputs   map[string]string `json:"inputs,omitempty"`
+	Paused   bool              `json:"paused"`
+	Version  string            `json:"version,omitempty"`
+	Trigger  *TriggerMetadata  `json:"trigger,omitempty"`
+	Stages   []StageRunOutput  `json:"stages"`
+}
+
+// StageRunOutput stage run, stages not started yet only have stage, name and state.
+// Rollback is the workflow run dispatched to roll back stage
+type StageRunOutput struct {
+	Stage          int               `json:"stage"`
+	Name           string            `json:"name"`
+	State          string            `json:"state"`
+	Title          string            `json:"title,omitempty"`
+	Url            string            `json:"url,omitempty"`
+	RunId          string            `json:"run_id,omitempty"`
+	Started        *time.Time        `json:"started,omitempty"`
+	Completed      *time.Time        `json:"completed,omitempty"`
+	Duration       string            `json:"duration,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+	Input          map[string]string `json:"input,omitempty"`
+	ApprovedBy     string            `json:"approved_by,omitempty"`
+	MonitorFailure *MonitorFailure   `json:"monitor_failure,omitempty"`
+	Jobs           []JobSummary      `json:"jobs,omitempty"`
+	Rollback       *StageRunOutput   `json:"rollback,omitempty"`
+}
+
+// stageMonitors monitors enabled for stage and monitors that roll back stage on failure
+func stageMonitors(stage Stage) ([]string, []string) {
+	monitors, rollback := []string{}, []string{}
+	if stage.Monitor.Workflow.Rollback {
+		rollback = append(rollback, "workflow")
+	}
+	if stage.Monitor.Datadog != nil {
+		monitors = append(monitors, "datadog")
+		if stage.Monitor.Datadog.Rollback {
+			rollback = append(rollback, "datadog")
+		}
+	}
+	if stage.Monitor.Prometheus != nil {
+		monitors = append(monitors, "prometheus")
+		if stage.Monitor.Prometheus.Rollback {
+			rollback = append(rollback, "prometheus")
+		}
+	}
+	if stage.Monitor.Http != nil {
+		monitors = append(monitors, "http")
+		if stage.Monitor.Http.Rollback {
+			rollback = append(rollback, "http")
+		}
+	}
+	return monitors, rollback
+}
+
+func pipelineOutput(pipeline *Pipeline) PipelineOutput {
+	output := PipelineOutput{
+		Name:     pipeline.Name,
+		Locked:   pipeline.Locked,
+		Stages:   []StageOutput{},
+		Triggers: pipeline.Triggers,
+	}
+	for i, stage := range pipeline.Stages {
+		monitors, rollback := stageMonitors(stage)
+		output.Stages = append(output.Stages, StageOutput{
+			Stage: i + 1,
+			Name:  getStageName(i, stage.Workflow.Name),
+			Repo:  stage.Repo,
+			Workflow: WorkflowOutput{
+				Id:   stage.Workflow.Id,
+				Name: stage.Workflow.Name,
+				Path: stage.Workflow.Path,
+				Url:  stage.Workflow.Url,
+			},
+			Approval:               stage.Approval,
+			Environment:            stage.Environment,
+			Input:                  stage.Input,
+			IgnoreWorkflowFailures: stage.Monitor.Workflow.Ignore,
+			Monitors:               monitors,
+			Rollback:               rollback,
+		})
+	}
+	for _, notification := range pipeline.Notifications {
+		output.Notifications = append(output.Notifications, NotificationOutput{Type: notification.Type, Events: notification.Events})
+	}
+	return output
+}
+
+func pipelineRunOutput(pipelineRun *PipelineRun) PipelineRunOutput {
+	output := PipelineRunOutput{
+		Id:       pipelineRun.Id,
+		Pipeline: pipelineRun.PipelineName,
+		State:    pipelineRun.State,
+		Created:  pipelineRun.Created,
+		Updated:  pipelineRun.Updated,
+		Duration: pipelineRun.Updated.Sub(pipelineRun.Created).String(),
+		Inputs:   pipelineRun.Inputs,
+		Paused:   pipelineRun.Paused,
+		Version:  pipelineRun.Version,
+		Stages:   []StageRunOutput{},
+	}
+	if pipelineRun.Trigger != (TriggerMetadata{}) {
+		output.Trigger = &pipelineRun.Trigger
+	}
+	for i, stage := range pipelineRun.Stages {
+		output.Stages = append(output.Stages, stageRunOutput(i+1, stage))
+	}
+	return output
+}
+
+func stageRunOutput(i int, stage StageRun) StageRunOutput {
+	output := StageRunOutput{
+		Stage:          i,
+		Name:           stage.Name,
+		State:          stage.State,
+		Title:          stage.Title,
+		Url:            stage.Url,
+		RunId:          stage.RunId,
+		Reason:         stage.Reason,
+		Input:          stage.Input,
+		MonitorFailure: stage.MonitorFailure,
+		Jobs:           stage.Jobs,
+	}
+	if !stage.Started.IsZero() {
+		output.Started = &stage.Started
+		if !stage.Completed.IsZero() {
+			output.Completed = &stage.Completed
+			output.Duration = stage.Completed.Sub(stage.Started).String()
+		}
+	}
+	if approval := stage.Metadata.Approval; approval.Name != "" || approval.Login != "" {
+		output.ApprovedBy = approval.Login
+		if output.ApprovedBy == "" {
+			output.ApprovedBy = approval.Name
+		}
+	}
+	if stage.Rollback != nil {
+		rollback := stageRunOutput(i, *stage.Rollback)
+		output.Rollback = &rollback
+	}
+	return output
+}
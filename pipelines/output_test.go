@@ -0,0 +1,210 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout output written to stdout by fn
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	fnErr := fn()
+	require.NoError(t, writer.Close())
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(out), fnErr
+}
+
+func setupOutputTest(t *testing.T) (*Pipeline, *PipelineRun) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOutput*")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	})
+	store.HomeDir = tempDir
+	t.Cleanup(holdStore(t))
+	skipRolloutSettle(t)
+
+	pipeline := &Pipeline{
+		Name: "Output",
+		Stages: []Stage{
+			{
+				Repo:     "org1/repo1",
+				Workflow: github.Workflow{Id: 1, Name: "Deploy", Path: ".github/workflows/deploy.yml"},
+				Approval: true,
+				Input:    map[string]string{"version": ""},
+				Monitor: MonitorInfo{
+					Workflow: WorkflowInfo{Rollback: true},
+					Datadog:  &DatadogInfo{ApiKey: "secret:dd-api", ApplicationKey: "secret:dd-app", Rollback: true},
+				},
+			},
+			{
+				Repo:     "org1/repo2",
+				Workflow: github.Workflow{Id: 2, Name: "Notify"},
+				Monitor:  MonitorInfo{Workflow: WorkflowInfo{Ignore: true}},
+			},
+		},
+		Notifications: []Notification{
+			{Type: "webhook", Url: "https://hooks.example.com", Secret: "hmac-secret", Events: []string{"failed"}},
+			{Type: "email", Smtp: &SmtpInfo{Host: "smtp.example.com", Password: "smtp-password"}},
+		},
+	}
+	require.NoError(t, SavePipeline(context.Background(), pipeline))
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	pipelineRun := &PipelineRun{
+		Id:           "run1",
+		PipelineName: "Output",
+		State:        string(ROLLBACK),
+		Created:      created,
+		Updated:      created.Add(3 * time.Minute),
+		Inputs:       map[string]string{"version": "v1"},
+		Trigger:      TriggerMetadata{Login: "octocat", Source: "user"},
+		Stages: []StageRun{
+			{
+				Name:      getStageName(0, "Deploy"),
+				State:     "Failed",
+				Url:       "https://github.com/org1/repo1/actions/runs/1",
+				RunId:     "1",
+				Started:   created,
+				Completed: created.Add(time.Minute),
+				Metadata:  StageRunMetadata{Approval: StageRunApproval{Name: "Octo Cat", Login: "octocat"}},
+				Rollback: &StageRun{
+					Name:      getStageName(0, "Deploy"),
+					State:     "Success",
+					Url:       "https://github.com/org1/repo1/actions/runs/2",
+					Started:   created.Add(time.Minute),
+					Completed: created.Add(2 * time.Minute),
+				},
+			},
+			{Name: getStageName(1, "Notify"), State: "Pending"},
+		},
+	}
+	require.NoError(t, savePipelineRun(context.Background(), pipelineRun))
+
+	return pipeline, pipelineRun
+}
+
+func TestShowPipelineOutput(t *testing.T) {
+	setupOutputTest(t)
+
+	out, err := captureStdout(t, func() error { return ShowPipeline("Output", helpers.OutputJSON) })
+	require.NoError(t, err)
+	assert.NotContains(t, out, "hmac-secret")
+	assert.NotContains(t, out, "smtp-password")
+	assert.NotContains(t, out, "dd-api")
+
+	var pipelineOutput PipelineOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &pipelineOutput))
+	require.Len(t, pipelineOutput.Stages, 2)
+	assert.Equal(t, StageOutput{
+		Stage:    1,
+		Name:     "Deploy-0",
+		Repo:     "org1/repo1",
+		Workflow: WorkflowOutput{Id: 1, Name: "Deploy", Path: ".github/workflows/deploy.yml"},
+		Approval: true,
+		Input:    map[string]string{"version": ""},
+		Monitors: []string{"datadog"},
+		Rollback: []string{"workflow", "datadog"},
+	}, pipelineOutput.Stages[0])
+	assert.True(t, pipelineOutput.Stages[1].IgnoreWorkflowFailures)
+	assert.Equal(t, []NotificationOutput{{Type: "webhook", Events: []string{"failed"}}, {Type: "email"}}, pipelineOutput.Notifications)
+
+	out, err = captureStdout(t, func() error { return ShowAllPipelines(helpers.OutputYAML) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "- name: Output\n")
+	assert.Contains(t, out, "runs:\n    Rollback: 1\n")
+	assert.NotContains(t, out, "\x1b[")
+
+	out, err = captureStdout(t, func() error { return ShowPipeline("Missing", helpers.OutputJSON) })
+	assert.EqualError(t, err, "pipeline Missing not found")
+	assert.Empty(t, out)
+}
+
+func TestShowPipelineRunOutput(t *testing.T) {
+	setupOutputTest(t)
+
+	out, err := captureStdout(t, func() error { return ShowPipelineRun("Output", "run1", helpers.OutputJSON, false) })
+	require.NoError(t, err)
+
+	var pipelineRunOutput PipelineRunOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &pipelineRunOutput))
+	assert.Equal(t, "Output", pipelineRunOutput.Pipeline)
+	assert.Equal(t, "3m0s", pipelineRunOutput.Duration)
+	assert.Equal(t, "octocat", pipelineRunOutput.Trigger.Login)
+	require.Len(t, pipelineRunOutput.Stages, 2)
+
+	deploy := pipelineRunOutput.Stages[0]
+	assert.Equal(t, "1m0s", deploy.Duration)
+	assert.Equal(t, "octocat", deploy.ApprovedBy)
+	require.NotNil(t, deploy.Rollback)
+	assert.Equal(t, "Success", deploy.Rollback.State)
+	assert.Equal(t, "https://github.com/org1/repo1/actions/runs/2", deploy.Rollback.Url)
+	assert.Equal(t, StageRunOutput{Stage: 2, Name: "Notify-1", State: "Pending"}, pipelineRunOutput.Stages[1])
+
+	// wide keeps table columns and adds more
+	out, err = captureStdout(t, func() error { return ShowAllPipelineRuns("Output", 10, helpers.OutputTable) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "RUN TIME")
+	assert.Contains(t, out, "run1")
+	assert.NotContains(t, out, "TRIGGERED BY")
+
+	out, err = captureStdout(t, func() error { return ShowAllPipelineRuns("Output", 10, helpers.OutputWide) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "RUN TIME")
+	assert.Contains(t, out, "TRIGGERED BY")
+	assert.Contains(t, out, "0/2")
+	assert.Contains(t, out, "octocat")
+	assert.NotContains(t, out, "\x1b[")
+
+	out, err = captureStdout(t, func() error { return ShowPipelineRun("Output", "run2", helpers.OutputJSON, false) })
+	assert.EqualError(t, err, "pipeline run run2 for pipeline Output not found")
+	assert.Empty(t, out)
+}
+
+func TestShowTriggersNotificationsOutput(t *testing.T) {
+	pipeline, _ := setupOutputTest(t)
+
+	out, err := captureStdout(t, func() error { return ShowPipelineTriggers("Output", helpers.OutputJSON) })
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", out)
+
+	pipeline.Triggers = []Trigger{{Event: TRIGGER_PUSH, Repo: "org1/repo1", Branch: "main", Inputs: map[string]string{"version": "${{ event.after }}"}}}
+	require.NoError(t, SavePipeline(context.Background(), pipeline))
+
+	out, err = captureStdout(t, func() error { return ShowPipelineTriggers("Output", helpers.OutputJSON) })
+	require.NoError(t, err)
+	var triggers []Trigger
+	require.NoError(t, json.Unmarshal([]byte(out), &triggers))
+	assert.Equal(t, pipeline.Triggers, triggers)
+
+	out, err = captureStdout(t, func() error { return ShowPipelineNotifications("Output", helpers.OutputYAML) })
+	require.NoError(t, err)
+	assert.Equal(t, "- type: webhook\n  events:\n    - failed\n- type: email\n", out)
+	assert.NotContains(t, out, "hmac-secret")
+	assert.NotContains(t, out, "smtp-password")
+
+	// table output goes through the shared renderer
+	out, err = captureStdout(t, func() error { return ShowPipelineNotifications("Output", helpers.OutputTable) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "DESTINATION")
+	assert.Contains(t, out, "https://hooks.example.com")
+	assert.Contains(t, out, "╭")
+}
@@ -14,7 +14,7 @@ const (
 )
 
 func pauseResumePipelineRun(pipelineRun *PipelineRun, reason string, pause bool) error {
-	userStore, err := users.GetCachedTokens()
+	currentUser, err := users.CurrentUser()
 	if err != nil {
 		return err
 	}
@@ -23,7 +23,7 @@ func pauseResumePipelineRun(pipelineRun *PipelineRun, reason string, pause bool)
 	if pause {
 		pipelineRun.Paused = true
 
-		if err := audit.Save(context.Background(), AUDIT_PAUSED, resource, userStore.GithubUser.Login, userStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_PAUSED, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 		fmt.Println("\n" + checkMark.Render() + " " + doneStyle.Render("Successfully paused pipeline\n"))
@@ -42,7 +42,7 @@ func pauseResumePipelineRun(pipelineRun *PipelineRun, reason string, pause bool)
 			warningStyle.Render(latestAudit.Message) + "\"\n"
 		fmt.Println(s)
 
-		if err := audit.Save(context.Background(), AUDIT_RESUMED, resource, userStore.GithubUser.Login, userStore.GithubUser.Email, reason); err != nil {
+		if err := audit.Save(context.Background(), AUDIT_RESUMED, resource, currentUser.Login, currentUser.Email, currentUser.Source, reason); err != nil {
 			return err
 		}
 	}
@@ -108,10 +108,11 @@ func PausePipelineRun(ctx context.Context, name, id, reason string) error {
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
 	pipelineRun.Paused = true
 
-	if err := audit.Save(ctx, AUDIT_PAUSED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_PAUSED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 
@@ -130,9 +131,10 @@ func ResumePipelineRun(ctx context.Context, name, id, reason string) error {
 
 	userName := ctx.Value(users.NameCtx).(string)
 	userEmail := ctx.Value(users.EmailCtx).(string)
+	userSource := ctx.Value(users.SourceCtx).(string)
 	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
 	pipelineRun.Paused = false
-	if err := audit.Save(ctx, AUDIT_RESUMED, resource, userName, userEmail, reason); err != nil {
+	if err := audit.Save(ctx, AUDIT_RESUMED, resource, userName, userEmail, userSource, reason); err != nil {
 		return err
 	}
 	return savePipelineRun(ctx, pipelineRun)
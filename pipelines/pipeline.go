@@ -6,6 +6,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/nixmade/pippy/helpers"
+
 	"github.com/urfave/cli/v3"
 )
 
@@ -54,9 +56,8 @@ func Command() *cli.Command {
 				Name:  "list",
 				Usage: "list pipeline already saved",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := ShowAllPipelines(); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := ShowAllPipelines(c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -84,9 +85,27 @@ func Command() *cli.Command {
 				Name:  "show",
 				Usage: "show pipeline already saved",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := ShowPipeline(c.String("name")); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := ShowPipeline(c.String("name"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
+					}
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "pipeline name",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "validate stage workflows accept stage and run inputs, are enabled and still exist",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					inputs := c.StringSlice("input")
+					inputPair := parseKeyValuePairs(inputs)
+					if err := RunValidatePipeline(c.String("name"), inputPair, c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -96,6 +115,22 @@ func Command() *cli.Command {
 						Usage:    "pipeline name",
 						Required: true,
 					},
+					&cli.StringSliceFlag{
+						Name:     "input",
+						Usage:    "pipeline run inputs to validate as kv pair, --input version=44ffae, stage inputs left empty are assumed provided when not set",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:  "migrate-secrets",
+				Usage: "move plaintext credentials of pipelines saved before secrets into secrets",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := RunMigrateSecrets(); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					return nil
 				},
 			},
 			{
@@ -144,6 +179,234 @@ func Command() *cli.Command {
 					},
 				},
 			},
+			{
+				Name:  "trigger",
+				Usage: "trigger pipeline runs from github push, tag, release or merged pull request events",
+				Commands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "add pipeline trigger",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							trigger := Trigger{
+								Event:  c.String("event"),
+								Repo:   c.String("repo"),
+								Branch: c.String("branch"),
+								Tag:    c.String("tag"),
+								Inputs: parseKeyValuePairs(c.StringSlice("input")),
+							}
+							if err := AddPipelineTrigger(ctx, c.String("name"), trigger); err != nil {
+								fmt.Printf("%v\n", err)
+								return err
+							}
+							return ShowPipelineTriggers(c.String("name"), c.String("output"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "event",
+								Usage:    "github event push, tag, release(published) or pull_request(merged)",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "repo",
+								Usage:    "repo event originates from as org/repo",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "branch",
+								Usage:    "branch glob for push and pull_request base branch, defaults to main",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "tag",
+								Usage:    "tag glob for tag and release events, eg: v*",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "input",
+								Usage:    "pipeline input templated from event as kv pair, --input version='${{ event.after }}' --input tag='${{ event.ref_name }}'",
+								Required: false,
+							},
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "list pipeline triggers",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := ShowPipelineTriggers(c.String("name"), c.String("output")); err != nil {
+								return helpers.PrintError(c.String("output"), err)
+							}
+							return nil
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:  "delete",
+						Usage: "delete pipeline trigger",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := DeletePipelineTrigger(ctx, c.String("name"), int(c.Int("index"))-1); err != nil {
+								fmt.Printf("%v\n", err)
+								return err
+							}
+							return ShowPipelineTriggers(c.String("name"), c.String("output"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:     "index",
+								Usage:    "trigger number shown in trigger list",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "notification",
+				Usage: "notify webhook, slack or email when pipeline runs and stages change state",
+				Commands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "add pipeline notification",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							notification := Notification{
+								Type:   c.String("type"),
+								Url:    c.String("url"),
+								Secret: c.String("secret"),
+								Events: c.StringSlice("event"),
+							}
+							if notification.Type == NOTIFICATION_EMAIL {
+								notification.Smtp = &SmtpInfo{
+									Host:     c.String("smtp-host"),
+									Port:     int(c.Int("smtp-port")),
+									Username: c.String("smtp-username"),
+									Password: c.String("smtp-password"),
+									From:     c.String("from"),
+									To:       c.StringSlice("to"),
+								}
+							}
+							if err := AddPipelineNotification(ctx, c.String("name"), notification); err != nil {
+								fmt.Printf("%v\n", err)
+								return err
+							}
+							return ShowPipelineNotifications(c.String("name"), c.String("output"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "type",
+								Usage:    "notification type webhook, slack or email",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "url",
+								Usage:    "webhook or slack incoming webhook url",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "secret",
+								Usage:    "webhook payload is signed with hmac sha256 in X-Pippy-Signature-256 header, saved in secrets, secret:name or env:NAME refer to existing",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "event",
+								Usage:    "events run_started, stage_succeeded, stage_failed, approval_pending, rollback, run_completed, all when not provided",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "smtp-host",
+								Usage:    "smtp host for email",
+								Required: false,
+							},
+							&cli.IntFlag{
+								Name:     "smtp-port",
+								Usage:    "smtp port for email",
+								Value:    587,
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "smtp-username",
+								Usage:    "smtp username for email",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "smtp-password",
+								Usage:    "smtp password for email, saved in secrets, secret:name or env:NAME refer to existing",
+								Required: false,
+								Sources:  cli.EnvVars("PIPPY_SMTP_PASSWORD"),
+							},
+							&cli.StringFlag{
+								Name:     "from",
+								Usage:    "email sender",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "to",
+								Usage:    "email recipients",
+								Required: false,
+							},
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "list pipeline notifications",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := ShowPipelineNotifications(c.String("name"), c.String("output")); err != nil {
+								return helpers.PrintError(c.String("output"), err)
+							}
+							return nil
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:  "delete",
+						Usage: "delete pipeline notification",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := DeletePipelineNotification(ctx, c.String("name"), int(c.Int("index"))-1); err != nil {
+								fmt.Printf("%v\n", err)
+								return err
+							}
+							return ShowPipelineNotifications(c.String("name"), c.String("output"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:     "index",
+								Usage:    "notification number shown in notification list",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
 			{
 				Name:  "run",
 				Usage: "pipeline runs",
@@ -189,9 +452,8 @@ func Command() *cli.Command {
 						Name:  "list",
 						Usage: "show pipeline runs",
 						Action: func(ctx context.Context, c *cli.Command) error {
-							if err := ShowAllPipelineRuns(c.String("name"), c.Int64("count")); err != nil {
-								fmt.Printf("%v\n", err)
-								return err
+							if err := ShowAllPipelineRuns(c.String("name"), c.Int64("count"), c.String("output")); err != nil {
+								return helpers.PrintError(c.String("output"), err)
 							}
 							return nil
 						},
@@ -213,7 +475,33 @@ func Command() *cli.Command {
 						Name:  "show",
 						Usage: "show pipeline run details",
 						Action: func(ctx context.Context, c *cli.Command) error {
-							if err := ShowPipelineRun(c.String("name"), c.String("id")); err != nil {
+							if err := ShowPipelineRun(c.String("name"), c.String("id"), c.String("output"), c.Bool("jobs")); err != nil {
+								return helpers.PrintError(c.String("output"), err)
+							}
+							return nil
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "id",
+								Usage:    "pipeline run id",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "jobs",
+								Usage: "expand every job of stages, by default only failed and in progress jobs are shown",
+							},
+						},
+					},
+					{
+						Name:  "watch",
+						Usage: "live read only view of pipeline run executed elsewhere, exits once run completes",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := WatchPipelineRunUI(c.String("name"), c.String("id")); err != nil {
 								fmt.Printf("%v\n", err)
 								return err
 							}
@@ -232,6 +520,39 @@ func Command() *cli.Command {
 							},
 						},
 					},
+					{
+						Name:  "logs",
+						Usage: "print github actions job logs of stage workflow run, failed or in progress stage by default",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if err := RunPipelineRunLogs(c.String("name"), c.String("id"), c.Int("stage"), c.Bool("follow")); err != nil {
+								fmt.Printf("%v\n", err)
+								return err
+							}
+							return nil
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "pipeline name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "id",
+								Usage:    "pipeline run id",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:     "stage",
+								Usage:    "stage number starting at 1",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "follow",
+								Usage:    "keep printing logs of jobs as they complete while stage is in progress",
+								Required: false,
+							},
+						},
+					},
 					{
 						Name:  "approve",
 						Usage: "approve pipeline run for stage pending approval",
@@ -0,0 +1,197 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/helpers"
+
+	"github.com/nixmade/orchestrator/core"
+)
+
+var (
+	PrometheusComparisons = []string{">", ">=", "<", "<=", "==", "!="}
+)
+
+type PrometheusQuery struct {
+	Name string `json:"name,omitempty"`
+	// Query PromQL expression, $window is replaced with evaluation window eg: rate(http_requests_total{code=~"5.."}[$window])
+	Query string `json:"query"`
+	// Comparison fails the stage when query value compared with threshold is true, one of >, >=, <, <=, ==, !=
+	Comparison string  `json:"comparison"`
+	Threshold  float64 `json:"threshold"`
+}
+
+type PrometheusInfo struct {
+	Url     string            `json:"url"`
+	Queries []PrometheusQuery `json:"queries"`
+	// Window stage is monitored after workflow success eg: 15m, defaults to 15m
+	Window   string `json:"window,omitempty"`
+	Rollback bool   `json:"rollback"`
+}
+
+func (p *PrometheusInfo) window() time.Duration {
+	window, err := time.ParseDuration(p.Window)
+	if err != nil || window <= 0 {
+		return DefaultMonitoringWindowSecs * time.Second
+	}
+	return window
+}
+
+func (p *PrometheusInfo) windowSecs() int {
+	return int(p.window().Seconds())
+}
+
+// Validate checks url, queries and comparisons
+func (p *PrometheusInfo) Validate() error {
+	if _, err := url.ParseRequestURI(p.Url); err != nil {
+		return fmt.Errorf("invalid prometheus url %s, %v", p.Url, err)
+	}
+	if len(p.Queries) <= 0 {
+		return fmt.Errorf("prometheus queries cannot be empty")
+	}
+	for _, query := range p.Queries {
+		if query.Query == "" {
+			return fmt.Errorf("prometheus query cannot be empty")
+		}
+		if _, err := compare(query.Comparison, 0, 0); err != nil {
+			return err
+		}
+	}
+	if p.Window != "" {
+		if _, err := time.ParseDuration(p.Window); err != nil {
+			return fmt.Errorf("invalid prometheus window %s, %v", p.Window, err)
+		}
+	}
+	return nil
+}
+
+func compare(comparison string, value, threshold float64) (bool, error) {
+	switch comparison {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("invalid comparison %s, choose one of %s", comparison, strings.Join(PrometheusComparisons, " "))
+}
+
+type PrometheusMonitoringController struct {
+	*PrometheusInfo
+}
+
+type prometheusSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func sampleValue(value []interface{}) (float64, error) {
+	if len(value) != 2 {
+		return 0, fmt.Errorf("unexpected sample %v", value)
+	}
+	str, ok := value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", value[1])
+	}
+	return strconv.ParseFloat(str, 64)
+}
+
+func metricLabels(metric map[string]string) string {
+	if len(metric) <= 0 {
+		return ""
+	}
+	var labels []string
+	for key, value := range metric {
+		labels = append(labels, fmt.Sprintf("%s=%q", key, value))
+	}
+	sort.Strings(labels)
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func (p *PrometheusInfo) query(query PrometheusQuery) ([]prometheusSample, error) {
+	expr := strings.ReplaceAll(query.Query, "$window", p.window().String())
+	queryUrl := fmt.Sprintf("%s/api/v1/query?query=%s", strings.TrimRight(p.Url, "/"), url.QueryEscape(expr))
+	response, err := helpers.HttpGet(queryUrl, map[string]string{"Accept": "application/json"})
+	if err != nil {
+		return nil, fmt.Errorf("received error from prometheus %s", err)
+	}
+
+	result := prometheusResponse{}
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prometheus response %s", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed %s: %s", result.ErrorType, result.Error)
+	}
+
+	switch result.Data.ResultType {
+	case "vector":
+		var samples []prometheusSample
+		if err := json.Unmarshal(result.Data.Result, &samples); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prometheus vector %s", err)
+		}
+		return samples, nil
+	case "scalar":
+		var value []interface{}
+		if err := json.Unmarshal(result.Data.Result, &value); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prometheus scalar %s", err)
+		}
+		return []prometheusSample{{Value: value}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported prometheus result type %s", result.Data.ResultType)
+}
+
+// ExternalMonitoring evaluates every query, any series breaching threshold fails the stage, no data is healthy
+func (m *PrometheusMonitoringController) ExternalMonitoring(_ []*core.ClientState) error {
+	for _, query := range m.Queries {
+		name := query.Name
+		if name == "" {
+			name = query.Query
+		}
+
+		samples, err := m.query(query)
+		if err != nil {
+			return err
+		}
+
+		for _, sample := range samples {
+			value, err := sampleValue(sample.Value)
+			if err != nil {
+				return fmt.Errorf("prometheus query %s returned %s", name, err)
+			}
+			breached, err := compare(query.Comparison, value, query.Threshold)
+			if err != nil {
+				return err
+			}
+			if breached {
+				return fmt.Errorf("%w, prometheus query %s%s value %g %s %g", ErrMonitoringFailed, name, metricLabels(sample.Metric), value, query.Comparison, query.Threshold)
+			}
+		}
+	}
+
+	return nil
+}
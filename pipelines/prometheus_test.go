@@ -0,0 +1,176 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type prometheusStandIn struct {
+	lock    sync.Mutex
+	value   string
+	queries []string
+}
+
+func (p *prometheusStandIn) setValue(value string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.value = value
+}
+
+func (p *prometheusStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if r.URL.Path != "/api/v1/query" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query().Get("query")
+	p.queries = append(p.queries, query)
+	w.Header().Set("Content-Type", "application/json")
+	if query == "invalid(" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		return
+	}
+	if query == "scalar(1)" {
+		_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1704700000.123,"1"]}}`)
+		return
+	}
+	_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1704700000.123,"0.01"]},{"metric":{"job":"web"},"value":[1704700000.123,"%s"]}]}}`, p.value)
+}
+
+func TestPrometheusMonitoring(t *testing.T) {
+	standIn := &prometheusStandIn{value: "0.02"}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	info := &PrometheusInfo{
+		Url:     server.URL + "/",
+		Queries: []PrometheusQuery{{Name: "error rate", Query: `sum(rate(errors[$window])) by (job)`, Comparison: ">", Threshold: 0.05}},
+		Window:  "5m",
+	}
+	require.NoError(t, info.Validate())
+
+	controller := &PrometheusMonitoringController{PrometheusInfo: info}
+	require.NoError(t, controller.ExternalMonitoring(nil))
+	assert.Equal(t, []string{"sum(rate(errors[5m0s])) by (job)"}, standIn.queries)
+
+	standIn.setValue("0.5")
+	err := controller.ExternalMonitoring(nil)
+	require.ErrorIs(t, err, ErrMonitoringFailed)
+	assert.Contains(t, err.Error(), `prometheus query error rate{job="web"} value 0.5 > 0.05`)
+
+	info.Queries = []PrometheusQuery{{Query: "scalar(1)", Comparison: "==", Threshold: 1}}
+	require.ErrorIs(t, controller.ExternalMonitoring(nil), ErrMonitoringFailed)
+
+	// query errors are not monitoring failures
+	info.Queries = []PrometheusQuery{{Query: "invalid(", Comparison: ">", Threshold: 1}}
+	err = controller.ExternalMonitoring(nil)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrMonitoringFailed)
+
+	info.Queries = []PrometheusQuery{{Query: "up", Comparison: "=>", Threshold: 1}}
+	require.Error(t, info.Validate())
+}
+
+func TestStageMonitoringController(t *testing.T) {
+	stage := Stage{}
+	assert.Nil(t, stageMonitoringController(stage, "key", nil))
+	assert.Equal(t, 0, stageMonitoringWindowSecs(stage))
+
+	stage.Monitor.Prometheus = &PrometheusInfo{Window: "30m"}
+	assert.IsType(t, &PrometheusMonitoringController{}, stageMonitoringController(stage, "key", nil))
+	assert.Equal(t, 1800, stageMonitoringWindowSecs(stage))
+
+	// datadog events only are not monitored
+	stage.Monitor.Datadog = &DatadogInfo{Events: true}
+	assert.IsType(t, &PrometheusMonitoringController{}, stageMonitoringController(stage, "key", nil))
+
+	stage.Monitor.Datadog = &DatadogInfo{Monitors: []string{"1"}}
+	controller, ok := stageMonitoringController(stage, "key", nil).(*MonitoringControllers)
+	require.True(t, ok)
+	assert.Len(t, controller.Controllers, 2)
+}
+
+func TestOrchestratePrometheusRollback(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestratePrometheusRollback*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
+	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
+
+	standIn := &prometheusStandIn{value: "0"}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	newPipeline := &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Monitor: MonitorInfo{Prometheus: &PrometheusInfo{
+					Url:      server.URL,
+					Queries:  []PrometheusQuery{{Query: "errors", Comparison: ">", Threshold: 0.05}},
+					Window:   "1s",
+					Rollback: true,
+				}},
+				Input: map[string]string{"version": ""}},
+		},
+	}
+	o.pipeline = newPipeline
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, newPipeline.Stages[0]))
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	o.githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed"}}, afterDispatch: true}
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+	require.Equal(t, SUCCESS, o.stageStatus.GetState())
+	require.NotEmpty(t, standIn.queries)
+
+	// workflow succeeds but prometheus breaches threshold, stage is rolled back
+	standIn.setValue("0.5")
+	prevRunId := o.pipelineRunId
+	runId := uuid.New().String()
+	o.pipelineRunId = runId
+	o.targetVersion = runId
+	o.stageStatus = &status{m: make(map[string]*run)}
+	o.inputs = map[string]string{"version": "dummy4"}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, newPipeline.Stages[0]))
+	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	githubClient := &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed", Conclusion: "success"}}, afterDispatch: true, stageStatus: o.stageStatus}
+	o.githubClient = githubClient
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+
+	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
+	require.NotNil(t, stageRun.rollback)
+	assert.Equal(t, ROLLBACK, o.stageStatus.GetState())
+	assert.Equal(t, string(FAILED), stageRun.state)
+	assert.Contains(t, stageRun.reason, "prometheus query errors")
+	assert.Equal(t, prevRunId, stageRun.rollback.version)
+	assert.Equal(t, "Success", stageRun.rollback.state)
+	require.Len(t, githubClient.dispatches, 2)
+}
@@ -2,18 +2,15 @@ package pipelines
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path"
-	"strconv"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/nixmade/pippy/github"
-	"github.com/nixmade/pippy/helpers"
 	"github.com/nixmade/pippy/log"
 	"github.com/nixmade/pippy/store"
 	"github.com/nixmade/pippy/users"
@@ -54,6 +51,8 @@ type TriggerMetadata struct {
 	Login  string `json:"login"`
 	Email  string `json:"email"`
 	Reason string `json:"reason"`
+	// Source identity used, user login, token, github actions, github app or webhook
+	Source string `json:"source,omitempty"`
 }
 
 type StageRun struct {
@@ -69,6 +68,9 @@ type StageRun struct {
 	Rollback        *StageRun         `json:"rollback,omitempty"`
 	Metadata        StageRunMetadata  `json:"metadata,omitempty"`
 	ConcurrentRunId string            `json:"concurrent"`
+	DeploymentId    int64             `json:"deployment_id,omitempty"`
+	MonitorFailure  *MonitorFailure   `json:"monitor_failure,omitempty"`
+	Jobs            []JobSummary      `json:"jobs,omitempty"`
 }
 
 type PipelineRun struct {
@@ -97,6 +99,9 @@ type run struct {
 	version         string
 	inputs          map[string]string
 	concurrentRunId string
+	deploymentId    int64
+	monitorFailure  *MonitorFailure
+	jobs            []JobSummary
 }
 
 type status struct {
@@ -104,6 +109,8 @@ type status struct {
 	state State
 	lock  sync.RWMutex
 	cache map[string]*run
+	// pausedBy actor who paused run, only known to watchers reading audits
+	pausedBy string
 }
 
 func (s *status) Set(key string, value *run) {
@@ -122,6 +129,7 @@ func deepCopy(to *run, from *run) *run {
 	for k, v := range from.inputs {
 		to.inputs[k] = v
 	}
+	to.jobs = slices.Clone(from.jobs)
 	if from.rollback != nil {
 		to.rollback = deepCopy(&run{}, from.rollback)
 	}
@@ -163,6 +171,18 @@ func (s *status) GetState() State {
 	return s.state
 }
 
+func (s *status) UpdatePausedBy(pausedBy string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pausedBy = pausedBy
+}
+
+func (s *status) GetPausedBy() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pausedBy
+}
+
 func getStageName(i int, name string) string {
 	return fmt.Sprintf("%s-%d", name, i)
 }
@@ -234,37 +254,60 @@ func RunPipeline(ctx context.Context, name, runId string, inputs map[string]stri
 		return nil
 	}
 
-	if err := o.orchestrate(ctx, 5000); err != nil {
+	if err := o.orchestrate(ctx, TickInterval); err != nil {
 		o.logger.Error().Err(err).Msg("Failed to run async orchestrator")
 		//panic(err)
 		return err
 	}
 	o.logger.Info().Msg("orchestrator is done")
+	o.waitNotifications()
 
 	return nil
 }
 
+// manualTrigger trigger metadata of runs started by current user
+func manualTrigger() (TriggerMetadata, error) {
+	currentUser, err := users.CurrentUser()
+	if err != nil {
+		return TriggerMetadata{}, err
+	}
+
+	return TriggerMetadata{Name: currentUser.Name, Login: currentUser.Login, Email: currentUser.Email, Source: currentUser.Source, Reason: "Manual run"}, nil
+}
+
 func RunPipelineUI(name, runId string, inputs map[string]string, force bool) error {
-	userStore, err := users.GetCachedTokens()
+	trigger, err := manualTrigger()
 	if err != nil {
 		return err
 	}
 
-	trigger := TriggerMetadata{Name: userStore.GithubUser.Name, Login: userStore.GithubUser.Login, Email: userStore.GithubUser.Email, Reason: "Manual run"}
 	o, err := createOrchestrator(context.Background(), name, runId, inputs, nil, trigger, force)
 	if err != nil {
 		return err
 	}
 
-	o.run(context.Background())
-	defer o.wait()
+	// run held by cockpit, webhook server or another terminal is refused before UI starts
+	o.leaseOwner = uuid.NewString()
+	if err := acquireRunLease(context.Background(), o.pipeline.Name, o.pipelineRunId, o.leaseOwner); err != nil {
+		return err
+	}
 
-	// return nil
+	// notifications from final save in wait are delivered before exiting
+	defer o.waitNotifications()
 	p := tea.NewProgram(initialModel(o.pipeline, o.stageStatus, o.started.String(), o.pipelineRunId))
-	if _, err := p.Run(); err != nil {
+	o.run(context.Background(), func(err error) {
+		p.Send(orchestrateErrMsg{err: err})
+	})
+	defer o.wait()
+
+	finalModel, err := p.Run()
+	if err != nil {
 		o.logger.Error().Err(err).Msg("error running UI")
 		return err
 	}
+	if m, ok := finalModel.(model); ok && m.err != nil {
+		return m.err
+	}
 	return nil
 }
 
@@ -284,12 +327,18 @@ type orchestrator struct {
 	done          chan bool
 	paused        bool
 	wg            sync.WaitGroup
+	// notifications pending deliveries of notifications and datadog events for this run
+	notifications sync.WaitGroup
 	config        *core.Config
 	githubClient  github.Client
 	rollback      *rollbackInfo
 	targetVersion string
 	force         bool
 	trigger       TriggerMetadata
+	lastPolled    map[string]time.Time
+	jobsPolled    map[int64]jobsPoll
+	// leaseOwner holds run lease, set before orchestrate when the lease is taken up front
+	leaseOwner string
 }
 
 func (o *orchestrator) setConfig(ctx context.Context) error {
@@ -321,47 +370,6 @@ func (o *orchestrator) setConfig(ctx context.Context) error {
 	return nil
 }
 
-type MonitoringController struct {
-	*DatadogInfo
-}
-
-type MonitorState struct {
-	Name                 string `json:"name"`
-	OverallState         string `json:"overall_state"`
-	OverallStateModified string `json:"overall_state_modified"`
-}
-
-func (m *MonitoringController) ExternalMonitoring(_ []*core.ClientState) error {
-	for _, monitor := range m.Monitors {
-		monitorID, err := strconv.ParseInt(monitor, 10, 64)
-		if err != nil {
-			return fmt.Errorf("failed to parse monitor %s", monitor)
-		}
-
-		url := fmt.Sprintf("https://api.%s/api/v1/monitor/%d?group_states=all&with_downtimes=true", m.Site, monitorID)
-		headers := map[string]string{
-			"Accept":             "application/json",
-			"DD-API-KEY":         m.ApiKey,
-			"DD-APPLICATION-KEY": m.ApplicationKey,
-		}
-		response, err := helpers.HttpGet(url, headers)
-		if err != nil {
-			return fmt.Errorf("received error from datadog %s", err)
-		}
-
-		state := MonitorState{}
-		if err := json.Unmarshal([]byte(response), &state); err != nil {
-			return fmt.Errorf("failed to unmarshal datadog response %s", err)
-		}
-
-		if strings.EqualFold(state.OverallState, "alert") {
-			return fmt.Errorf("monitor %s in alert state since %s", monitor, state.OverallStateModified)
-		}
-	}
-
-	return nil
-}
-
 func (o *orchestrator) setupEngine() error {
 	var err error
 	o.logger.Info().Msg("setting up new orchestrator engine")
@@ -371,12 +379,12 @@ func (o *orchestrator) setupEngine() error {
 		return err
 	}
 
-	core.RegisteredMonitoringControllers = append(core.RegisteredMonitoringControllers, &MonitoringController{})
+	registerControllers()
 
 	o.options = &core.RolloutOptions{
 		BatchPercent:        1,
 		SuccessPercent:      100,
-		SuccessTimeoutSecs:  0,
+		SuccessTimeoutSecs:  RolloutSettleSecs,
 		DurationTimeoutSecs: 3600,
 	}
 
@@ -434,6 +442,9 @@ func setStageRun(stageRun *StageRun, status *run) {
 	stageRun.Completed = status.completed
 	stageRun.Reason = status.reason
 	stageRun.ConcurrentRunId = status.concurrentRunId
+	stageRun.DeploymentId = status.deploymentId
+	stageRun.MonitorFailure = status.monitorFailure
+	stageRun.Jobs = slices.Clone(status.jobs)
 	for key, value := range status.inputs {
 		stageRun.Input[key] = value
 	}
@@ -455,6 +466,9 @@ func loadStageRun(stageRun *StageRun) *run {
 		completed:       stageRun.Completed,
 		reason:          stageRun.Reason,
 		concurrentRunId: stageRun.ConcurrentRunId,
+		deploymentId:    stageRun.DeploymentId,
+		monitorFailure:  stageRun.MonitorFailure,
+		jobs:            slices.Clone(stageRun.Jobs),
 	}
 	approval := stageRun.Metadata.Approval
 	if approval.Name != "" || approval.Login != "" {
@@ -468,12 +482,15 @@ func loadStageRun(stageRun *StageRun) *run {
 
 func (o *orchestrator) savePipelineRun(ctx context.Context) error {
 	o.logger.Info().Msg("begin saving pipeline run")
+	var previous *PipelineRun
 	pipelineRun, err := GetPipelineRun(ctx, o.pipeline.Name, o.pipelineRunId)
 	if errors.Is(err, store.ErrKeyNotFound) {
 		o.logger.Warn().Msg("pipeline run not found creating new")
 		pipelineRun = &PipelineRun{Id: o.pipelineRunId, PipelineName: o.pipeline.Name, Paused: false, Created: time.Now().UTC(), Trigger: o.trigger}
 	} else if err != nil {
 		return nil
+	} else {
+		previous = &PipelineRun{State: pipelineRun.State, Stages: slices.Clone(pipelineRun.Stages)}
 	}
 
 	pipelineRun.State = string(o.stageStatus.GetState())
@@ -499,5 +516,10 @@ func (o *orchestrator) savePipelineRun(ctx context.Context) error {
 
 	o.logger.Info().Msg("saving pipeline run")
 
-	return savePipelineRun(ctx, pipelineRun)
+	if err := savePipelineRun(ctx, pipelineRun); err != nil {
+		return err
+	}
+
+	o.notify(notificationEvents(previous, pipelineRun))
+	return nil
 }
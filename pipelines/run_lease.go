@@ -0,0 +1,132 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/store"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RunLeasePrefix = "runlease:"
+)
+
+var (
+	// RunLeaseDuration is how long a pipeline run is held by its orchestrator without renewal,
+	// a run left behind by a crashed orchestrator can be continued once it expires
+	RunLeaseDuration = time.Minute
+
+	// runLeaseLock serializes lease updates from orchestrators running in this process
+	runLeaseLock sync.Mutex
+)
+
+// RunLease pipeline run held by a single orchestrator
+type RunLease struct {
+	Owner   string    `json:"owner"`
+	Host    string    `json:"host"`
+	Expires time.Time `json:"expires"`
+}
+
+func runLeaseKey(name, id string) string {
+	return fmt.Sprintf("%s%s/%s", RunLeasePrefix, name, id)
+}
+
+// acquireRunLease takes or renews run lease for owner, runs held by another orchestrator are refused
+func acquireRunLease(ctx context.Context, name, id, owner string) error {
+	runLeaseLock.Lock()
+	defer runLeaseLock.Unlock()
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	lease := &RunLease{}
+	if err := dbStore.LoadJSON(runLeaseKey(name, id), lease); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return err
+	}
+	if lease.Owner != "" && lease.Owner != owner && time.Now().UTC().Before(lease.Expires) {
+		return fmt.Errorf("pipeline run %s is orchestrated on %s until %s", id, lease.Host, lease.Expires.Format(time.RFC3339))
+	}
+
+	host, _ := os.Hostname()
+	lease = &RunLease{Owner: owner, Host: host, Expires: time.Now().UTC().Add(RunLeaseDuration)}
+	return dbStore.SaveJSON(runLeaseKey(name, id), lease)
+}
+
+// releaseRunLease drops run lease when it is still held by owner
+func releaseRunLease(ctx context.Context, name, id, owner string) error {
+	runLeaseLock.Lock()
+	defer runLeaseLock.Unlock()
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	lease := &RunLease{}
+	if err := dbStore.LoadJSON(runLeaseKey(name, id), lease); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+	if lease.Owner != owner {
+		return nil
+	}
+	return dbStore.Delete(runLeaseKey(name, id))
+}
+
+// holdRunLease takes run lease and renews it until returned func is called, which releases it
+func (o *orchestrator) holdRunLease(ctx context.Context) (func(), error) {
+	if o.leaseOwner == "" {
+		o.leaseOwner = uuid.NewString()
+	}
+	owner := o.leaseOwner
+	if err := acquireRunLease(ctx, o.pipeline.Name, o.pipelineRunId, owner); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var renewals sync.WaitGroup
+	renewals.Add(1)
+	go func() {
+		defer renewals.Done()
+		ticker := time.NewTicker(RunLeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := acquireRunLease(context.Background(), o.pipeline.Name, o.pipelineRunId, owner); err != nil {
+					o.logger.Error().Err(err).Msg("failed to renew pipeline run lease")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		renewals.Wait()
+		if err := releaseRunLease(context.Background(), o.pipeline.Name, o.pipelineRunId, owner); err != nil {
+			o.logger.Error().Err(err).Msg("failed to release pipeline run lease")
+		}
+	}, nil
+}
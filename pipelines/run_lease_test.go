@@ -0,0 +1,99 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLease(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestRunLease*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	ctx := context.Background()
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run1", "owner1"))
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run1", "owner1"))
+	require.ErrorContains(t, acquireRunLease(ctx, "Pipeline1", "run1", "owner2"), "pipeline run run1 is orchestrated on")
+
+	// other runs are not held
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run2", "owner2"))
+
+	// lease is released only by its owner
+	require.NoError(t, releaseRunLease(ctx, "Pipeline1", "run1", "owner2"))
+	require.Error(t, acquireRunLease(ctx, "Pipeline1", "run1", "owner2"))
+	require.NoError(t, releaseRunLease(ctx, "Pipeline1", "run1", "owner1"))
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run1", "owner2"))
+
+	// lease left behind by a crashed orchestrator expires
+	duration := RunLeaseDuration
+	RunLeaseDuration = -time.Second
+	defer func() {
+		RunLeaseDuration = duration
+	}()
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run3", "owner1"))
+	require.NoError(t, acquireRunLease(ctx, "Pipeline1", "run3", "owner2"))
+}
+
+func TestOrchestrateRunLeased(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateRunLeased*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	o.githubClient = newTestGithubClient()
+
+	// run continued elsewhere, eg: pipeline run execute --id while cockpit continues it
+	ctx := context.Background()
+	require.NoError(t, acquireRunLease(ctx, defaultTestPipeline.Name, o.pipelineRunId, "other"))
+	require.ErrorContains(t, o.orchestrate(ctx, 60000), "is orchestrated on")
+
+	_, err = GetPipelineRun(ctx, defaultTestPipeline.Name, o.pipelineRunId)
+	require.ErrorIs(t, err, store.ErrKeyNotFound)
+
+	// lease is released once orchestrator is done
+	require.NoError(t, releaseRunLease(ctx, defaultTestPipeline.Name, o.pipelineRunId, "other"))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, o.orchestrate(cancelled, 60000), context.Canceled)
+	require.NoError(t, acquireRunLease(ctx, defaultTestPipeline.Name, o.pipelineRunId, "other"))
+}
+
+func TestRunPipelineUIRunLeased(t *testing.T) {
+	setup := setupE2E(t, []Stage{{}})
+
+	// pipeline run execute --id while webhook server or cockpit continues the run
+	require.NoError(t, acquireRunLease(setup.ctx, "E2E", "leased", "other"))
+	require.ErrorContains(t, RunPipelineUI("E2E", "leased", map[string]string{"version": "v1"}, false), "pipeline run leased is orchestrated on")
+
+	_, err := GetPipelineRun(setup.ctx, "E2E", "leased")
+	require.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func TestRunUIOrchestrateError(t *testing.T) {
+	m := initialModel(defaultTestPipeline, &status{m: make(map[string]*run)}, "now", "run1")
+	updated, cmd := m.Update(orchestrateErrMsg{err: fmt.Errorf("pipeline run run1 is orchestrated on host1")})
+	require.NotNil(t, cmd)
+	assert.IsType(t, tea.QuitMsg{}, cmd())
+	assert.Contains(t, updated.View(), "pipeline run run1 is orchestrated on host1")
+	assert.EqualError(t, updated.(model).err, "pipeline run run1 is orchestrated on host1")
+}
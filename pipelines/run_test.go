@@ -6,6 +6,7 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +33,25 @@ var (
 	}
 )
 
+// holdStore keeps store open until returned func is called, orchestrator saves run on every tick
+// which otherwise reopens badger each time
+func holdStore(t *testing.T) func() {
+	dbStore, err := store.Get(context.Background())
+	require.NoError(t, err)
+	return func() {
+		assert.NoError(t, store.Close(dbStore))
+	}
+}
+
+// skipRolloutSettle completes unmonitored stages as soon as workflow succeeds instead of waiting for a second
+func skipRolloutSettle(t *testing.T) {
+	settleSecs := RolloutSettleSecs
+	RolloutSettleSecs = -1
+	t.Cleanup(func() {
+		RolloutSettleSecs = settleSecs
+	})
+}
+
 func setConfig(o *orchestrator) {
 	config := core.NewDefaultConfig()
 
@@ -51,12 +71,21 @@ type dispatch struct {
 	inputs    map[string]interface{}
 }
 
+type deployment struct {
+	org, repo, ref, environment string
+	statuses                    []string
+}
+
 type runGithubClient struct {
 	workflowRuns  []github.WorkflowRun
 	dispatches    []dispatch
 	dispatchErr   error
 	afterDispatch bool
 	stageStatus   *status
+	listCalls     int
+	jobsCalls     int
+	deployments   []deployment
+	rateLimit     *github.RateLimit
 }
 
 func newTestGithubClient() *runGithubClient {
@@ -73,6 +102,7 @@ func (t *runGithubClient) ListWorkflows(org, repo string) ([]github.Workflow, er
 	return nil, nil
 }
 func (t *runGithubClient) ListWorkflowRuns(org, repo string, workflowID int64, created string) ([]github.WorkflowRun, error) {
+	t.listCalls++
 	dispatched := false
 	for _, dispatch := range t.dispatches {
 		dispatched = dispatch.id == workflowID
@@ -88,6 +118,24 @@ func (t *runGithubClient) ListWorkflowRuns(org, repo string, workflowID int64, c
 	}
 	return nil, nil
 }
+func (t *runGithubClient) FindWorkflowRun(org, repo string, workflowID int64, created, match string) (*github.WorkflowRun, error) {
+	workflowRuns, err := t.ListWorkflowRuns(org, repo, workflowID, created)
+	if err != nil {
+		return nil, err
+	}
+	for _, workflowRun := range workflowRuns {
+		if strings.Contains(workflowRun.Name, match) {
+			return &workflowRun, nil
+		}
+	}
+	return nil, nil
+}
+func (t *runGithubClient) CurrentRateLimit() *github.RateLimit {
+	return t.rateLimit
+}
+func (t *runGithubClient) ListRateLimits() ([]github.RateLimit, error) {
+	return nil, nil
+}
 func (t *runGithubClient) CreateWorkflowDispatch(org, repo string, workflowID int64, ref string, inputs map[string]interface{}) error {
 	t.dispatches = append(t.dispatches, dispatch{org: org, repo: repo, id: workflowID, inputs: maps.Clone(inputs)})
 	return t.dispatchErr
@@ -101,6 +149,31 @@ func (t *runGithubClient) ValidateWorkflowFull(org, repo, path string) (string,
 	return "", "", nil
 }
 
+func (t *runGithubClient) ListWorkflowJobs(org, repo string, runID int64) ([]github.WorkflowJob, error) {
+	t.jobsCalls++
+	return nil, nil
+}
+
+func (t *runGithubClient) GetWorkflowRunLogs(org, repo string, runID int64) ([]github.WorkflowLog, error) {
+	return nil, nil
+}
+
+func (t *runGithubClient) GetWorkflowJobLogs(org, repo string, jobID int64) (string, error) {
+	return "", nil
+}
+
+func (t *runGithubClient) ListWorkflowInputs(org, repo, path string) ([]github.WorkflowInput, error) {
+	return nil, nil
+}
+
+func (t *runGithubClient) FindPullRequest(org, repo, branch string) (*github.PullRequest, error) {
+	return nil, nil
+}
+
+func (t *runGithubClient) CreatePullRequest(org, repo, branch, title, body string, files map[string]string) (*github.PullRequest, error) {
+	return nil, nil
+}
+
 func (t *runGithubClient) GetWorkflow(org, repo string, id int64) (*github.Workflow, error) {
 	return nil, nil
 }
@@ -109,6 +182,19 @@ func (t *runGithubClient) ListOrgsForUser() ([]github.Org, error) {
 	return nil, nil
 }
 
+func (t *runGithubClient) CreateDeployment(org, repo, ref, environment, description string, payload map[string]interface{}) (int64, error) {
+	t.deployments = append(t.deployments, deployment{org: org, repo: repo, ref: ref, environment: environment})
+	return int64(len(t.deployments)), nil
+}
+
+func (t *runGithubClient) CreateDeploymentStatus(org, repo string, deploymentID int64, state, environment, logUrl, description string) error {
+	if deploymentID <= 0 || deploymentID > int64(len(t.deployments)) {
+		return fmt.Errorf("deployment %d not found", deploymentID)
+	}
+	t.deployments[deploymentID-1].statuses = append(t.deployments[deploymentID-1].statuses, state)
+	return nil
+}
+
 func setupOrchestrator(*testing.T) *orchestrator {
 	logger := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	if testing.Verbose() {
@@ -145,6 +231,7 @@ func TestSaveLoadPipelineRun(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	require.NoError(t, o.loadPipelineRun(context.Background()))
 
@@ -183,6 +270,7 @@ func TestOrchestrateGood(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -201,7 +289,7 @@ func TestOrchestrateGood(t *testing.T) {
 
 	var runs []github.WorkflowRun
 	for i, stage := range newPipeline.Stages {
-		err = o.getCurrentState(i, stage)
+		err = o.getCurrentState(context.Background(), i, stage)
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(i, stage.Workflow.Name))
@@ -227,6 +315,7 @@ func TestOrchestrateBad(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -245,7 +334,7 @@ func TestOrchestrateBad(t *testing.T) {
 	o.githubClient = newTestGithubClient()
 	var runs []github.WorkflowRun
 	for i, stage := range newPipeline.Stages {
-		err = o.getCurrentState(i, stage)
+		err = o.getCurrentState(context.Background(), i, stage)
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(i, stage.Workflow.Name))
@@ -275,6 +364,7 @@ func TestOrchestrateIgnoreFailures(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -295,7 +385,7 @@ func TestOrchestrateIgnoreFailures(t *testing.T) {
 	o.githubClient = newTestGithubClient()
 	var runs []github.WorkflowRun
 	for i, stage := range newPipeline.Stages {
-		err = o.getCurrentState(i, stage)
+		err = o.getCurrentState(context.Background(), i, stage)
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(i, stage.Workflow.Name))
@@ -327,6 +417,7 @@ func TestOrchestrateApproval(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -345,7 +436,7 @@ func TestOrchestrateApproval(t *testing.T) {
 	setConfig(o)
 	testGithubClient := newTestGithubClient()
 	o.githubClient = testGithubClient
-	err = o.getCurrentState(0, newPipeline.Stages[0])
+	err = o.getCurrentState(context.Background(), 0, newPipeline.Stages[0])
 	require.NoError(t, err)
 
 	status := o.stageStatus.Get(getStageName(0, "Workflow1"))
@@ -372,6 +463,7 @@ func TestOrchestratePaused(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	o.githubClient = newTestGithubClient()
 
@@ -388,6 +480,29 @@ func TestOrchestratePaused(t *testing.T) {
 	assert.Equal(t, PAUSED, o.stageStatus.GetState())
 }
 
+func TestOrchestrateContextDone(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateContextDone*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	o.githubClient = newTestGithubClient()
+
+	// server shutdown cancels triggered runs, run is saved to resume later
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, o.orchestrate(ctx, 60000), context.Canceled)
+
+	_, err = GetPipelineRun(context.Background(), defaultTestPipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+}
+
 func TestOrchestrateApprovalMulti(t *testing.T) {
 	o := setupOrchestrator(t)
 
@@ -398,6 +513,7 @@ func TestOrchestrateApprovalMulti(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -417,7 +533,7 @@ func TestOrchestrateApprovalMulti(t *testing.T) {
 
 		testGithubClient := newTestGithubClient()
 		o.githubClient = testGithubClient
-		err = o.getCurrentState(0, newPipeline.Stages[0])
+		err = o.getCurrentState(context.Background(), 0, newPipeline.Stages[0])
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(0, "Workflow1"))
@@ -440,7 +556,7 @@ func TestOrchestrateApprovalMulti(t *testing.T) {
 	{
 		testGithubClient := newTestGithubClient()
 		o2.githubClient = testGithubClient
-		err = o2.getCurrentState(0, newPipeline.Stages[0])
+		err = o2.getCurrentState(context.Background(), 0, newPipeline.Stages[0])
 		require.NoError(t, err)
 
 		status := o2.stageStatus.Get(getStageName(0, "Workflow1"))
@@ -472,6 +588,7 @@ func TestOrchestrateRollback(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
 	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
@@ -488,7 +605,7 @@ func TestOrchestrateRollback(t *testing.T) {
 	}
 	o.pipeline = newPipeline
 
-	err = o.getCurrentState(0, newPipeline.Stages[0])
+	err = o.getCurrentState(context.Background(), 0, newPipeline.Stages[0])
 	require.NoError(t, err)
 
 	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
@@ -512,7 +629,7 @@ func TestOrchestrateRollback(t *testing.T) {
 	o.stageStatus = &status{m: make(map[string]*run)}
 	o.inputs = map[string]string{"version": "dummy4"}
 
-	err = o.getCurrentState(0, defaultTestPipeline.Stages[0])
+	err = o.getCurrentState(context.Background(), 0, defaultTestPipeline.Stages[0])
 	require.NoError(t, err)
 
 	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
@@ -554,6 +671,7 @@ func TestOrchestrateBadDispatchErr(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -572,7 +690,7 @@ func TestOrchestrateBadDispatchErr(t *testing.T) {
 	o.githubClient = newTestGithubClient()
 	//var runs []github.WorkflowRun
 	for i, stage := range newPipeline.Stages {
-		err = o.getCurrentState(i, stage)
+		err = o.getCurrentState(context.Background(), i, stage)
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(i, stage.Workflow.Name))
@@ -602,6 +720,27 @@ func TestOrchestrateBadDispatchErr(t *testing.T) {
 	require.Len(t, githubClient.dispatches, 1)
 }
 
+func TestOrchestrateTickErrorSaved(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateTickErrorSaved*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	o.githubClient = &runGithubClient{dispatchErr: fmt.Errorf("dispatch rejected")}
+	require.ErrorContains(t, o.orchestrate(context.Background(), 1), "dispatch rejected")
+
+	// failed stage and reason are saved, tick error is not lost with the orchestrator
+	pipelineRun, err := GetPipelineRun(context.Background(), defaultTestPipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+	assert.Equal(t, "Workflow_Failed", pipelineRun.Stages[0].State)
+	assert.Contains(t, pipelineRun.Stages[0].Reason, "dispatch rejected")
+}
+
 func TestOrchestrateConcurrentError(t *testing.T) {
 	o := setupOrchestrator(t)
 
@@ -612,6 +751,7 @@ func TestOrchestrateConcurrentError(t *testing.T) {
 		assert.NoError(t, os.RemoveAll(tempDir))
 	}()
 	store.HomeDir = tempDir
+	defer holdStore(t)()
 
 	newPipeline := &Pipeline{
 		Name: "Pipeline1",
@@ -631,7 +771,7 @@ func TestOrchestrateConcurrentError(t *testing.T) {
 	o.githubClient = newTestGithubClient()
 	var runs []github.WorkflowRun
 	for i, stage := range newPipeline.Stages {
-		err = o.getCurrentState(i, stage)
+		err = o.getCurrentState(context.Background(), i, stage)
 		require.NoError(t, err)
 
 		status := o.stageStatus.Get(getStageName(i, stage.Workflow.Name))
@@ -695,3 +835,170 @@ func TestOrchestrateConcurrentError(t *testing.T) {
 	require.Equal(t, "InProgress", currentRun.state)
 	require.Len(t, githubClient.dispatches, 1)
 }
+
+func TestOrchestrateWebhookWorkflowRuns(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateWebhookWorkflowRuns*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	githubClient := newTestGithubClient()
+	o.githubClient = githubClient
+	require.NoError(t, o.getCurrentState(context.Background(), 0, defaultTestPipeline.Stages[0]))
+	require.Equal(t, 1, githubClient.listCalls)
+
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+	require.Equal(t, "Workflow_Unknown", stageRun.state)
+
+	pushedRun := github.WorkflowRun{Name: "Workflow1 - " + stageRun.runId, Status: "completed", Conclusion: "success", Url: "pushedurl", UpdatedAt: time.Now().UTC()}
+	stageRunId, err := UpdateWorkflowRun(context.Background(), pushedRun)
+	require.NoError(t, err)
+	require.Equal(t, stageRun.runId, stageRunId)
+
+	// pushed workflow run is used without polling github
+	require.NoError(t, o.getCurrentState(context.Background(), 0, defaultTestPipeline.Stages[0]))
+	require.Equal(t, 1, githubClient.listCalls)
+
+	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
+	assert.Equal(t, "Workflow_Success", stageRun.state)
+	assert.Equal(t, "pushedurl", stageRun.runUrl)
+
+	// completed run is no longer kept
+	completedRun, err := GetWorkflowRunUpdate(context.Background(), stageRun.runId)
+	require.NoError(t, err)
+	assert.Nil(t, completedRun)
+
+	// fallback polling once interval expires
+	o.lastPolled[stageRun.runId] = time.Now().Add(-WebhookFallbackPollInterval)
+	stageRun.state = "InProgress"
+	require.NoError(t, o.getCurrentState(context.Background(), 0, defaultTestPipeline.Stages[0]))
+	require.Equal(t, 2, githubClient.listCalls)
+}
+
+func TestPruneWorkflowRunUpdates(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestPruneWorkflowRunUpdates*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	staleRunId := uuid.NewString()
+	staleRun := github.WorkflowRun{Name: "Workflow1 - " + staleRunId, Status: "in_progress", UpdatedAt: time.Now().UTC().Add(-2 * WorkflowRunUpdateRetention)}
+	_, err = UpdateWorkflowRun(context.Background(), staleRun)
+	require.NoError(t, err)
+
+	// stale run is kept until another run is pushed
+	pushedRun, err := GetWorkflowRunUpdate(context.Background(), staleRunId)
+	require.NoError(t, err)
+	require.NotNil(t, pushedRun)
+
+	freshRunId := uuid.NewString()
+	freshRun := github.WorkflowRun{Name: "Workflow1 - " + freshRunId, Status: "in_progress", UpdatedAt: time.Now().UTC()}
+	_, err = UpdateWorkflowRun(context.Background(), freshRun)
+	require.NoError(t, err)
+
+	pushedRun, err = GetWorkflowRunUpdate(context.Background(), staleRunId)
+	require.NoError(t, err)
+	assert.Nil(t, pushedRun)
+
+	pushedRun, err = GetWorkflowRunUpdate(context.Background(), freshRunId)
+	require.NoError(t, err)
+	assert.NotNil(t, pushedRun)
+}
+
+func TestOrchestratePollInterval(t *testing.T) {
+	o := setupOrchestrator(t)
+	githubClient := newTestGithubClient()
+	o.githubClient = githubClient
+
+	interval := 5 * time.Second
+	assert.Equal(t, interval, o.pollInterval(interval))
+
+	githubClient.rateLimit = &github.RateLimit{Resource: github.RATE_LIMIT_CORE, Limit: 5000, Remaining: 4000, Reset: time.Now().Add(time.Hour)}
+	assert.Equal(t, interval, o.pollInterval(interval))
+
+	// low quota slows polling
+	githubClient.rateLimit.Remaining = 100
+	assert.Equal(t, LowRateLimitPollInterval, o.pollInterval(interval))
+
+	// exhausted quota waits for reset
+	githubClient.rateLimit.Remaining = 0
+	assert.Greater(t, o.pollInterval(interval), 59*time.Minute)
+}
+
+func TestOrchestrateDeployments(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestOrchestrateDeployments*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+	skipRolloutSettle(t)
+
+	o.config.StoreDirectory = filepath.Join(tempDir, "orchestrator")
+	require.NoError(t, os.MkdirAll(o.config.StoreDirectory, os.ModePerm))
+
+	newPipeline := &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow:    expectedWorkflows["org1/repo1"][0],
+				Monitor:     MonitorInfo{Workflow: WorkflowInfo{Rollback: true}},
+				Input:       map[string]string{"version": ""},
+				Environment: "production"},
+		},
+	}
+	o.pipeline = newPipeline
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, newPipeline.Stages[0]))
+	stageRun := o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	githubClient := &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed"}}, afterDispatch: true}
+	o.githubClient = githubClient
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+	require.Equal(t, SUCCESS, o.stageStatus.GetState())
+
+	require.Len(t, githubClient.deployments, 1)
+	assert.Equal(t, deployment{org: "org1", repo: "repo1", ref: "main", environment: "production", statuses: []string{DEPLOYMENT_IN_PROGRESS, DEPLOYMENT_SUCCESS}}, githubClient.deployments[0])
+
+	pipelineRun, err := GetPipelineRun(context.Background(), newPipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pipelineRun.Stages[0].DeploymentId)
+
+	// failed rollout is rolled back, rollback is reported as a new deployment
+	runId := uuid.New().String()
+	o.pipelineRunId = runId
+	o.targetVersion = runId
+	o.stageStatus = &status{m: make(map[string]*run)}
+	o.inputs = map[string]string{"version": "dummy4"}
+
+	require.NoError(t, o.getCurrentState(context.Background(), 0, newPipeline.Stages[0]))
+	stageRun = o.stageStatus.Get(getStageName(0, "Workflow1"))
+
+	githubClient = &runGithubClient{workflowRuns: []github.WorkflowRun{{Name: stageRun.runId, Status: "completed", Conclusion: "failure"}}, afterDispatch: true, stageStatus: o.stageStatus}
+	o.githubClient = githubClient
+	require.NoError(t, o.orchestrate(context.Background(), 1))
+
+	require.Len(t, githubClient.deployments, 2)
+	assert.Equal(t, []string{DEPLOYMENT_IN_PROGRESS, DEPLOYMENT_FAILURE}, githubClient.deployments[0].statuses)
+	assert.Equal(t, []string{DEPLOYMENT_IN_PROGRESS, DEPLOYMENT_SUCCESS}, githubClient.deployments[1].statuses)
+
+	pipelineRun, err = GetPipelineRun(context.Background(), newPipeline.Name, runId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pipelineRun.Stages[0].DeploymentId)
+	require.NotNil(t, pipelineRun.Stages[0].Rollback)
+	assert.Equal(t, int64(2), pipelineRun.Stages[0].Rollback.DeploymentId)
+}
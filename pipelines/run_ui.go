@@ -2,6 +2,7 @@ package pipelines
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -31,6 +32,17 @@ type model struct {
 	stages      []string
 	startedAt   string
 	stageStatus *status
+	// expand shows every job of stages, toggled with e
+	expand bool
+	// watch is read only view of run orchestrated elsewhere, it keeps running while run is paused
+	watch bool
+	// err orchestrator failure, UI exits with it
+	err error
+}
+
+// orchestrateErrMsg orchestrator running in background failed
+type orchestrateErrMsg struct {
+	err error
 }
 
 func initialModel(pipeline *Pipeline, stageStatus *status, startedAt string, runId string) model {
@@ -61,23 +73,38 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
+		case "e":
+			m.expand = !m.expand
+			return m, nil
 		}
+	case orchestrateErrMsg:
+		m.err = msg.err
+		return m, tea.Quit
 	default:
-		switch m.stageStatus.GetState() {
-		case SUCCESS, FAILED, PAUSED, LOCKED, ROLLBACK:
+		if m.done(m.stageStatus.GetState()) {
 			return m, tea.Quit
-		default:
-			var cmd tea.Cmd
-			m.spinner, cmd = m.spinner.Update(msg)
-			return m, cmd
 		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	}
 
 	return m, nil
 }
 
+// done whether UI exits at state, watching exits only once run completes
+func (m model) done(state State) bool {
+	if m.watch {
+		return slices.Contains([]State{SUCCESS, FAILED, ROLLBACK, CANCELED}, state)
+	}
+	return slices.Contains([]State{SUCCESS, FAILED, PAUSED, LOCKED, ROLLBACK}, state)
+}
+
 func (m model) View() string {
 	s := currentStyle.Render(fmt.Sprintf("Running pipeline %s using run id %s, started at %s", m.name, m.runId, m.startedAt)) + "\n\n"
+	if m.watch {
+		s = currentStyle.Render(fmt.Sprintf("Watching pipeline %s run id %s, started at %s", m.name, m.runId, m.startedAt)) + "\n\n"
+	}
 
 	for i, stageName := range m.stages {
 		if status := m.stageStatus.GetCache(getStageName(i, stageName)); status != nil {
@@ -91,6 +118,7 @@ func (m model) View() string {
 				if status.approvedBy != "" {
 					s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(status.approvedBy)
 				}
+				s += renderJobs(status.jobs, m.expand)
 				s += "\n"
 				continue
 			} else if strings.EqualFold(status.state, "InProgress") {
@@ -99,6 +127,7 @@ func (m model) View() string {
 				if status.approvedBy != "" {
 					s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(status.approvedBy)
 				}
+				s += renderJobs(status.jobs, m.expand)
 				if status.rollback != nil {
 					rollbackTitle := stageName
 					if status.rollback.title != "" {
@@ -119,6 +148,7 @@ func (m model) View() string {
 				if status.approvedBy != "" {
 					s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(status.approvedBy)
 				}
+				s += renderJobs(status.jobs, m.expand)
 				if status.rollback != nil {
 					s += warningStyle.Faint(true).Render("\n    Rollback " + status.rollback.state + " " + status.rollback.title)
 					s += warningStyle.Faint(true).Render("\n    	" + status.rollback.runUrl)
@@ -161,8 +191,26 @@ func (m model) View() string {
 	}
 
 	if m.stageStatus.GetState() == PAUSED {
-		s += "\n" + clockMark.Render() + " " + warningStyle.Render(fmt.Sprintf("Paused pipeline %s with run id %s\n", m.name, m.runId))
+		paused := fmt.Sprintf("Paused pipeline %s with run id %s", m.name, m.runId)
+		if pausedBy := m.stageStatus.GetPausedBy(); pausedBy != "" {
+			paused += " " + pausedBy
+		}
+		s += "\n" + clockMark.Render() + " " + warningStyle.Render(paused+"\n")
+	}
+
+	if m.stageStatus.GetState() == CANCELED {
+		s += "\n" + crossMark.Render() + " " + failedStyle.Render(fmt.Sprintf("Canceled pipeline %s with run id %s\n", m.name, m.runId))
+	}
+
+	if m.err != nil {
+		s += "\n" + crossMark.Render() + " " + failedStyle.Render(fmt.Sprintf("Failed running pipeline %s with run id %s, %v\n", m.name, m.runId, m.err))
+	}
+
+	help := "\ne expand jobs • q quit"
+	if m.expand {
+		help = "\ne collapse jobs • q quit"
 	}
+	s += descriptionStyle.Faint(true).Render(help)
 
 	return s
 }
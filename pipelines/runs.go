@@ -6,15 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/helpers"
 	"github.com/nixmade/pippy/store"
-
-	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/lipgloss/table"
 )
 
 func displayInputs(inputs map[string]string) string {
@@ -25,77 +23,7 @@ func displayInputs(inputs map[string]string) string {
 	return strings.Join(output, ",")
 }
 
-func listPipelineRuns(pipelineRuns []*PipelineRun) {
-	rows := [][]string{}
-	for _, pipelineRun := range pipelineRuns {
-		rows = append(rows, []string{pipelineRun.Created.Format(time.RFC3339), pipelineRun.Id, pipelineRun.State, pipelineRun.Updated.Sub(pipelineRun.Created).String(), displayInputs(pipelineRun.Inputs)})
-	}
-
-	sort.Slice(rows, func(i, j int) bool {
-		leftTime, err := time.Parse(time.RFC3339, rows[i][0])
-		if err != nil {
-			return false
-		}
-
-		rightTime, err := time.Parse(time.RFC3339, rows[j][0])
-		if err != nil {
-			return false
-		}
-
-		return leftTime.After(rightTime)
-	})
-
-	re := lipgloss.NewRenderer(os.Stdout)
-
-	var (
-		// HeaderStyle is the lipgloss style used for the table headers.
-		HeaderStyle = re.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
-		// CellStyle is the base lipgloss style used for the table rows.
-		CellStyle = re.NewStyle().Padding(0, 1).Width(14)
-		// OddRowStyle is the lipgloss style used for odd-numbered table rows.
-		OddRowStyle = CellStyle.Foreground(bright)
-		// EvenRowStyle is the lipgloss style used for even-numbered table rows.
-		EvenRowStyle = CellStyle.Foreground(dim)
-		// BorderStyle is the lipgloss style used for the table border.
-		BorderStyle = lipgloss.NewStyle().Foreground(dim)
-	)
-
-	t := table.New().
-		Width(120).
-		Border(lipgloss.RoundedBorder()).
-		BorderStyle(BorderStyle).
-		Headers("TIME", "ID", "STATE", "RUN TIME", "INPUTS").
-		Rows(rows...).
-		StyleFunc(func(row, col int) lipgloss.Style {
-			var style lipgloss.Style
-			switch {
-			case row == 0:
-				style = HeaderStyle
-			case row%2 == 0:
-				style = EvenRowStyle
-			default:
-				style = OddRowStyle
-			}
-
-			if col == 0 {
-				style = style.Width(18)
-			}
-			if col == 1 {
-				style = style.Width(36)
-			}
-			if col == 2 {
-				style = style.Width(8)
-			}
-			if col == 4 {
-				style = style.Width(20)
-			}
-			return style
-		})
-
-	fmt.Println(t)
-}
-
-func showPipelineRun(name, id string, pipelineRun *PipelineRun) {
+func showPipelineRun(name, id string, pipelineRun *PipelineRun, expand bool) {
 	s := currentStyle.Render(fmt.Sprintf("Pipeline %s with run id %s started at %s", name, id, pipelineRun.Created.String())) + "\n\n"
 
 	for _, stage := range pipelineRun.Stages {
@@ -110,6 +38,7 @@ func showPipelineRun(name, id string, pipelineRun *PipelineRun) {
 			if approvedBy != "" {
 				s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(approvedBy)
 			}
+			s += renderJobs(stage.Jobs, expand)
 			s += "\n"
 			continue
 		} else if strings.EqualFold(stage.State, "InProgress") {
@@ -118,6 +47,7 @@ func showPipelineRun(name, id string, pipelineRun *PipelineRun) {
 			if approvedBy != "" {
 				s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(approvedBy)
 			}
+			s += renderJobs(stage.Jobs, expand)
 			if stage.Rollback != nil {
 				s += descriptionStyle.Faint(true).Render("\n    Rollback " + stage.Rollback.State + " " + stage.Rollback.Title)
 				s += descriptionStyle.Faint(true).Render("\n    	" + stage.Rollback.Url)
@@ -134,6 +64,10 @@ func showPipelineRun(name, id string, pipelineRun *PipelineRun) {
 			if approvedBy != "" {
 				s += descriptionStyle.Faint(true).Render("\n    Approved by ") + doneStyle.Render(approvedBy)
 			}
+			if failure := stage.MonitorFailure; failure != nil {
+				s += descriptionStyle.Faint(true).Render("\n    Monitor ") + failedStyle.Render(fmt.Sprintf("%s %s %s %s", failure.Monitor, failure.Name, failure.Group, failure.State))
+			}
+			s += renderJobs(stage.Jobs, expand)
 			if stage.Rollback != nil {
 				s += warningStyle.Faint(true).Render("\n    Rollback " + stage.Rollback.State + " " + stage.Rollback.Title)
 				s += warningStyle.Faint(true).Render("\n    	" + stage.Rollback.Url)
@@ -319,21 +253,53 @@ func savePipelineRun(ctx context.Context, run *PipelineRun) error {
 	return dbStore.SaveJSON(pipelineRunKey, run)
 }
 
-func ShowAllPipelineRuns(name string, limit int64) error {
+// ShowAllPipelineRuns lists pipeline runs newest first, wide adds pipeline, stages, version and trigger columns
+func ShowAllPipelineRuns(name string, limit int64, output string) error {
 	pipelineRuns, err := GetPipelineRunsN(context.Background(), name, limit)
 	if err != nil {
 		return err
 	}
 
-	listPipelineRuns(pipelineRuns)
+	headers := []string{"TIME", "ID", "STATE", "RUN TIME", "INPUTS"}
+	if output == helpers.OutputWide {
+		headers = append(headers, "PIPELINE", "STAGES", "VERSION", "TRIGGERED BY")
+	}
+	pipelineRunOutputs := []PipelineRunOutput{}
+	rows := [][]string{}
+	for _, pipelineRun := range pipelineRuns {
+		pipelineRunOutput := pipelineRunOutput(pipelineRun)
+		pipelineRunOutputs = append(pipelineRunOutputs, pipelineRunOutput)
+
+		row := []string{
+			pipelineRun.Created.Format(time.RFC3339),
+			pipelineRun.Id,
+			pipelineRun.State,
+			pipelineRunOutput.Duration,
+			displayInputs(pipelineRun.Inputs),
+		}
+		if output == helpers.OutputWide {
+			completed := 0
+			for _, stage := range pipelineRun.Stages {
+				if strings.EqualFold(stage.State, "Success") {
+					completed++
+				}
+			}
+			row = append(row, pipelineRun.PipelineName, fmt.Sprintf("%d/%d", completed, len(pipelineRun.Stages)), pipelineRun.Version, pipelineRun.Trigger.Login)
+		}
+		rows = append(rows, row)
+	}
 
-	return nil
+	return helpers.WriteOutput(os.Stdout, output, pipelineRunOutputs, headers, rows)
 }
 
-func ShowPipelineRun(name, id string) error {
+// ShowPipelineRun shows stages of pipeline run, expand shows every job of stages instead of only failed or in progress jobs
+func ShowPipelineRun(name, id, output string, expand bool) error {
 	pipelineRun, err := GetPipelineRun(context.Background(), name, id)
 	if err != nil {
 		if errors.Is(err, store.ErrKeyNotFound) {
+			if output != "" {
+				return fmt.Errorf("pipeline run %s for pipeline %s not found", id, name)
+			}
 			s := "\n" + crossMark.PaddingRight(1).Render() +
 				failedStyle.Render("pipeline run ") +
 				warningStyle.Render(id) +
@@ -343,9 +309,47 @@ func ShowPipelineRun(name, id string) error {
 			fmt.Println(s)
 			return nil
 		}
+		return err
 	}
 
-	showPipelineRun(name, id, pipelineRun)
+	if output == "" {
+		showPipelineRun(name, id, pipelineRun, expand)
+		return nil
+	}
 
-	return nil
+	pipelineRunOutput := pipelineRunOutput(pipelineRun)
+	headers := []string{"#", "STAGE", "STATE", "RUN TIME", "URL"}
+	if output == helpers.OutputWide {
+		headers = append(headers, "RUN ID", "APPROVED BY", "REASON", "ROLLBACK", "FAILED JOBS")
+	}
+	rows := [][]string{}
+	for _, stage := range pipelineRunOutput.Stages {
+		row := []string{strconv.Itoa(stage.Stage), stage.Name, stage.State, stage.Duration, stage.Url}
+		if output == helpers.OutputWide {
+			rollback := ""
+			if stage.Rollback != nil {
+				rollback = strings.TrimSpace(stage.Rollback.State + " " + stage.Rollback.Url)
+			}
+			row = append(row, stage.RunId, stage.ApprovedBy, stage.Reason, rollback, failedJobs(stage.Jobs))
+		}
+		rows = append(rows, row)
+	}
+
+	return helpers.WriteOutput(os.Stdout, output, pipelineRunOutput, headers, rows)
+}
+
+// failedJobs failed jobs with their failed step
+func failedJobs(jobs []JobSummary) string {
+	var failed []string
+	for _, job := range jobs {
+		if !job.Failed() {
+			continue
+		}
+		if job.FailedStep != "" {
+			failed = append(failed, job.Name+"/"+job.FailedStep)
+			continue
+		}
+		failed = append(failed, job.Name)
+	}
+	return strings.Join(failed, ",")
 }
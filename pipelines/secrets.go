@@ -0,0 +1,170 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/secrets"
+
+	"github.com/google/uuid"
+)
+
+var invalidSecretChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// credential value held by a pipeline part, key names the secret it is moved into
+type credential struct {
+	key   string
+	value *string
+}
+
+// credentialHolder is implemented by every pipeline part holding credentials,
+// plaintext credentials are moved into secrets when pipeline is saved
+type credentialHolder interface {
+	credentials() []credential
+}
+
+func (d *DatadogInfo) credentials() []credential {
+	return []credential{
+		{"datadog-api-key", &d.ApiKey},
+		{"datadog-application-key", &d.ApplicationKey},
+	}
+}
+
+func (n *Notification) credentials() []credential {
+	credentials := []credential{{"webhook-secret", &n.Secret}}
+	if n.Smtp != nil {
+		credentials = append(credentials, credential{"smtp-password", &n.Smtp.Password})
+	}
+	return credentials
+}
+
+// namedCredentialHolder holder with secret name prefix unique within pipeline
+type namedCredentialHolder struct {
+	name   string
+	holder credentialHolder
+}
+
+// credentialHolders returns every part of pipeline holding credentials
+func (p *Pipeline) credentialHolders() []namedCredentialHolder {
+	var holders []namedCredentialHolder
+	for i, stage := range p.Stages {
+		if stage.Monitor.Datadog != nil {
+			holders = append(holders, namedCredentialHolder{name: getStageName(i, stage.Workflow.Name), holder: stage.Monitor.Datadog})
+		}
+	}
+	for i := range p.Notifications {
+		// notifications are deleted by index, index based names would be reused by the next notification added
+		holders = append(holders, namedCredentialHolder{name: "notification-" + uuid.NewString()[:8], holder: &p.Notifications[i]})
+	}
+	return holders
+}
+
+// secretName secret name used when plaintext credentials are moved into secrets
+func secretName(pipeline, holder, key string) string {
+	name := fmt.Sprintf("%s-%s-%s", pipeline, holder, key)
+	return invalidSecretChars.ReplaceAllString(name, "_")
+}
+
+// ownedSecrets names of secrets pipeline credentials were moved into, secrets referenced by users may be shared and are kept
+func (p *Pipeline) ownedSecrets() []string {
+	prefix := invalidSecretChars.ReplaceAllString(p.Name+"-", "_")
+	var names []string
+	for _, holder := range p.credentialHolders() {
+		for _, credential := range holder.holder.credentials() {
+			name, ok := strings.CutPrefix(*credential.value, secrets.REF_SECRET)
+			if ok && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, "-"+credential.key) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// deleteOwnedSecrets deletes secrets owned by previous which updated no longer refers to, nil updated deletes all of them
+func deleteOwnedSecrets(ctx context.Context, previous, updated *Pipeline) error {
+	var kept []string
+	if updated != nil {
+		kept = updated.ownedSecrets()
+	}
+	for _, name := range previous.ownedSecrets() {
+		if slices.Contains(kept, name) {
+			continue
+		}
+		if err := secrets.Delete(ctx, name); err != nil && !errors.Is(err, secrets.ErrSecretNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// externalizeSecrets moves plaintext credentials into secrets and replaces them with references,
+// returns true when pipeline changed
+func externalizeSecrets(ctx context.Context, pipeline *Pipeline) (bool, error) {
+	changed := false
+	for _, holder := range pipeline.credentialHolders() {
+		for _, credential := range holder.holder.credentials() {
+			if *credential.value == "" || secrets.IsRef(*credential.value) {
+				continue
+			}
+			name := secretName(pipeline.Name, holder.name, credential.key)
+			if err := secrets.Set(ctx, name, *credential.value); err != nil {
+				return changed, err
+			}
+			*credential.value = secrets.Ref(name)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// hasPlaintextSecrets pipeline saved before secrets holds plaintext credentials
+func hasPlaintextSecrets(pipeline *Pipeline) bool {
+	for _, holder := range pipeline.credentialHolders() {
+		for _, credential := range holder.holder.credentials() {
+			if *credential.value != "" && !secrets.IsRef(*credential.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MigrateSecrets saves pipelines created before secrets with references, returns names of migrated pipelines
+func MigrateSecrets(ctx context.Context) ([]string, error) {
+	pipelines, err := ListPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []string
+	for _, pipeline := range pipelines {
+		if !hasPlaintextSecrets(pipeline) {
+			continue
+		}
+		if err := SavePipeline(ctx, pipeline); err != nil {
+			return migrated, fmt.Errorf("failed to migrate pipeline %s credentials to secrets, %v", pipeline.Name, err)
+		}
+		log.Get().Info().Str("Pipeline", pipeline.Name).Msg("migrated pipeline credentials to secrets")
+		migrated = append(migrated, pipeline.Name)
+	}
+	return migrated, nil
+}
+
+func RunMigrateSecrets() error {
+	migrated, err := MigrateSecrets(context.Background())
+	for _, name := range migrated {
+		fmt.Println(checkMark.Render() + " " + doneStyle.Render(fmt.Sprintf("Moved pipeline %s credentials to secrets", name)))
+	}
+	if err != nil {
+		return err
+	}
+	if len(migrated) <= 0 {
+		fmt.Println("No pipelines with plaintext credentials")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/pippy/secrets"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/nixmade/orchestrator/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecrets(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestSecrets*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	ctx := context.Background()
+	require.NoError(t, secrets.Set(ctx, "datadog-api-key", "api"))
+	require.NoError(t, secrets.Set(ctx, "datadog-api-key", "api2"))
+	require.Error(t, secrets.Set(ctx, "datadog api key", "api"))
+
+	value, err := secrets.Get(ctx, "datadog-api-key")
+	require.NoError(t, err)
+	assert.Equal(t, "api2", value)
+
+	list, err := secrets.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "datadog-api-key", list[0].Name)
+	assert.NotContains(t, list[0].Value, "api2")
+
+	value, err = secrets.Resolve(ctx, "secret:datadog-api-key")
+	require.NoError(t, err)
+	assert.Equal(t, "api2", value)
+
+	t.Setenv("PIPPY_TEST_DD_API_KEY", "envapi")
+	value, err = secrets.Resolve(ctx, "env:PIPPY_TEST_DD_API_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "envapi", value)
+
+	_, err = secrets.Resolve(ctx, "env:PIPPY_TEST_UNSET")
+	require.Error(t, err)
+
+	// secrets cannot be decrypted with a different master key
+	t.Setenv(secrets.MasterKeyEnv, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	_, err = secrets.Get(ctx, "datadog-api-key")
+	require.Error(t, err)
+
+	require.NoError(t, secrets.Delete(ctx, "datadog-api-key"))
+	_, err = secrets.Get(ctx, "datadog-api-key")
+	require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestMigrateSecrets*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	prodStatus := "OK"
+	setupDatadog(t, &prodStatus)
+
+	// pipeline saved before secrets holds plaintext keys
+	ctx := context.Background()
+	pipeline := &Pipeline{
+		Name: "Pipeline1",
+		Stages: []Stage{
+			{Repo: "org1/repo1",
+				Workflow: expectedWorkflows["org1/repo1"][0],
+				Monitor:  MonitorInfo{Datadog: &DatadogInfo{Monitors: []string{"1234"}, ApiKey: "api", ApplicationKey: "app", Groups: []string{"env:prod"}}}},
+		},
+	}
+	dbStore, err := store.Get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, dbStore.SaveJSON(PipelinePrefix+pipeline.Name, pipeline))
+	require.NoError(t, store.Close(dbStore))
+
+	// reads do not migrate
+	pipelines, err := ListPipelines(ctx)
+	require.NoError(t, err)
+	require.Len(t, pipelines, 1)
+	assert.Equal(t, "api", pipelines[0].Stages[0].Monitor.Datadog.ApiKey)
+	_, err = secrets.Get(ctx, "Pipeline1-Workflow1-0-datadog-api-key")
+	require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+
+	migrated, err := MigrateSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Pipeline1"}, migrated)
+
+	pipelines, err = ListPipelines(ctx)
+	require.NoError(t, err)
+	require.Len(t, pipelines, 1)
+	assert.Equal(t, "secret:Pipeline1-Workflow1-0-datadog-api-key", pipelines[0].Stages[0].Monitor.Datadog.ApiKey)
+	assert.Equal(t, "secret:Pipeline1-Workflow1-0-datadog-application-key", pipelines[0].Stages[0].Monitor.Datadog.ApplicationKey)
+
+	dbStore, err = store.Get(ctx)
+	require.NoError(t, err)
+	var saved string
+	require.NoError(t, dbStore.LoadValues(PipelinePrefix, func(key, value any) error {
+		saved = value.(string)
+		return nil
+	}))
+	require.NoError(t, store.Close(dbStore))
+	assert.NotContains(t, saved, `"api"`)
+	assert.NotContains(t, saved, `"app"`)
+
+	migrated, err = MigrateSecrets(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+
+	// references are resolved only while monitoring
+	migratedPipeline, err := GetPipeline(ctx, pipeline.Name)
+	require.NoError(t, err)
+	controller := newDatadogMonitoringController(migratedPipeline.Stages[0].Monitor.Datadog, nil)
+	targets := []*core.ClientState{{Name: "org1/repo1/0/1234"}}
+	require.NoError(t, controller.ExternalMonitoring(targets))
+
+	// env references are kept as is
+	migratedPipeline.Stages[0].Monitor.Datadog.ApiKey = "env:PIPPY_TEST_DD_API_KEY"
+	require.NoError(t, SavePipeline(ctx, migratedPipeline))
+	controller = newDatadogMonitoringController(migratedPipeline.Stages[0].Monitor.Datadog, nil)
+	require.Error(t, controller.ExternalMonitoring(targets))
+
+	t.Setenv("PIPPY_TEST_DD_API_KEY", "api")
+	require.NoError(t, controller.ExternalMonitoring(targets))
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/nixmade/pippy/helpers"
 	"github.com/nixmade/pippy/store"
 
 	"github.com/charmbracelet/lipgloss"
@@ -30,30 +33,20 @@ func showPipeline(pipeline *Pipeline) {
 			}
 		}
 		ignore := "NO"
-		rollback := ""
 		if stage.Monitor.Workflow.Ignore {
 			ignore = "YES"
 		}
-		if stage.Monitor.Workflow.Rollback {
-			rollback = "WORKFLOW"
+		monitors, rollbacks := stageMonitors(stage)
+		monitoring := "NO"
+		if len(monitors) > 0 {
+			monitoring = strings.ToUpper(strings.Join(monitors, ","))
 		}
-		datadog := "NO"
-		if stage.Monitor.Datadog != nil {
-			datadog = "YES"
-			if stage.Monitor.Datadog.Rollback {
-				if rollback == "" {
-					rollback = "DATADOG"
-				} else {
-					rollback += ",DATADOG"
-				}
-			}
-		}
-
-		if rollback == "" {
-			rollback = "NO"
+		rollback := "NO"
+		if len(rollbacks) > 0 {
+			rollback = strings.ToUpper(strings.Join(rollbacks, ","))
 		}
 
-		rows = append(rows, []string{strconv.Itoa(i + 1), stage.Repo, stage.Workflow.Name, stage.Workflow.Url, approval, ignore, datadog, rollback})
+		rows = append(rows, []string{strconv.Itoa(i + 1), stage.Repo, stage.Workflow.Name, stage.Workflow.Url, approval, ignore, monitoring, rollback})
 	}
 
 	re := lipgloss.NewRenderer(os.Stdout)
@@ -75,7 +68,7 @@ func showPipeline(pipeline *Pipeline) {
 		Width(120).
 		Border(lipgloss.RoundedBorder()).
 		BorderStyle(BorderStyle).
-		Headers("#", "REPO", "WORKFLOW", "URL", "REQUIRES APPROVAL", "IGNORE WORKFLOW FAILURES", "DATADOG MONITORING", "ROLLBACK").
+		Headers("#", "REPO", "WORKFLOW", "URL", "REQUIRES APPROVAL", "IGNORE WORKFLOW FAILURES", "MONITORING", "ROLLBACK").
 		Rows(rows...).
 		StyleFunc(func(row, col int) lipgloss.Style {
 			var style lipgloss.Style
@@ -118,10 +111,13 @@ func showPipeline(pipeline *Pipeline) {
 	fmt.Println(t)
 }
 
-func ShowPipeline(name string) error {
+func ShowPipeline(name, output string) error {
 	pipeline, err := GetPipeline(context.Background(), name)
 	if err != nil {
 		if errors.Is(err, store.ErrKeyNotFound) {
+			if output != "" {
+				return fmt.Errorf("pipeline %s not found", name)
+			}
 			s := "\n" + crossMark.PaddingRight(1).Render() +
 				failedStyle.Render("pipeline ") +
 				warningStyle.Render(name) +
@@ -132,11 +128,44 @@ func ShowPipeline(name string) error {
 		return err
 	}
 
+	switch output {
+	case helpers.OutputJSON, helpers.OutputYAML:
+		return helpers.WriteOutput(os.Stdout, output, pipelineOutput(pipeline), nil, nil)
+	case helpers.OutputWide:
+		return helpers.WriteOutput(os.Stdout, output, nil, []string{"#", "NAME", "REPO", "WORKFLOW", "PATH", "APPROVAL", "ENVIRONMENT", "INPUT", "MONITORING", "ROLLBACK"}, widePipelineRows(pipeline))
+	}
+
 	showPipeline(pipeline)
 
 	return nil
 }
 
+func widePipelineRows(pipeline *Pipeline) [][]string {
+	rows := [][]string{}
+	for _, stage := range pipelineOutput(pipeline).Stages {
+		approval := "NO"
+		if stage.Approval {
+			approval = "YES"
+			if pipeline.Locked {
+				approval = "LOCKED"
+			}
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(stage.Stage),
+			stage.Name,
+			stage.Repo,
+			stage.Workflow.Name,
+			stage.Workflow.Path,
+			approval,
+			stage.Environment,
+			displayInputs(stage.Input),
+			strings.Join(stage.Monitors, ","),
+			strings.Join(stage.Rollback, ","),
+		})
+	}
+	return rows
+}
+
 func listPipeline(pipelines []*Pipeline) error {
 	rows := [][]string{}
 	for i, pipeline := range pipelines {
@@ -208,11 +237,34 @@ func listPipeline(pipelines []*Pipeline) error {
 	return nil
 }
 
-func ShowAllPipelines() error {
+func ShowAllPipelines(output string) error {
 	pipelines, err := ListPipelines(context.Background())
 	if err != nil {
 		return err
 	}
 
-	return listPipeline(pipelines)
+	if output == "" || output == helpers.OutputTable {
+		return listPipeline(pipelines)
+	}
+
+	pipelineOutputs := []PipelineOutput{}
+	rows := [][]string{}
+	for _, pipeline := range pipelines {
+		runs, err := GetPipelineRunCountByState(context.Background(), pipeline.Name)
+		if err != nil {
+			return err
+		}
+		pipelineOutput := pipelineOutput(pipeline)
+		pipelineOutput.Runs = runs
+		pipelineOutputs = append(pipelineOutputs, pipelineOutput)
+
+		var states []string
+		for state, count := range runs {
+			states = append(states, fmt.Sprintf("%s=%d", state, count))
+		}
+		sort.Strings(states)
+		rows = append(rows, []string{pipeline.Name, strconv.Itoa(len(pipeline.Stages)), strings.Join(states, ","), strconv.FormatBool(pipeline.Locked), strconv.Itoa(len(pipeline.Triggers)), strconv.Itoa(len(pipeline.Notifications))})
+	}
+
+	return helpers.WriteOutput(os.Stdout, output, pipelineOutputs, []string{"NAME", "STAGES", "RUNS", "LOCKED", "TRIGGERS", "NOTIFICATIONS"}, rows)
 }
@@ -0,0 +1,444 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryPrefix = "delivery:"
+
+	DELIVERY_PENDING = "pending"
+	DELIVERY_DONE    = "done"
+
+	TRIGGER_PUSH         = "push"
+	TRIGGER_TAG          = "tag"
+	TRIGGER_RELEASE      = "release"
+	TRIGGER_PULL_REQUEST = "pull_request"
+
+	AUDIT_TRIGGERED string = "Triggered"
+
+	// SOURCE_WEBHOOK trigger metadata source for runs triggered by github webhooks
+	SOURCE_WEBHOOK = "webhook"
+)
+
+var (
+	TriggerEvents = []string{TRIGGER_PUSH, TRIGGER_TAG, TRIGGER_RELEASE, TRIGGER_PULL_REQUEST}
+
+	eventTemplateRegex = regexp.MustCompile(`\$\{\{\s*event\.([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+	// DeliveryClaimTimeout pending delivery left behind by a stopped webhook server can be claimed again after it
+	DeliveryClaimTimeout = 5 * time.Minute
+
+	// deliveryLock serializes delivery claims from webhook requests handled in this process
+	deliveryLock sync.Mutex
+)
+
+// Delivery github webhook delivery claimed by TriggerPipelines
+type Delivery struct {
+	State   string    `json:"state"`
+	Updated time.Time `json:"updated"`
+}
+
+// Trigger starts a pipeline run when a matching github event is received
+type Trigger struct {
+	// Event is one of push, tag, release or pull_request(merged)
+	Event string `json:"event"`
+	// Repo org/repo event originates from
+	Repo string `json:"repo"`
+	// Branch glob for push and base branch for pull requests, defaults to main
+	Branch string `json:"branch,omitempty"`
+	// Tag glob for tag pushes and published releases
+	Tag string `json:"tag,omitempty"`
+	// Inputs for pipeline run, values are templated from event eg: version=${{ event.after }}
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+// TriggeredRun is a pipeline run to be started for a matching event
+type TriggeredRun struct {
+	Pipeline string
+	RunId    string
+	Inputs   map[string]string
+	Trigger  TriggerMetadata
+}
+
+type triggerEvent struct {
+	name    string
+	repo    string
+	ref     string
+	refName string
+	values  map[string]any
+}
+
+func parseTriggerEvent(event string, payload []byte) (*triggerEvent, error) {
+	values := make(map[string]any)
+	if err := json.Unmarshal(payload, &values); err != nil {
+		return nil, err
+	}
+
+	e := &triggerEvent{values: values}
+	if repoName, ok := lookupEventValue(values, "repository.full_name"); ok {
+		e.repo = fmt.Sprint(repoName)
+	}
+
+	switch event {
+	case "push":
+		ref, _ := lookupEventValue(values, "ref")
+		e.ref = fmt.Sprint(ref)
+		if deleted, _ := lookupEventValue(values, "deleted"); deleted == true {
+			return nil, nil
+		}
+		if refName, ok := strings.CutPrefix(e.ref, "refs/tags/"); ok {
+			e.name = TRIGGER_TAG
+			e.refName = refName
+		} else if refName, ok := strings.CutPrefix(e.ref, "refs/heads/"); ok {
+			e.name = TRIGGER_PUSH
+			e.refName = refName
+		} else {
+			return nil, nil
+		}
+	case "release":
+		if action, _ := lookupEventValue(values, "action"); action != "published" {
+			return nil, nil
+		}
+		tag, _ := lookupEventValue(values, "release.tag_name")
+		e.name = TRIGGER_RELEASE
+		e.refName = fmt.Sprint(tag)
+		e.ref = "refs/tags/" + e.refName
+	case "pull_request":
+		action, _ := lookupEventValue(values, "action")
+		merged, _ := lookupEventValue(values, "pull_request.merged")
+		if action != "closed" || merged != true {
+			return nil, nil
+		}
+		base, _ := lookupEventValue(values, "pull_request.base.ref")
+		e.name = TRIGGER_PULL_REQUEST
+		e.refName = fmt.Sprint(base)
+		e.ref = "refs/heads/" + e.refName
+	default:
+		return nil, nil
+	}
+
+	values["ref_name"] = e.refName
+	return e, nil
+}
+
+func lookupEventValue(values map[string]any, field string) (any, bool) {
+	var current any = values
+	for _, key := range strings.Split(field, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func globMatch(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+func (t Trigger) matches(e *triggerEvent) bool {
+	if t.Event != e.name || !strings.EqualFold(t.Repo, e.repo) {
+		return false
+	}
+
+	switch t.Event {
+	case TRIGGER_PUSH, TRIGGER_PULL_REQUEST:
+		branch := t.Branch
+		if branch == "" {
+			branch = "main"
+		}
+		return globMatch(branch, e.refName)
+	case TRIGGER_TAG, TRIGGER_RELEASE:
+		return t.Tag == "" || globMatch(t.Tag, e.refName)
+	}
+
+	return false
+}
+
+func (t Trigger) inputs(e *triggerEvent) (map[string]string, error) {
+	inputs := make(map[string]string)
+	for key, value := range t.Inputs {
+		var missing []string
+		inputs[key] = eventTemplateRegex.ReplaceAllStringFunc(value, func(match string) string {
+			field := eventTemplateRegex.FindStringSubmatch(match)[1]
+			eventValue, ok := lookupEventValue(e.values, field)
+			if !ok || eventValue == nil {
+				missing = append(missing, field)
+				return ""
+			}
+			if number, ok := eventValue.(float64); ok {
+				return strconv.FormatFloat(number, 'f', -1, 64)
+			}
+			return fmt.Sprint(eventValue)
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("event fields %s not found for input %s", strings.Join(missing, ","), key)
+		}
+	}
+	return inputs, nil
+}
+
+func (e *triggerEvent) metadata(deliveryId string) TriggerMetadata {
+	login, _ := lookupEventValue(e.values, "sender.login")
+	trigger := TriggerMetadata{Login: fmt.Sprint(login), Name: fmt.Sprint(login), Source: SOURCE_WEBHOOK}
+	if name, ok := lookupEventValue(e.values, "pusher.name"); ok {
+		trigger.Name = fmt.Sprint(name)
+	}
+	if email, ok := lookupEventValue(e.values, "pusher.email"); ok {
+		trigger.Email = fmt.Sprint(email)
+	}
+	trigger.Reason = fmt.Sprintf("Github %s event %s for %s, delivery %s", e.name, e.refName, e.repo, deliveryId)
+	return trigger
+}
+
+// TriggerPipelines matches github event against pipeline triggers, duplicate deliveries are ignored
+func TriggerPipelines(ctx context.Context, event, deliveryId string, payload []byte) (triggeredRuns []TriggeredRun, err error) {
+	logger := log.Get().With().Str("Event", event).Str("Delivery", deliveryId).Logger()
+
+	triggerEvent, err := parseTriggerEvent(event, payload)
+	if err != nil {
+		return nil, err
+	}
+	if triggerEvent == nil {
+		logger.Info().Msg("event does not trigger pipelines")
+		return nil, nil
+	}
+
+	if deliveryId != "" {
+		claimed, err := claimDelivery(ctx, deliveryId)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			logger.Info().Msg("ignoring duplicate delivery")
+			return nil, nil
+		}
+		// redelivery after a failure below triggers again
+		defer func() {
+			if err == nil {
+				err = completeDelivery(ctx, deliveryId)
+				return
+			}
+			if releaseErr := releaseDelivery(ctx, deliveryId); releaseErr != nil {
+				logger.Error().Err(releaseErr).Msg("failed to release delivery")
+			}
+		}()
+	}
+
+	pipelines, err := ListPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// every run is created before any is saved, failures leave nothing behind
+	var orchestrators []*orchestrator
+	for _, pipeline := range pipelines {
+		for i, trigger := range pipeline.Triggers {
+			if !trigger.matches(triggerEvent) {
+				continue
+			}
+
+			inputs, err := trigger.inputs(triggerEvent)
+			if err != nil {
+				logger.Error().Err(err).Str("Pipeline", pipeline.Name).Int("Trigger", i).Msg("failed to template trigger inputs")
+				continue
+			}
+
+			triggeredRun := TriggeredRun{Pipeline: pipeline.Name, RunId: uuid.NewString(), Inputs: inputs, Trigger: triggerEvent.metadata(deliveryId)}
+			o, err := createOrchestrator(ctx, triggeredRun.Pipeline, triggeredRun.RunId, triggeredRun.Inputs, nil, triggeredRun.Trigger, false)
+			if err != nil {
+				return nil, err
+			}
+			orchestrators = append(orchestrators, o)
+			triggeredRuns = append(triggeredRuns, triggeredRun)
+			// a single run per pipeline even if multiple triggers match
+			break
+		}
+	}
+
+	// runs saved before a failure are deleted so a redelivery does not trigger them twice
+	for i, o := range orchestrators {
+		if err := o.savePipelineRun(ctx); err != nil {
+			deleteTriggeredRuns(ctx, triggeredRuns[:i])
+			return nil, err
+		}
+	}
+
+	for _, triggeredRun := range triggeredRuns {
+		resource := map[string]string{"Pipeline": triggeredRun.Pipeline, "PipelineRun": triggeredRun.RunId}
+		if err := audit.Save(ctx, AUDIT_TRIGGERED, resource, triggeredRun.Trigger.Login, triggeredRun.Trigger.Email, triggeredRun.Trigger.Source, triggeredRun.Trigger.Reason); err != nil {
+			deleteTriggeredRuns(ctx, triggeredRuns)
+			return nil, err
+		}
+		logger.Info().Str("Pipeline", triggeredRun.Pipeline).Str("RunId", triggeredRun.RunId).Msg("event triggered pipeline run")
+	}
+
+	return triggeredRuns, nil
+}
+
+// deleteTriggeredRuns deletes saved runs of a failed trigger, they are never started
+func deleteTriggeredRuns(ctx context.Context, triggeredRuns []TriggeredRun) {
+	for _, triggeredRun := range triggeredRuns {
+		if err := DeletePipelineRun(ctx, triggeredRun.Pipeline, triggeredRun.RunId); err != nil {
+			log.Get().Error().Err(err).Str("Pipeline", triggeredRun.Pipeline).Str("RunId", triggeredRun.RunId).Msg("failed to delete triggered pipeline run")
+		}
+	}
+}
+
+// claimDelivery records delivery id as pending, returns false when it is done or pending elsewhere
+func claimDelivery(ctx context.Context, deliveryId string) (bool, error) {
+	deliveryLock.Lock()
+	defer deliveryLock.Unlock()
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	var value json.RawMessage
+	err = dbStore.LoadJSON(DeliveryPrefix+deliveryId, &value)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return false, err
+	}
+	if err == nil {
+		delivery := &Delivery{}
+		if err := json.Unmarshal(value, delivery); err != nil {
+			// deliveries recorded before claims are timestamps of done deliveries
+			return false, nil
+		}
+		if delivery.State == DELIVERY_DONE || time.Now().UTC().Before(delivery.Updated.Add(DeliveryClaimTimeout)) {
+			return false, nil
+		}
+	}
+
+	return true, dbStore.SaveJSON(DeliveryPrefix+deliveryId, &Delivery{State: DELIVERY_PENDING, Updated: time.Now().UTC()})
+}
+
+// completeDelivery marks claimed delivery done, later deliveries with same id are ignored
+func completeDelivery(ctx context.Context, deliveryId string) error {
+	deliveryLock.Lock()
+	defer deliveryLock.Unlock()
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	return dbStore.SaveJSON(DeliveryPrefix+deliveryId, &Delivery{State: DELIVERY_DONE, Updated: time.Now().UTC()})
+}
+
+// releaseDelivery drops claim of a failed delivery so it can be redelivered
+func releaseDelivery(ctx context.Context, deliveryId string) error {
+	deliveryLock.Lock()
+	defer deliveryLock.Unlock()
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	if err := dbStore.Delete(DeliveryPrefix + deliveryId); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+func AddPipelineTrigger(ctx context.Context, name string, trigger Trigger) error {
+	if !slices.Contains(TriggerEvents, trigger.Event) {
+		return fmt.Errorf("please provide a valid event in %s", strings.Join(TriggerEvents, ","))
+	}
+
+	if len(strings.SplitN(trigger.Repo, "/", 2)) != 2 {
+		return fmt.Errorf("please provide repo as org/repo")
+	}
+
+	for _, pattern := range []string{trigger.Branch, trigger.Tag} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %s, %v", pattern, err)
+		}
+	}
+
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pipeline.Triggers = append(pipeline.Triggers, trigger)
+	return SavePipeline(ctx, pipeline)
+}
+
+func DeletePipelineTrigger(ctx context.Context, name string, index int) error {
+	pipeline, err := GetPipeline(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(pipeline.Triggers) {
+		return fmt.Errorf("%d invalid trigger, choose between 1 and %d", index+1, len(pipeline.Triggers))
+	}
+
+	pipeline.Triggers = slices.Delete(pipeline.Triggers, index, index+1)
+	return SavePipeline(ctx, pipeline)
+}
+
+func ShowPipelineTriggers(name, output string) error {
+	pipeline, err := GetPipeline(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	if len(pipeline.Triggers) <= 0 && !helpers.Structured(output) {
+		fmt.Println("\n" + currentStyle.Render(fmt.Sprintf("No triggers for pipeline %s\n", name)))
+		return nil
+	}
+
+	rows := [][]string{}
+	for i, trigger := range pipeline.Triggers {
+		rows = append(rows, []string{strconv.Itoa(i + 1), trigger.Event, trigger.Repo, trigger.Branch, trigger.Tag, displayInputs(trigger.Inputs)})
+	}
+
+	triggers := pipeline.Triggers
+	if triggers == nil {
+		triggers = []Trigger{}
+	}
+	return helpers.WriteOutput(os.Stdout, output, triggers, []string{"#", "EVENT", "REPO", "BRANCH", "TAG", "INPUTS"}, rows)
+}
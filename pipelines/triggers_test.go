@@ -0,0 +1,89 @@
+package pipelines
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimDelivery(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestClaimDelivery*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	ctx := context.Background()
+	claimed, err := claimDelivery(ctx, "delivery1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// pending delivery is not claimed twice
+	claimed, err = claimDelivery(ctx, "delivery1")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// failed delivery is released for redelivery
+	require.NoError(t, releaseDelivery(ctx, "delivery1"))
+	claimed, err = claimDelivery(ctx, "delivery1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	require.NoError(t, completeDelivery(ctx, "delivery1"))
+	claimed, err = claimDelivery(ctx, "delivery1")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// deliveries recorded as timestamps are done
+	dbStore, err := store.Get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, dbStore.SaveJSON(DeliveryPrefix+"delivery2", time.Now().UTC()))
+	require.NoError(t, store.Close(dbStore))
+	claimed, err = claimDelivery(ctx, "delivery2")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// pending delivery of a stopped webhook server is claimed again
+	timeout := DeliveryClaimTimeout
+	DeliveryClaimTimeout = -time.Second
+	defer func() {
+		DeliveryClaimTimeout = timeout
+	}()
+	claimed, err = claimDelivery(ctx, "delivery3")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = claimDelivery(ctx, "delivery3")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestDeleteTriggeredRuns(t *testing.T) {
+	o := setupOrchestrator(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestDeleteTriggeredRuns*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	defer holdStore(t)()
+
+	ctx := context.Background()
+	require.NoError(t, o.savePipelineRun(ctx))
+	_, err = GetPipelineRun(ctx, o.pipeline.Name, o.pipelineRunId)
+	require.NoError(t, err)
+
+	deleteTriggeredRuns(ctx, []TriggeredRun{{Pipeline: o.pipeline.Name, RunId: o.pipelineRunId}, {Pipeline: o.pipeline.Name, RunId: "unsaved"}})
+	_, err = GetPipelineRun(ctx, o.pipeline.Name, o.pipelineRunId)
+	require.ErrorIs(t, err, store.ErrKeyNotFound)
+}
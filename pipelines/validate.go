@@ -0,0 +1,251 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
+)
+
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// ValidationIssue problem found with a stage, errors fail the stage when it is dispatched
+type ValidationIssue struct {
+	Stage    int    `json:"stage"`
+	Repo     string `json:"repo"`
+	Workflow string `json:"workflow"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ValidatePipeline compares each stage workflow's current dispatch inputs with stage inputs and run inputs.
+// When run inputs are not provided, stage inputs left empty are assumed to be provided at run time
+func ValidatePipeline(client github.Client, pipeline *Pipeline, inputs map[string]string) ([]ValidationIssue, error) {
+	// inputs left empty in any stage are expected as run inputs, run inputs are provided to every stage
+	runInputs := make(map[string]*string)
+	for key, value := range inputs {
+		runInputs[key] = &value
+	}
+	if len(inputs) <= 0 {
+		for _, stage := range pipeline.Stages {
+			for key, value := range stage.Input {
+				if value == "" {
+					runInputs[key] = nil
+				}
+			}
+		}
+	}
+
+	var issues []ValidationIssue
+	for i, stage := range pipeline.Stages {
+		stageIssues, err := validateStage(client, stage, runInputs)
+		if err != nil {
+			return nil, err
+		}
+		stageIssues = append(validateMonitors(stage), stageIssues...)
+		for _, message := range stageIssues {
+			message.Stage = i + 1
+			message.Repo = stage.Repo
+			message.Workflow = stage.Workflow.Name
+			issues = append(issues, message)
+		}
+	}
+
+	return issues, nil
+}
+
+// validateStage issues for stage, nil run input value is unknown until run time
+func validateStage(client github.Client, stage Stage, runInputs map[string]*string) ([]ValidationIssue, error) {
+	orgRepoSlice := strings.SplitN(stage.Repo, "/", 2)
+	if len(orgRepoSlice) != 2 {
+		return []ValidationIssue{issue(SEVERITY_ERROR, "repo %s is not in org/repo format", stage.Repo)}, nil
+	}
+	org, repo := orgRepoSlice[0], orgRepoSlice[1]
+
+	workflow, err := client.GetWorkflow(org, repo, stage.Workflow.Id)
+	if github.IsNotFound(err) {
+		return []ValidationIssue{issue(SEVERITY_ERROR, "workflow %s no longer exists in %s", stage.Workflow.Path, stage.Repo)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if workflow.State == github.WORKFLOW_DELETED {
+		return []ValidationIssue{issue(SEVERITY_ERROR, "workflow file %s was deleted", workflow.Path)}, nil
+	}
+	if workflow.State != github.WORKFLOW_ACTIVE {
+		return []ValidationIssue{issue(SEVERITY_ERROR, "workflow is %s, it cannot be dispatched until it is enabled", workflow.State)}, nil
+	}
+
+	var issues []ValidationIssue
+	if stage.Workflow.Path != "" && workflow.Path != stage.Workflow.Path {
+		issues = append(issues, issue(SEVERITY_WARNING, "workflow moved from %s to %s", stage.Workflow.Path, workflow.Path))
+	}
+
+	workflowInputs, err := client.ListWorkflowInputs(org, repo, workflow.Path)
+	if github.IsNotFound(err) {
+		return append(issues, issue(SEVERITY_ERROR, "workflow file %s was deleted", workflow.Path)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// inputs dispatched for stage, static stage inputs are overridden by run inputs
+	dispatched := make(map[string]*string)
+	for key, value := range stage.Input {
+		if value != "" {
+			dispatched[key] = &value
+		}
+	}
+	for key, value := range runInputs {
+		dispatched[key] = value
+	}
+
+	defined := make(map[string]bool)
+	for _, input := range workflowInputs {
+		defined[input.Name] = true
+	}
+	if !defined["pippy_run_id"] {
+		issues = append(issues, issue(SEVERITY_ERROR, "workflow is not pippy ready, validate using pippy workflow validate"))
+	}
+
+	for _, input := range workflowInputs {
+		if input.Name == "pippy_run_id" {
+			continue
+		}
+
+		value, ok := dispatched[input.Name]
+		if !ok {
+			if input.Required && input.Default == "" {
+				issues = append(issues, issue(SEVERITY_ERROR, "required input %s is not provided by stage or run inputs", input.Name))
+			} else if _, expected := stage.Input[input.Name]; expected {
+				issues = append(issues, issue(SEVERITY_WARNING, "input %s is not provided by run inputs, workflow default %q is used", input.Name, input.Default))
+			}
+			continue
+		}
+
+		// value known only at run time
+		if value == nil {
+			continue
+		}
+		if message := validateInputValue(input, *value); message != "" {
+			issues = append(issues, issue(SEVERITY_ERROR, "%s", message))
+		}
+	}
+
+	var undefined []string
+	for key := range dispatched {
+		if !defined[key] {
+			undefined = append(undefined, key)
+		}
+	}
+	sort.Strings(undefined)
+	for _, key := range undefined {
+		issues = append(issues, issue(SEVERITY_ERROR, "input %s is not defined by workflow, github rejects dispatch with unexpected inputs", key))
+	}
+
+	return issues, nil
+}
+
+// validateMonitors issues for monitors configured on stage, monitors are checked only once workflow is dispatched
+func validateMonitors(stage Stage) []ValidationIssue {
+	var issues []ValidationIssue
+	if stage.Monitor.Datadog != nil {
+		if err := stage.Monitor.Datadog.Validate(); err != nil {
+			issues = append(issues, issue(SEVERITY_ERROR, "datadog monitor %v", err))
+		}
+	}
+	if stage.Monitor.Prometheus != nil {
+		if err := stage.Monitor.Prometheus.Validate(); err != nil {
+			issues = append(issues, issue(SEVERITY_ERROR, "prometheus monitor %v", err))
+		}
+	}
+	if stage.Monitor.Http != nil {
+		if err := stage.Monitor.Http.Validate(); err != nil {
+			issues = append(issues, issue(SEVERITY_ERROR, "http monitor %v", err))
+		}
+	}
+	return issues
+}
+
+// validateInputValue reason value is not accepted for input, empty when it is
+func validateInputValue(input github.WorkflowInput, value string) string {
+	switch input.Type {
+	case github.INPUT_CHOICE:
+		if !slices.Contains(input.Options, value) {
+			return fmt.Sprintf("input %s value %q is not one of %s", input.Name, value, strings.Join(input.Options, ","))
+		}
+	case github.INPUT_BOOLEAN:
+		if value != "true" && value != "false" {
+			return fmt.Sprintf("input %s value %q is not a boolean", input.Name, value)
+		}
+	case github.INPUT_NUMBER:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("input %s value %q is not a number", input.Name, value)
+		}
+	}
+	if input.Required && value == "" && input.Default == "" {
+		return fmt.Sprintf("required input %s is empty", input.Name)
+	}
+	return ""
+}
+
+func issue(severity, format string, args ...any) ValidationIssue {
+	return ValidationIssue{Severity: severity, Message: fmt.Sprintf(format, args...)}
+}
+
+func RunValidatePipeline(name string, inputs map[string]string, output string) error {
+	pipeline, err := GetPipeline(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	issues, err := ValidatePipeline(github.DefaultClient, pipeline, inputs)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, validationIssue := range issues {
+		rows = append(rows, []string{strconv.Itoa(validationIssue.Stage), validationIssue.Repo, validationIssue.Workflow, strings.ToUpper(validationIssue.Severity), validationIssue.Message})
+	}
+
+	structured := helpers.Structured(output)
+	switch {
+	case structured:
+		if issues == nil {
+			issues = []ValidationIssue{}
+		}
+		if err := helpers.WriteOutput(os.Stdout, output, issues, nil, nil); err != nil {
+			return err
+		}
+	case len(issues) <= 0:
+		fmt.Println(checkMark.Render() + " " + doneStyle.Render(fmt.Sprintf("Pipeline %s passed validation", name)))
+		return nil
+	default:
+		fmt.Println(helpers.RenderHighlightTable([]string{"STAGE", "REPO", "WORKFLOW", "SEVERITY", "ISSUE"}, rows, func(row int) bool {
+			return issues[row].Severity == SEVERITY_ERROR
+		}))
+	}
+
+	for _, validationIssue := range issues {
+		if validationIssue.Severity == SEVERITY_ERROR {
+			err := fmt.Errorf("pipeline %s failed validation", name)
+			if structured {
+				// issues already printed as the only document
+				return &helpers.PrintedError{Err: err}
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package pipelines
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	validateDeployWorkflow = `name: Deploy
+run-name: Deploy ${{ inputs.version }} - ${{ inputs.pippy_run_id }}
+on:
+  workflow_dispatch:
+    inputs:
+      version:
+        type: string
+        required: true
+      environment:
+        type: choice
+        required: true
+        default: staging
+        options: [staging, production]
+      dry_run:
+        type: boolean
+      pippy_run_id:
+        type: string
+`
+	validateNotifyWorkflow = `name: Notify
+run-name: Notify - ${{ inputs.pippy_run_id }}
+on:
+  workflow_dispatch:
+    inputs:
+      channel:
+        required: true
+      pippy_run_id:
+        type: string
+`
+	validateBuildWorkflow = `name: Build
+on: push
+`
+)
+
+func setupValidateTest(t *testing.T) (*githubtest.Server, []*githubtest.Workflow, *Pipeline) {
+	server := githubtest.NewServer(t)
+	server.AddRepo("org1/repo1", true)
+
+	var workflows []*githubtest.Workflow
+	pipeline := &Pipeline{Name: "Validate"}
+	for _, workflow := range []struct{ path, content string }{
+		{".github/workflows/deploy.yml", validateDeployWorkflow},
+		{".github/workflows/notify.yml", validateNotifyWorkflow},
+		{".github/workflows/build.yml", validateBuildWorkflow},
+	} {
+		added, err := server.AddWorkflow("org1/repo1", workflow.path, workflow.content, nil)
+		require.NoError(t, err)
+		workflows = append(workflows, added)
+		pipeline.Stages = append(pipeline.Stages, Stage{
+			Repo:     "org1/repo1",
+			Workflow: github.Workflow{Id: added.Id, Name: added.Name, Path: added.Path},
+		})
+	}
+
+	return server, workflows, pipeline
+}
+
+func messages(issues []ValidationIssue, stage int) []string {
+	var stageMessages []string
+	for _, issue := range issues {
+		if issue.Stage == stage {
+			stageMessages = append(stageMessages, issue.Severity+": "+issue.Message)
+		}
+	}
+	return stageMessages
+}
+
+func TestValidatePipeline(t *testing.T) {
+	_, _, pipeline := setupValidateTest(t)
+	pipeline.Stages[0].Input = map[string]string{"version": "", "environment": "qa"}
+	pipeline.Stages[1].Input = map[string]string{"channel": "#deploys"}
+
+	issues, err := ValidatePipeline(github.DefaultClient, pipeline, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{`error: input environment value "qa" is not one of staging,production`}, messages(issues, 1))
+	// version is expected as run input and is provided to every stage
+	assert.Equal(t, []string{"error: input version is not defined by workflow, github rejects dispatch with unexpected inputs"}, messages(issues, 2))
+	assert.Equal(t, []string{
+		"error: workflow is not pippy ready, validate using pippy workflow validate",
+		"error: input version is not defined by workflow, github rejects dispatch with unexpected inputs",
+	}, messages(issues, 3))
+	assert.Equal(t, "org1/repo1", issues[0].Repo)
+	assert.Equal(t, "Deploy", issues[0].Workflow)
+}
+
+func TestValidatePipelineMonitors(t *testing.T) {
+	_, _, pipeline := setupValidateTest(t)
+	pipeline.Stages = pipeline.Stages[:2]
+	pipeline.Stages[0].Input = map[string]string{"version": "", "environment": "production"}
+	pipeline.Stages[0].Monitor.Datadog = &DatadogInfo{Monitors: []string{"1234"}, ApiKey: "secret:api", ApplicationKey: "secret:app", FailureStates: []string{"Critical"}}
+	pipeline.Stages[1].Monitor.Http = &HttpInfo{Checks: []HttpCheck{{Url: "https://service/version", JsonPath: "$.build.["}}}
+
+	issues, err := ValidatePipeline(github.DefaultClient, pipeline, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"error: datadog monitor invalid datadog state Critical, choose from " + strings.Join(DatadogStates, ",")}, messages(issues, 1))
+	// monitor issues are reported with workflow issues
+	require.Len(t, messages(issues, 2), 3)
+	assert.Contains(t, messages(issues, 2)[0], "error: http monitor invalid json path $.build.[")
+}
+
+func TestValidatePipelineRunInputs(t *testing.T) {
+	_, _, pipeline := setupValidateTest(t)
+	pipeline.Stages = pipeline.Stages[:2]
+	pipeline.Stages[0].Input = map[string]string{"version": "", "environment": "production"}
+
+	// run inputs override static stage inputs and replace expected stage inputs
+	issues, err := ValidatePipeline(github.DefaultClient, pipeline, map[string]string{"environment": "staging", "dry_run": "maybe"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`error: input dry_run value "maybe" is not a boolean`,
+		"error: required input version is not provided by stage or run inputs",
+	}, messages(issues, 1))
+	assert.Equal(t, []string{
+		"error: required input channel is not provided by stage or run inputs",
+		"error: input dry_run is not defined by workflow, github rejects dispatch with unexpected inputs",
+		"error: input environment is not defined by workflow, github rejects dispatch with unexpected inputs",
+	}, messages(issues, 2))
+}
+
+func TestValidatePipelineWorkflowState(t *testing.T) {
+	server, workflows, pipeline := setupValidateTest(t)
+	pipeline.Stages = pipeline.Stages[:2]
+	pipeline.Stages[0].Input = map[string]string{"version": "", "dry_run": ""}
+	pipeline.Stages[1].Input = map[string]string{"channel": "#deploys"}
+	pipeline.Stages[1].Workflow.Path = ".github/workflows/notify-old.yml"
+
+	issues, err := ValidatePipeline(github.DefaultClient, pipeline, map[string]string{"version": "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`warning: input dry_run is not provided by run inputs, workflow default "" is used`}, messages(issues, 1))
+	assert.Equal(t, []string{
+		"warning: workflow moved from .github/workflows/notify-old.yml to .github/workflows/notify.yml",
+		"error: input version is not defined by workflow, github rejects dispatch with unexpected inputs",
+	}, messages(issues, 2))
+
+	server.SetState(workflows[0], "disabled_manually")
+	server.SetState(workflows[1], "deleted")
+	issues, err = ValidatePipeline(github.DefaultClient, pipeline, map[string]string{"version": "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error: workflow is disabled_manually, it cannot be dispatched until it is enabled"}, messages(issues, 1))
+	assert.Equal(t, []string{"error: workflow file .github/workflows/notify.yml was deleted"}, messages(issues, 2))
+
+	pipeline.Stages[1].Workflow.Id = 1
+	issues, err = ValidatePipeline(github.DefaultClient, pipeline, map[string]string{"version": "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error: workflow .github/workflows/notify-old.yml no longer exists in org1/repo1"}, messages(issues, 2))
+}
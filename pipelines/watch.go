@@ -0,0 +1,97 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WatchPollInterval is how often pipeline run is reloaded from store while watching
+var WatchPollInterval = 2 * time.Second
+
+// WatchPipelineRunUI shows live UI of pipeline run orchestrated by another process, run is reloaded from store
+// and no orchestrator is started
+func WatchPipelineRunUI(name, id string) error {
+	ctx := context.Background()
+	pipelineRun, err := GetPipelineRun(ctx, name, id)
+	if err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return fmt.Errorf("pipeline run %s for pipeline %s not found", id, name)
+		}
+		return err
+	}
+
+	stageStatus := &status{m: make(map[string]*run)}
+	updateWatchStatus(ctx, stageStatus, pipelineRun)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go watchPipelineRun(ctx, stageStatus, name, id)
+
+	m := initialModel(watchPipeline(pipelineRun), stageStatus, pipelineRun.Created.String(), id)
+	m.watch = true
+	if _, err := tea.NewProgram(m).Run(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// watchPipeline stages of pipeline run, pipeline may have changed since run started
+func watchPipeline(pipelineRun *PipelineRun) *Pipeline {
+	pipeline := &Pipeline{Name: pipelineRun.PipelineName}
+	for _, stageRun := range pipelineRun.Stages {
+		pipeline.Stages = append(pipeline.Stages, Stage{Workflow: github.Workflow{Name: stageRun.Name}})
+	}
+	return pipeline
+}
+
+// watchPipelineRun reloads pipeline run until ctx is done, store can be briefly held by orchestrator so failed
+// loads are retried on next poll
+func watchPipelineRun(ctx context.Context, stageStatus *status, name, id string) {
+	logger := log.Get().With().Str("Pipeline", name).Str("RunId", id).Logger()
+	ticker := time.NewTicker(WatchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pipelineRun, err := GetPipelineRun(ctx, name, id)
+			if err != nil {
+				logger.Warn().Err(err).Msg("failed to reload watched pipeline run")
+				continue
+			}
+			updateWatchStatus(ctx, stageStatus, pipelineRun)
+		}
+	}
+}
+
+// updateWatchStatus stage status from saved pipeline run, run paused by user is shown paused until orchestrator saves it
+func updateWatchStatus(ctx context.Context, stageStatus *status, pipelineRun *PipelineRun) {
+	for i, stageRun := range pipelineRun.Stages {
+		stageStatus.Set(getStageName(i, stageRun.Name), loadStageRun(&stageRun))
+	}
+
+	state := State(pipelineRun.State)
+	if pipelineRun.Paused && state != SUCCESS && state != FAILED && state != ROLLBACK && state != CANCELED {
+		state = PAUSED
+	}
+
+	pausedBy := ""
+	if state == PAUSED {
+		resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
+		if latestAudit, err := audit.Latest(ctx, AUDIT_PAUSED, resource); err == nil {
+			pausedBy = fmt.Sprintf("at %s by %s(%s) - \"%s\"", latestAudit.Time.String(), latestAudit.Actor, latestAudit.Email, latestAudit.Message)
+		}
+	}
+	stageStatus.UpdatePausedBy(pausedBy)
+	stageStatus.UpdateState(state)
+}
@@ -0,0 +1,86 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateWatchStatus(t *testing.T) {
+	_, pipelineRun := setupOutputTest(t)
+	ctx := context.Background()
+
+	stageStatus := &status{m: make(map[string]*run)}
+	updateWatchStatus(ctx, stageStatus, pipelineRun)
+	assert.Equal(t, ROLLBACK, stageStatus.GetState())
+	deploy := stageStatus.GetCache(getStageName(0, pipelineRun.Stages[0].Name))
+	assert.Equal(t, "Failed", deploy.state)
+	assert.Equal(t, "Octo Cat(octocat)", deploy.approvedBy)
+	require.NotNil(t, deploy.rollback)
+	assert.Equal(t, "Success", deploy.rollback.state)
+
+	m := initialModel(watchPipeline(pipelineRun), stageStatus, pipelineRun.Created.String(), pipelineRun.Id)
+	m.watch = true
+	assert.True(t, m.done(stageStatus.GetState()))
+	assert.Contains(t, m.View(), "Watching pipeline Output run id run1")
+	assert.Contains(t, m.View(), "Rollback Success")
+
+	// run paused by user keeps watch running, execute UI exits
+	pipelineRun.State = string(IN_PROGRESS)
+	pipelineRun.Paused = true
+	resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
+	require.NoError(t, audit.Save(ctx, AUDIT_PAUSED, resource, "octocat", "octocat@example.com", users.SOURCE_USER, "hold deploys"))
+	updateWatchStatus(ctx, stageStatus, pipelineRun)
+	assert.Equal(t, PAUSED, stageStatus.GetState())
+	assert.False(t, m.done(PAUSED))
+	assert.True(t, model{}.done(PAUSED))
+	assert.Contains(t, m.View(), "by octocat(octocat@example.com) - \"hold deploys\"")
+
+	pipelineRun.Paused = false
+	updateWatchStatus(ctx, stageStatus, pipelineRun)
+	assert.Equal(t, IN_PROGRESS, stageStatus.GetState())
+	assert.Empty(t, stageStatus.GetPausedBy())
+}
+
+func TestWatchPipelineRun(t *testing.T) {
+	_, pipelineRun := setupOutputTest(t)
+
+	pollInterval := WatchPollInterval
+	WatchPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		WatchPollInterval = pollInterval
+	})
+
+	pipelineRun.State = string(PENDING_APPROVAL)
+	pipelineRun.Stages[1].State = "PendingApproval"
+	require.NoError(t, savePipelineRun(context.Background(), pipelineRun))
+
+	stageStatus := &status{m: make(map[string]*run)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchPipelineRun(ctx, stageStatus, "Output", "run1")
+
+	require.Eventually(t, func() bool {
+		return stageStatus.GetState() == PENDING_APPROVAL
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// approval and completion saved by orchestrator running elsewhere
+	pipelineRun.State = string(SUCCESS)
+	pipelineRun.Stages[1].State = "Success"
+	pipelineRun.Stages[1].Metadata.Approval = StageRunApproval{Name: "Approver", Login: "approver1"}
+	require.NoError(t, savePipelineRun(context.Background(), pipelineRun))
+
+	require.Eventually(t, func() bool {
+		return stageStatus.GetState() == SUCCESS
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Approver(approver1)", stageStatus.GetCache(getStageName(1, pipelineRun.Stages[1].Name)).approvedBy)
+
+	err := WatchPipelineRunUI("Output", "run2")
+	assert.EqualError(t, err, "pipeline run run2 for pipeline Output not found")
+}
@@ -0,0 +1,184 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/store"
+)
+
+const (
+	WorkflowRunPrefix = "workflowrun:"
+)
+
+var (
+	// WebhookFallbackPollInterval is how often github is still polled for a stage run once webhooks deliver its workflow runs
+	WebhookFallbackPollInterval = time.Minute
+	// LowRateLimitPollInterval is how often stages are ticked while github quota is low
+	LowRateLimitPollInterval = 30 * time.Second
+	// WorkflowRunUpdateRetention is how long pushed workflow runs are kept when no orchestrator completes them
+	WorkflowRunUpdateRetention = 24 * time.Hour
+
+	stageRunIdRegex    = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	workflowRunUpdates = &workflowRunCache{m: make(map[string]github.WorkflowRun)}
+)
+
+// workflowRunCache holds workflow runs pushed by webhooks for orchestrators running in this process
+type workflowRunCache struct {
+	m    map[string]github.WorkflowRun
+	lock sync.RWMutex
+}
+
+func (c *workflowRunCache) Set(stageRunId string, workflowRun github.WorkflowRun) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.m[stageRunId] = workflowRun
+}
+
+func (c *workflowRunCache) Get(stageRunId string) (github.WorkflowRun, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	workflowRun, ok := c.m[stageRunId]
+	return workflowRun, ok
+}
+
+func (c *workflowRunCache) Delete(stageRunId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.m, stageRunId)
+}
+
+// StageRunId extracts pippy_run_id from the workflow run name, github sets it from run-name
+func StageRunId(workflowRunName string) string {
+	matches := stageRunIdRegex.FindAllString(strings.ToLower(workflowRunName), -1)
+	if len(matches) <= 0 {
+		return ""
+	}
+	return matches[len(matches)-1]
+}
+
+// UpdateWorkflowRun records workflow run state pushed by github, returns the matching stage run id
+func UpdateWorkflowRun(ctx context.Context, workflowRun github.WorkflowRun) (string, error) {
+	stageRunId := StageRunId(workflowRun.Name)
+	if stageRunId == "" {
+		return "", nil
+	}
+
+	existing, err := GetWorkflowRunUpdate(ctx, stageRunId)
+	if err != nil {
+		return "", err
+	}
+
+	// deliveries can arrive out of order, keep the latest state
+	if existing != nil && existing.UpdatedAt.After(workflowRun.UpdatedAt) {
+		return stageRunId, nil
+	}
+
+	workflowRunUpdates.Set(stageRunId, workflowRun)
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	if err := dbStore.SaveJSON(WorkflowRunPrefix+stageRunId, &workflowRun); err != nil {
+		return "", err
+	}
+
+	return stageRunId, pruneWorkflowRunUpdates(ctx, stageRunId)
+}
+
+// pruneWorkflowRunUpdates deletes other pushed workflow runs not updated within retention,
+// runs of stages never orchestrated in this store or deliveries arriving after stage completed
+func pruneWorkflowRunUpdates(ctx context.Context, stageRunId string) error {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	var expired []string
+	err = dbStore.LoadValues(WorkflowRunPrefix, func(key, value any) error {
+		if key.(string) == WorkflowRunPrefix+stageRunId {
+			return nil
+		}
+		workflowRun := github.WorkflowRun{}
+		if err := json.Unmarshal([]byte(value.(string)), &workflowRun); err != nil {
+			return err
+		}
+		if time.Since(workflowRun.UpdatedAt) > WorkflowRunUpdateRetention {
+			expired = append(expired, key.(string))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		workflowRunUpdates.Delete(strings.TrimPrefix(key, WorkflowRunPrefix))
+		if err := dbStore.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteWorkflowRunUpdate removes workflow run pushed for stage run id, called once stage run completes
+func deleteWorkflowRunUpdate(ctx context.Context, stageRunId string) error {
+	workflowRunUpdates.Delete(stageRunId)
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	return dbStore.Delete(WorkflowRunPrefix + stageRunId)
+}
+
+// GetWorkflowRunUpdate returns latest workflow run pushed by webhooks for stage run id, nil if none received
+func GetWorkflowRunUpdate(ctx context.Context, stageRunId string) (*github.WorkflowRun, error) {
+	if workflowRun, ok := workflowRunUpdates.Get(stageRunId); ok {
+		return &workflowRun, nil
+	}
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	workflowRun := &github.WorkflowRun{}
+	if err := dbStore.LoadJSON(WorkflowRunPrefix+stageRunId, workflowRun); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return workflowRun, nil
+}
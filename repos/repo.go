@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 
 	"github.com/urfave/cli/v3"
 )
@@ -20,9 +21,8 @@ func Command() *cli.Command {
 				Name:  "list",
 				Usage: "list repos in a org",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := RunListRepos(github.DefaultClient, c.String("type")); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := RunListRepos(github.DefaultClient, c.String("type"), c.StringSlice("org"), c.StringSlice("repo"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -40,6 +40,16 @@ func Command() *cli.Command {
 							return fmt.Errorf("please provide a valid value in %s", strings.Join(validValues, ","))
 						},
 					},
+					&cli.StringSliceFlag{
+						Name:     "org",
+						Usage:    "only repos in orgs matching glob, --org nixmade --org 'team-*'",
+						Required: false,
+					},
+					&cli.StringSliceFlag{
+						Name:     "repo",
+						Usage:    "only repos matching org/repo glob, --repo 'nixmade/*'",
+						Required: false,
+					},
 				},
 			},
 		},
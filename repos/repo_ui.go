@@ -2,11 +2,15 @@ package repos
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 )
 
 var (
@@ -55,14 +59,86 @@ func Run(items []list.Item) error {
 	return nil
 }
 
-func RunListRepos(c github.Client, repoType string) error {
-	repoItems, err := c.ListRepos(repoType)
+type repoOutput struct {
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	Description string `json:"description"`
+}
+
+// RunListRepos opens interactive list unless output or filters are provided
+func RunListRepos(c github.Client, repoType string, orgs, patterns []string, output string) error {
+	repoItems, err := ListRepos(c, repoType, orgs, patterns)
 	if err != nil {
 		return err
 	}
-	var listItems []list.Item
+
+	if output == "" && len(orgs) <= 0 && len(patterns) <= 0 {
+		var listItems []list.Item
+		for _, repoItem := range repoItems {
+			listItems = append(listItems, repoItem)
+		}
+		return Run(listItems)
+	}
+
+	repoOutputs := []repoOutput{}
+	rows := [][]string{}
 	for _, repoItem := range repoItems {
-		listItems = append(listItems, repoItem)
+		repoOutputs = append(repoOutputs, repoOutput{Name: repoItem.Name, Url: repoItem.Url, Description: repoItem.Detail})
+		rows = append(rows, []string{repoItem.Name, repoItem.Url, repoItem.Detail})
 	}
-	return Run(listItems)
+	return helpers.WriteOutput(os.Stdout, output, repoOutputs, []string{"REPO", "URL", "DESCRIPTION"}, rows)
+}
+
+// ListRepos repos of type filtered by org and org/repo glob patterns, no filters lists all
+func ListRepos(c github.Client, repoType string, orgs, patterns []string) ([]github.Repo, error) {
+	repoItems, err := c.ListRepos(repoType)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []github.Repo
+	for _, repoItem := range repoItems {
+		org, _, _ := strings.Cut(repoItem.Name, "/")
+		if len(orgs) > 0 && !helpers.MatchAny(orgs, org) {
+			continue
+		}
+		if len(patterns) > 0 && !helpers.MatchAny(patterns, repoItem.Name) {
+			continue
+		}
+		filtered = append(filtered, repoItem)
+	}
+	return filtered, nil
+}
+
+// MatchRepos org/repo names selected by patterns, names without glob characters are used as is
+// so repos outside of repo type can be selected
+func MatchRepos(c github.Client, repoType string, patterns []string) ([]string, error) {
+	var names, globs []string
+	for _, pattern := range patterns {
+		if !helpers.IsGlob(pattern) {
+			if !strings.Contains(pattern, "/") {
+				return nil, fmt.Errorf("repo %s is not in org/repo format", pattern)
+			}
+			names = append(names, pattern)
+			continue
+		}
+		globs = append(globs, pattern)
+	}
+
+	if len(globs) > 0 {
+		repoItems, err := ListRepos(c, repoType, nil, globs)
+		if err != nil {
+			return nil, err
+		}
+		if len(repoItems) <= 0 {
+			return nil, fmt.Errorf("no %s repos match %s", repoType, strings.Join(globs, ","))
+		}
+		for _, repoItem := range repoItems {
+			if !slices.Contains(names, repoItem.Name) {
+				names = append(names, repoItem.Name)
+			}
+		}
+	}
+
+	return names, nil
 }
@@ -0,0 +1,485 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/charmbracelet/huh"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	SecretPrefix string = "secret:"
+	// REF_SECRET refers to a secret saved with pippy secret set eg: secret:datadog-api-key
+	REF_SECRET string = "secret:"
+	// REF_ENV refers to an environment variable eg: env:DD_API_KEY
+	REF_ENV string = "env:"
+	// MasterKeyEnv base64 encoded 32 byte master key, defaults to ~/.pippy/master.key
+	MasterKeyEnv string = "PIPPY_MASTER_KEY"
+	// PassphraseEnv optional passphrase protecting master key, key file alone cannot decrypt when set
+	PassphraseEnv    string = "PIPPY_PASSPHRASE"
+	masterKeySize    int    = 32
+	passphraseRounds int    = 600000
+)
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+	validName         = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+	// derived passphrase keys are cached, key derivation is intentionally slow
+	derivedKeys    = make(map[string][]byte)
+	derivedKeyLock sync.Mutex
+)
+
+type Secret struct {
+	Name string `json:"name"`
+	// Value nonce and AES-GCM sealed value encoded as base64
+	Value   string    `json:"value"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// masterKey local key, stretched with passphrase when provided
+func masterKey() ([]byte, error) {
+	key, err := localKey()
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return key, nil
+	}
+
+	derivedKeyLock.Lock()
+	defer derivedKeyLock.Unlock()
+
+	cacheKey := string(key) + passphrase
+	if derived, ok := derivedKeys[cacheKey]; ok {
+		return derived, nil
+	}
+	derived, err := pbkdf2.Key(sha256.New, passphrase, key, passphraseRounds, masterKeySize)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys[cacheKey] = derived
+	return derived, nil
+}
+
+// localKey reads master key from env or local key file, key file is created on first use
+func localKey() ([]byte, error) {
+	if encoded := os.Getenv(MasterKeyEnv); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s, %v", MasterKeyEnv, err)
+		}
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("%s must be %d bytes, found %d", MasterKeyEnv, masterKeySize, len(key))
+		}
+		return key, nil
+	}
+
+	userHomeDir, err := store.GetHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	keyDir := path.Join(userHomeDir, ".pippy")
+	keyFile := path.Join(keyDir, "master.key")
+	key, err := os.ReadFile(keyFile)
+	if err == nil {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes, found %d", keyFile, masterKeySize, len(key))
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key = make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(keyDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return newKeyGCM(key)
+}
+
+func newKeyGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewDataKey random key for EncryptWithKey, callers seal it with Encrypt before storing it
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encrypt seals value with master key, name is authenticated so sealed values cannot be swapped
+func Encrypt(name, value string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	return seal(gcm, name, value)
+}
+
+// Decrypt opens value sealed with Encrypt using same name
+func Decrypt(name, value string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(gcm, name, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s, master key or passphrase changed? %v", name, err)
+	}
+	return plaintext, nil
+}
+
+// EncryptWithKey seals value with data key instead of master key, dropping data key shreds every value sealed with it
+func EncryptWithKey(key []byte, name, value string) (string, error) {
+	gcm, err := newKeyGCM(key)
+	if err != nil {
+		return "", err
+	}
+	return seal(gcm, name, value)
+}
+
+// DecryptWithKey opens value sealed with EncryptWithKey using same key and name
+func DecryptWithKey(key []byte, name, value string) (string, error) {
+	gcm, err := newKeyGCM(key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(gcm, name, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s, data key changed? %v", name, err)
+	}
+	return plaintext, nil
+}
+
+func seal(gcm cipher.AEAD, name, value string) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(gcm cipher.AEAD, name, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("%s is corrupted", name)
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %s, use letters, digits, _ . or -", name)
+	}
+	return nil
+}
+
+// Set encrypts and saves secret, existing secret is replaced
+func Set(ctx context.Context, name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("secret %s value cannot be empty", name)
+	}
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	now := time.Now().UTC()
+	secret := &Secret{Name: name, Created: now}
+	if err := dbStore.LoadJSON(SecretPrefix+name, secret); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return err
+	}
+
+	secret.Value, err = Encrypt(name, value)
+	if err != nil {
+		return err
+	}
+	secret.Updated = now
+
+	return dbStore.SaveJSON(SecretPrefix+name, secret)
+}
+
+// Get decrypted secret value
+func Get(ctx context.Context, name string) (string, error) {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	secret := &Secret{}
+	if err := dbStore.LoadJSON(SecretPrefix+name, secret); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return "", fmt.Errorf("%w %s", ErrSecretNotFound, name)
+		}
+		return "", err
+	}
+
+	return Decrypt(name, secret.Value)
+}
+
+// List secrets sorted by name, values are not decrypted
+func List(ctx context.Context) ([]Secret, error) {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	var secrets []Secret
+	secretItr := func(key any, value any) error {
+		var secret Secret
+		if err := json.Unmarshal([]byte(value.(string)), &secret); err != nil {
+			return err
+		}
+		secrets = append(secrets, secret)
+		return nil
+	}
+	if err := dbStore.LoadValues(SecretPrefix, secretItr); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+
+	return secrets, nil
+}
+
+func Delete(ctx context.Context, name string) error {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	secret := &Secret{}
+	if err := dbStore.LoadJSON(SecretPrefix+name, secret); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return fmt.Errorf("%w %s", ErrSecretNotFound, name)
+		}
+		return err
+	}
+
+	return dbStore.Delete(SecretPrefix + name)
+}
+
+// IsRef value refers to a secret or environment variable instead of holding plaintext
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, REF_SECRET) || strings.HasPrefix(value, REF_ENV)
+}
+
+// Ref reference for secret name
+func Ref(name string) string {
+	return REF_SECRET + name
+}
+
+// Resolve returns value of secret:NAME or env:VAR references, any other value is returned as is
+func Resolve(ctx context.Context, value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, REF_SECRET); ok {
+		return Get(ctx, name)
+	}
+	if name, ok := strings.CutPrefix(value, REF_ENV); ok {
+		envValue, found := os.LookupEnv(name)
+		if !found || envValue == "" {
+			return "", fmt.Errorf("environment variable %s not set", name)
+		}
+		return envValue, nil
+	}
+	return value, nil
+}
+
+// SecretOutput secret printed by secret list with --output json or yaml, value is never included
+type SecretOutput struct {
+	Name      string    `json:"name"`
+	Reference string    `json:"reference"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+func ListSecretsUI(output string) error {
+	secrets, err := List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(secrets) <= 0 && !helpers.Structured(output) {
+		fmt.Print("\nNo secrets found, use pippy secret set\n\n")
+		return nil
+	}
+
+	secretOutputs := []SecretOutput{}
+	rows := [][]string{}
+	for _, secret := range secrets {
+		secretOutputs = append(secretOutputs, SecretOutput{Name: secret.Name, Reference: Ref(secret.Name), Created: secret.Created, Updated: secret.Updated})
+		rows = append(rows, []string{secret.Name, Ref(secret.Name), secret.Created.Format(time.RFC3339), secret.Updated.Format(time.RFC3339)})
+	}
+
+	return helpers.WriteOutput(os.Stdout, output, secretOutputs, []string{"NAME", "REFERENCE", "CREATED", "UPDATED"}, rows)
+}
+
+func SetSecretUI(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	if value == "" {
+		if err := huh.NewInput().
+			Title(fmt.Sprintf("Value for secret %s", name)).
+			EchoMode(huh.EchoModePassword).
+			Value(&value).
+			Validate(func(t string) error {
+				if t == "" {
+					return errors.New("secret value cannot be empty")
+				}
+				return nil
+			}).Run(); err != nil {
+			return err
+		}
+	}
+
+	if err := Set(context.Background(), name, value); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nSaved secret %s, refer to it as %s\n\n", name, Ref(name))
+	return nil
+}
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "secret",
+		Usage: "secret management, secrets are encrypted with local master key",
+		Commands: []*cli.Command{
+			{
+				Name:  "set",
+				Usage: "create or update secret, value is prompted when not provided",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := SetSecretUI(c.String("name"), c.String("value")); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "secret name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "value",
+						Usage:    "secret value, prefer prompt to keep value out of shell history",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "list secret names",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := ListSecretsUI(c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "delete",
+				Usage: "delete secret",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := Delete(ctx, c.String("name")); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					fmt.Printf("\nDeleted secret %s\n\n", c.String("name"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "secret name",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
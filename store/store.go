@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
 
 	"github.com/nixmade/orchestrator/store"
 )
@@ -17,6 +18,14 @@ var (
 	PUBLIC_SCHEMA  = store.PUBLIC_SCHEMA
 )
 
+// badger allows a single open handle per directory, share it across goroutines
+// and close it once the last user is done so other processes can open it
+var (
+	sharedStore store.Store
+	sharedRefs  int
+	sharedLock  sync.Mutex
+)
+
 type contextKey struct {
 	name string
 }
@@ -62,6 +71,14 @@ func Get(ctx context.Context) (store.Store, error) {
 		return store.NewPgxStore(os.Getenv("DATABASE_URL"), schemaName, tableName)
 	}
 
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	if sharedStore != nil {
+		sharedRefs++
+		return sharedStore, nil
+	}
+
 	userHomeDir, err := GetHomeDir()
 	if err != nil {
 		return nil, err
@@ -76,6 +93,9 @@ func Get(ctx context.Context) (store.Store, error) {
 		return nil, fmt.Errorf("failed to create store %v", err)
 	}
 
+	sharedStore = dbStore
+	sharedRefs = 1
+
 	return dbStore, nil
 }
 
@@ -83,5 +103,17 @@ func Close(dbStore store.Store) error {
 	if defaultStore != nil {
 		return nil
 	}
+
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	if dbStore == sharedStore {
+		sharedRefs--
+		if sharedRefs > 0 {
+			return nil
+		}
+		sharedStore = nil
+	}
+
 	return dbStore.Close()
 }
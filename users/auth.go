@@ -2,17 +2,19 @@ package users
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"net/url"
-	"os/exec"
-	"runtime"
+	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/secrets"
 
 	"github.com/nixmade/pippy/store"
 
@@ -43,6 +45,17 @@ func Command() *cli.Command {
 					return nil
 				},
 			},
+			{
+				Name:  "logout",
+				Usage: "logout user, removes cached github tokens",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := LogoutUser(); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					return nil
+				},
+			},
 		},
 	}
 }
@@ -55,9 +68,123 @@ type UserStore struct {
 	RefreshTokenExpiresIn int64      `json:"refresh_token_expires_in"`
 	RefreshTime           int64      `json:"refresh_time"`
 	GithubUser            githubUser `json:"user"`
+	// Encrypted access and refresh tokens are sealed with local master key
+	Encrypted bool `json:"encrypted,omitempty"`
+	// DataKey access and refresh tokens are sealed with profile data key instead of master key
+	DataKey bool `json:"data_key,omitempty"`
+}
+
+// dataKeyFile profile data key sealing cached tokens, it is kept out of the store so logout can overwrite it
+func dataKeyFile(profile *Profile) (string, error) {
+	userHomeDir, err := store.GetHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(userHomeDir, ".pippy", "keys", profile.Name+".key"), nil
+}
+
+// tokensDataKey profile data key sealed with master key, key is created on first use
+func tokensDataKey(profile *Profile) ([]byte, error) {
+	keyFile, err := dataKeyFile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return rotateTokensDataKey(profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := secrets.Decrypt(profile.tokensKey()+"/data_key", string(sealed))
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// rotateTokensDataKey overwrites profile data key with a new key, tokens sealed with previous key can no longer be decrypted
+func rotateTokensDataKey(profile *Profile) ([]byte, error) {
+	keyFile, err := dataKeyFile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := secrets.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := secrets.Encrypt(profile.tokensKey()+"/data_key", base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path.Dir(keyFile), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, []byte(sealed), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// sealTokens copy of cache with access and refresh tokens encrypted with profile data key, tokens are bound to cache key
+func sealTokens(profile *Profile, cacheStore *UserStore) (*UserStore, error) {
+	dataKey, err := tokensDataKey(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	key := profile.tokensKey()
+	sealed := *cacheStore
+	if sealed.AccessToken, err = secrets.EncryptWithKey(dataKey, key+"/access_token", cacheStore.AccessToken); err != nil {
+		return nil, err
+	}
+	if cacheStore.RefreshToken != "" {
+		if sealed.RefreshToken, err = secrets.EncryptWithKey(dataKey, key+"/refresh_token", cacheStore.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+	sealed.Encrypted = true
+	sealed.DataKey = true
+	return &sealed, nil
+}
+
+// openTokens decrypts access and refresh tokens in place, tokens sealed before data keys are opened with master key
+func openTokens(profile *Profile, cacheStore *UserStore) error {
+	decrypt := secrets.Decrypt
+	if cacheStore.DataKey {
+		dataKey, err := tokensDataKey(profile)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt cached tokens, login again using pippy user login, %v", err)
+		}
+		decrypt = func(name, value string) (string, error) {
+			return secrets.DecryptWithKey(dataKey, name, value)
+		}
+	}
+
+	key := profile.tokensKey()
+	var err error
+	if cacheStore.AccessToken, err = decrypt(key+"/access_token", cacheStore.AccessToken); err != nil {
+		return fmt.Errorf("failed to decrypt cached tokens, login again using pippy user login, %v", err)
+	}
+	if cacheStore.RefreshToken != "" {
+		if cacheStore.RefreshToken, err = decrypt(key+"/refresh_token", cacheStore.RefreshToken); err != nil {
+			return fmt.Errorf("failed to decrypt cached tokens, login again using pippy user login, %v", err)
+		}
+	}
+	cacheStore.Encrypted = false
+	cacheStore.DataKey = false
+	return nil
 }
 
 func CacheTokens(cacheStore *UserStore) error {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return err
+	}
+
 	dbStore, err := store.Get(context.Background())
 	if err != nil {
 		return err
@@ -76,10 +203,20 @@ func CacheTokens(cacheStore *UserStore) error {
 		cacheStore.GithubUser = *user
 	}
 
-	return dbStore.SaveJSON(Settings, cacheStore)
+	sealed, err := sealTokens(profile, cacheStore)
+	if err != nil {
+		return err
+	}
+
+	return dbStore.SaveJSON(profile.tokensKey(), sealed)
 }
 
 func GetCachedTokens() (*UserStore, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	dbStore, err := store.Get(context.Background())
 	if err != nil {
 		return nil, err
@@ -91,16 +228,77 @@ func GetCachedTokens() (*UserStore, error) {
 	}()
 
 	cacheStore := &UserStore{}
-	if err := dbStore.LoadJSON(Settings, cacheStore); err != nil {
+	if err := dbStore.LoadJSON(profile.tokensKey(), cacheStore); err != nil {
 		if errors.Is(err, store.ErrKeyNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
+	if cacheStore.DataKey {
+		if err := openTokens(profile, cacheStore); err != nil {
+			return nil, err
+		}
+		return cacheStore, nil
+	}
+
+	if cacheStore.Encrypted {
+		if err := openTokens(profile, cacheStore); err != nil {
+			return nil, err
+		}
+	}
+
+	// tokens cached before encryption or data keys are migrated
+	if cacheStore.AccessToken != "" {
+		sealed, err := sealTokens(profile, cacheStore)
+		if err != nil {
+			return nil, err
+		}
+		if err := dbStore.SaveJSON(profile.tokensKey(), sealed); err != nil {
+			return nil, err
+		}
+	}
+
 	return cacheStore, nil
 }
 
+// LogoutUser deletes cached tokens and rotates profile data key, sealed tokens left in store files cannot be decrypted
+func LogoutUser() error {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return err
+	}
+
+	dbStore, err := store.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	cacheStore := &UserStore{}
+	if err := dbStore.LoadJSON(profile.tokensKey(), cacheStore); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			fmt.Println("Not logged in")
+			return nil
+		}
+		return err
+	}
+
+	if err := dbStore.Delete(profile.tokensKey()); err != nil {
+		return err
+	}
+	if _, err := rotateTokensDataKey(profile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Logged out %s, revoke pippy access if required at %s/settings/applications\n", cacheStore.GithubUser.Login, profile.WebUrl)
+	return nil
+}
+
 func GetCachedAccessToken() (string, error) {
 	cachedStore, err := LoginUser()
 	if err != nil {
@@ -114,6 +312,11 @@ func GetCachedAccessToken() (string, error) {
 // Apikey is typically valid for predetermined time, key is automatically refreshed by client
 // if signup is true, signs up user automatically
 func LoginUser() (*UserStore, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	cachedStore, err := GetCachedTokens()
 	if err != nil {
 		return nil, err
@@ -122,7 +325,7 @@ func LoginUser() (*UserStore, error) {
 		if cachedStore.ExpiresIn > 0 {
 			currentTime := time.Now().UTC().Unix()
 			if currentTime > (cachedStore.RefreshTime + cachedStore.ExpiresIn) {
-				userStore, err := RefreshAccessToken(ClientID, "", cachedStore.RefreshToken)
+				userStore, err := RefreshAccessToken(profile.OAuthClientID(), "", cachedStore.RefreshToken)
 				if err != nil {
 					return nil, err
 				}
@@ -132,12 +335,12 @@ func LoginUser() (*UserStore, error) {
 		return cachedStore, nil
 	}
 
-	resp, err := helpers.HttpPost("https://github.com/login/device/code", fmt.Sprintf("client_id=%s&scope=%s", ClientID, Scope))
+	resp, err := helpers.HttpPost(profile.deviceCodeUrl(), fmt.Sprintf("client_id=%s&scope=%s", profile.OAuthClientID(), Scope))
 	if err != nil {
 		return nil, err
 	}
 
-	user_store, err := getAccessToken(string(resp))
+	user_store, err := getAccessToken(profile, string(resp))
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +352,7 @@ func LoginUser() (*UserStore, error) {
 	return user_store, nil
 }
 
-func getAccessToken(resp string) (*UserStore, error) {
+func getAccessToken(profile *Profile, resp string) (*UserStore, error) {
 	values, err := url.ParseQuery(resp)
 	if err != nil {
 		return nil, err
@@ -172,16 +375,16 @@ func getAccessToken(resp string) (*UserStore, error) {
 
 	fmt.Printf("Please enter user verification code %s at %s\n", values.Get("user_code"), verification_url)
 
-	openbrowser(verification_url)
+	browse(verification_url)
 
 	params := url.Values{}
-	params.Set("client_id", ClientID)
+	params.Set("client_id", profile.OAuthClientID())
 	params.Set("device_code", values.Get("device_code"))
 	params.Set("grant_type", GrantType)
 
 	start := time.Now().UTC()
 	for int64(time.Since(start).Seconds()) < expires {
-		resp, err := helpers.HttpPost("https://github.com/login/oauth/access_token", params.Encode())
+		resp, err := helpers.HttpPost(profile.accessTokenUrl(), params.Encode())
 		if err != nil {
 			time.Sleep(time.Duration(interval) * time.Second)
 			continue
@@ -243,6 +446,11 @@ func getAccessToken(resp string) (*UserStore, error) {
 }
 
 func RefreshAccessToken(clientID, clientSecret, refreshToken string) (*UserStore, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	params := url.Values{}
 	params.Set("client_id", clientID)
 	if clientSecret != "" {
@@ -251,7 +459,7 @@ func RefreshAccessToken(clientID, clientSecret, refreshToken string) (*UserStore
 	params.Set("refresh_token", refreshToken)
 	params.Set("grant_type", "refresh_token")
 
-	resp, err := helpers.HttpPost("https://github.com/login/oauth/access_token", params.Encode())
+	resp, err := helpers.HttpPost(profile.accessTokenUrl(), params.Encode())
 	if err != nil {
 		return nil, err
 	}
@@ -288,20 +496,11 @@ func RefreshAccessToken(clientID, clientSecret, refreshToken string) (*UserStore
 	return userStore, nil
 }
 
-func openbrowser(url string) {
-	var err error
+// browse opens verification url, replaced in tests
+var browse = openbrowser
 
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("unsupported platform")
-	}
-	if err != nil {
+func openbrowser(url string) {
+	if err := helpers.OpenBrowser(url); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/pippy/secrets"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRawTokens(t *testing.T) *UserStore {
+	dbStore, err := store.Get(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close(dbStore))
+	}()
+
+	raw := &UserStore{}
+	require.NoError(t, dbStore.LoadJSON(Settings, raw))
+	return raw
+}
+
+func TestCacheTokensEncrypted(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestCacheTokensEncrypted*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	userStore := &UserStore{AccessToken: "gho_access", RefreshToken: "ghr_refresh", GithubUser: githubUser{Login: "user1"}}
+	require.NoError(t, CacheTokens(userStore))
+
+	raw := loadRawTokens(t)
+	assert.True(t, raw.Encrypted)
+	assert.True(t, raw.DataKey)
+	assert.NotContains(t, raw.AccessToken, "gho_access")
+	assert.NotContains(t, raw.RefreshToken, "ghr_refresh")
+
+	cached, err := GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+	assert.Equal(t, "ghr_refresh", cached.RefreshToken)
+	assert.Equal(t, "user1", cached.GithubUser.Login)
+
+	// tokens cannot be decrypted with a different passphrase, data key is kept
+	t.Setenv(secrets.PassphraseEnv, "passphrase")
+	_, err = GetCachedTokens()
+	require.Error(t, err)
+	t.Setenv(secrets.PassphraseEnv, "")
+	cached, err = GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+
+	require.NoError(t, LogoutUser())
+	cached, err = GetCachedTokens()
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	// sealed tokens left behind in store files are shredded with rotated data key
+	profile, err := ActiveProfile()
+	require.NoError(t, err)
+	require.ErrorContains(t, openTokens(profile, raw), "failed to decrypt cached tokens")
+}
+
+func TestCachedTokensMigrated(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestCachedTokensMigrated*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	t.Setenv(secrets.MasterKeyEnv, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+
+	// tokens cached before encryption
+	dbStore, err := store.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, dbStore.SaveJSON(Settings, &UserStore{AccessToken: "gho_access", GithubUser: githubUser{Login: "user1"}}))
+	require.NoError(t, store.Close(dbStore))
+
+	cached, err := GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+
+	raw := loadRawTokens(t)
+	assert.True(t, raw.Encrypted)
+	assert.True(t, raw.DataKey)
+	assert.NotContains(t, raw.AccessToken, "gho_access")
+	assert.Empty(t, raw.RefreshToken)
+
+	cached, err = GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+}
+
+func TestMasterKeySealedTokensMigrated(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestMasterKeySealedTokensMigrated*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	// tokens sealed with master key before data keys
+	accessToken, err := secrets.Encrypt(Settings+"/access_token", "gho_access")
+	require.NoError(t, err)
+	dbStore, err := store.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, dbStore.SaveJSON(Settings, &UserStore{AccessToken: accessToken, Encrypted: true, GithubUser: githubUser{Login: "user1"}}))
+	require.NoError(t, store.Close(dbStore))
+
+	cached, err := GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+
+	raw := loadRawTokens(t)
+	assert.True(t, raw.DataKey)
+	assert.NotEqual(t, accessToken, raw.AccessToken)
+
+	cached, err = GetCachedTokens()
+	require.NoError(t, err)
+	assert.Equal(t, "gho_access", cached.AccessToken)
+}
@@ -0,0 +1,227 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	SOURCE_USER           = "user"
+	SOURCE_TOKEN          = "token"
+	SOURCE_GITHUB_ACTIONS = "github_actions"
+	SOURCE_GITHUB_APP     = "github_app"
+)
+
+var (
+	// headless credentials from flags or environment, cached user is not required when set
+	headless *Credentials
+	// identity resolved once per process
+	currentUser     *Identity
+	currentUserLock sync.Mutex
+)
+
+// Credentials headless github credentials, either a token or github app
+type Credentials struct {
+	Token          string
+	AppID          int64
+	InstallationID int64
+	PrivateKey     []byte
+}
+
+// Identity acting user reflected in trigger metadata and audits
+type Identity struct {
+	Name   string `json:"name"`
+	Login  string `json:"login"`
+	Email  string `json:"email"`
+	Source string `json:"source"`
+}
+
+type githubApp struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// Flags headless authentication flags, values are also read from environment
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "github endpoint profile, github enterprise server profiles are added using pippy profile set",
+			Value:   DefaultProfile,
+			Sources: cli.EnvVars(ProfileEnv),
+		},
+		&cli.StringFlag{
+			Name:    "github-token",
+			Usage:   "github personal access token or GITHUB_TOKEN in github actions",
+			Sources: cli.EnvVars("PIPPY_GITHUB_TOKEN", "GITHUB_TOKEN"),
+		},
+		&cli.Int64Flag{
+			Name:    "github-app-id",
+			Usage:   "github app id, requires installation id and private key file",
+			Sources: cli.EnvVars("PIPPY_GITHUB_APP_ID"),
+		},
+		&cli.Int64Flag{
+			Name:    "github-installation-id",
+			Usage:   "github app installation id",
+			Sources: cli.EnvVars("PIPPY_GITHUB_INSTALLATION_ID"),
+		},
+		&cli.StringFlag{
+			Name:    "github-private-key-file",
+			Usage:   "github app private key pem file",
+			Sources: cli.EnvVars("PIPPY_GITHUB_PRIVATE_KEY_FILE"),
+		},
+	}
+}
+
+// Before configures profile and headless credentials from flags
+func Before(ctx context.Context, c *cli.Command) (context.Context, error) {
+	SetProfile(c.String("profile"))
+	if err := SetCredentials(c.String("github-token"), c.Int64("github-app-id"), c.Int64("github-installation-id"), c.String("github-private-key-file")); err != nil {
+		fmt.Printf("%v\n", err)
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// SetCredentials configures headless credentials, github app takes precedence over token
+func SetCredentials(token string, appID, installationID int64, privateKeyFile string) error {
+	currentUserLock.Lock()
+	defer currentUserLock.Unlock()
+	currentUser = nil
+	headless = nil
+
+	if appID != 0 || installationID != 0 || privateKeyFile != "" {
+		if appID == 0 || installationID == 0 || privateKeyFile == "" {
+			return errors.New("github app requires app id, installation id and private key file")
+		}
+		privateKey, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read github app private key, %v", err)
+		}
+		headless = &Credentials{AppID: appID, InstallationID: installationID, PrivateKey: privateKey}
+		return nil
+	}
+
+	if token != "" {
+		headless = &Credentials{Token: token}
+	}
+	return nil
+}
+
+func resetCurrentUser() {
+	currentUserLock.Lock()
+	defer currentUserLock.Unlock()
+	currentUser = nil
+}
+
+// HeadlessCredentials configured credentials, nil when cached user login is used
+func HeadlessCredentials() *Credentials {
+	return headless
+}
+
+// CurrentUser identity for headless credentials or cached user login
+func CurrentUser() (*Identity, error) {
+	currentUserLock.Lock()
+	defer currentUserLock.Unlock()
+
+	if currentUser != nil {
+		return currentUser, nil
+	}
+
+	identity, err := resolveIdentity()
+	if err != nil {
+		return nil, err
+	}
+	currentUser = identity
+	return currentUser, nil
+}
+
+// ActorContext sets the logged in pippy user as the actor for approvals and audits
+func ActorContext(ctx context.Context) (context.Context, error) {
+	currentUser, err := CurrentUser()
+	if err != nil {
+		return nil, err
+	}
+
+	name := currentUser.Name
+	if name == "" {
+		name = currentUser.Login
+	}
+
+	ctx = context.WithValue(ctx, NameCtx, name)
+	ctx = context.WithValue(ctx, SourceCtx, currentUser.Source)
+	return context.WithValue(ctx, EmailCtx, currentUser.Email), nil
+}
+
+func resolveIdentity() (*Identity, error) {
+	if headless != nil && headless.AppID != 0 {
+		return appIdentity(headless)
+	}
+
+	if headless != nil && headless.Token != "" {
+		user, err := GithubUser(headless.Token)
+		if err == nil {
+			return &Identity{Name: user.Name, Login: user.Login, Email: user.Email, Source: SOURCE_TOKEN}, nil
+		}
+		// GITHUB_TOKEN in github actions cannot read user, actor triggered the workflow
+		if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+			return &Identity{Name: actor, Login: actor, Source: SOURCE_GITHUB_ACTIONS}, nil
+		}
+		return nil, fmt.Errorf("failed to get github user for token, %v", err)
+	}
+
+	userStore, err := GetCachedTokens()
+	if err != nil {
+		return nil, err
+	}
+	if userStore == nil {
+		return nil, errors.New("user not logged in, please run pippy user login or set GITHUB_TOKEN")
+	}
+
+	return &Identity{Name: userStore.GithubUser.Name, Login: userStore.GithubUser.Login, Email: userStore.GithubUser.Email, Source: SOURCE_USER}, nil
+}
+
+// appIdentity github app bot identity, falls back to app id when app cannot be read
+func appIdentity(credentials *Credentials) (*Identity, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Name:   fmt.Sprintf("GitHub App %d", credentials.AppID),
+		Login:  fmt.Sprintf("app-%d[bot]", credentials.AppID),
+		Source: SOURCE_GITHUB_APP,
+	}
+
+	tr, err := ghinstallation.NewAppsTransport(http.DefaultTransport, credentials.AppID, credentials.PrivateKey)
+	if err != nil {
+		return identity, nil
+	}
+	tr.BaseURL = profile.ApiUrl
+	client := &http.Client{Transport: tr}
+	resp, err := client.Get(tr.BaseURL + "/app")
+	if err != nil {
+		return identity, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var app githubApp
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&app) != nil || app.Slug == "" {
+		return identity, nil
+	}
+
+	identity.Name = app.Name
+	identity.Login = app.Slug + "[bot]"
+	return identity, nil
+}
@@ -0,0 +1,98 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupGithubApi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/user" && r.Header.Get("Authorization") == "token pat":
+			_, _ = w.Write([]byte(`{"login":"user1","name":"User One","email":"user1@example.com"}`))
+		case r.URL.Path == "/app" && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "):
+			_, _ = w.Write([]byte(`{"slug":"pippy-ci","name":"Pippy CI"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	UseProfile(&Profile{Name: "ghes", ApiUrl: server.URL, UploadUrl: server.URL, WebUrl: server.URL})
+	t.Cleanup(func() {
+		SetProfile(DefaultProfile)
+		require.NoError(t, SetCredentials("", 0, 0, ""))
+	})
+}
+
+func TestCurrentUserHeadless(t *testing.T) {
+	setupGithubApi(t)
+
+	// personal access token
+	require.NoError(t, SetCredentials("pat", 0, 0, ""))
+	assert.Equal(t, &Credentials{Token: "pat"}, HeadlessCredentials())
+	identity, err := CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "User One", Login: "user1", Email: "user1@example.com", Source: SOURCE_TOKEN}, identity)
+
+	// GITHUB_TOKEN cannot read user, actor is used
+	require.NoError(t, SetCredentials("ghs_token", 0, 0, ""))
+	_, err = CurrentUser()
+	require.Error(t, err)
+
+	t.Setenv("GITHUB_ACTOR", "octocat")
+	identity, err = CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "octocat", Login: "octocat", Source: SOURCE_GITHUB_ACTIONS}, identity)
+
+	// github app
+	require.Error(t, SetCredentials("", 1234, 0, ""))
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}), 0600))
+
+	require.NoError(t, SetCredentials("pat", 1234, 5678, keyFile))
+	assert.Equal(t, int64(1234), HeadlessCredentials().AppID)
+	assert.Empty(t, HeadlessCredentials().Token)
+	identity, err = CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "Pippy CI", Login: "pippy-ci[bot]", Source: SOURCE_GITHUB_APP}, identity)
+}
+
+func TestCurrentUserCached(t *testing.T) {
+	setupGithubApi(t)
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestCurrentUserCached*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	require.NoError(t, SetCredentials("", 0, 0, ""))
+	assert.Nil(t, HeadlessCredentials())
+	_, err = CurrentUser()
+	require.Error(t, err)
+
+	require.NoError(t, CacheTokens(&UserStore{AccessToken: "gho_access", GithubUser: githubUser{Login: "user1", Name: "User One"}}))
+	identity, err := CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "User One", Login: "user1", Source: SOURCE_USER}, identity)
+}
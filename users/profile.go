@@ -0,0 +1,372 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	DefaultProfile string = "default"
+	ProfilePrefix  string = "profile:"
+	// ProfileEnv selects active profile, same as --profile
+	ProfileEnv string = "PIPPY_PROFILE"
+	// enterpriseApiPath github enterprise server api is served from web url
+	enterpriseApiPath string = "/api/v3"
+)
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+
+	profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+	// githubProfile github.com endpoints used by default profile
+	githubProfile = Profile{
+		Name:      DefaultProfile,
+		ApiUrl:    "https://api.github.com",
+		UploadUrl: "https://uploads.github.com",
+		WebUrl:    "https://github.com",
+	}
+
+	activeProfileName = DefaultProfile
+	activeProfile     *Profile
+	profileLock       sync.Mutex
+)
+
+// Profile github endpoints, github enterprise server is configured as a separate profile
+type Profile struct {
+	Name      string `json:"name"`
+	ApiUrl    string `json:"api_url"`
+	UploadUrl string `json:"upload_url"`
+	// WebUrl serves oauth device flow endpoints
+	WebUrl   string `json:"web_url"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// NewProfile validates endpoints, upload and web urls are derived for enterprise server api urls ending with /api/v3
+func NewProfile(name, apiUrl, uploadUrl, webUrl, clientID string) (*Profile, error) {
+	if !profileNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid profile name %s, only letters, digits, '_', '.' and '-' are allowed", name)
+	}
+	if name == DefaultProfile {
+		return nil, fmt.Errorf("profile %s is reserved for github.com", DefaultProfile)
+	}
+
+	apiUrl = strings.TrimRight(apiUrl, "/")
+	uploadUrl = strings.TrimRight(uploadUrl, "/")
+	webUrl = strings.TrimRight(webUrl, "/")
+
+	if serverUrl, ok := strings.CutSuffix(apiUrl, enterpriseApiPath); ok {
+		if webUrl == "" {
+			webUrl = serverUrl
+		}
+		if uploadUrl == "" {
+			uploadUrl = serverUrl + "/api/uploads"
+		}
+	}
+
+	for flag, value := range map[string]string{"api url": apiUrl, "upload url": uploadUrl, "web url": webUrl} {
+		if value == "" {
+			return nil, fmt.Errorf("%s is required, it can only be derived from api url ending with %s", flag, enterpriseApiPath)
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid %s %s", flag, value)
+		}
+	}
+
+	return &Profile{Name: name, ApiUrl: apiUrl, UploadUrl: uploadUrl, WebUrl: webUrl, ClientID: clientID}, nil
+}
+
+// IsDefault profile uses github.com
+func (p *Profile) IsDefault() bool {
+	return p.Name == DefaultProfile
+}
+
+// OAuthClientID client id used for device flow, defaults to pippy client id
+func (p *Profile) OAuthClientID() string {
+	if p.ClientID != "" {
+		return p.ClientID
+	}
+	return ClientID
+}
+
+func (p *Profile) deviceCodeUrl() string {
+	return p.WebUrl + "/login/device/code"
+}
+
+func (p *Profile) accessTokenUrl() string {
+	return p.WebUrl + "/login/oauth/access_token"
+}
+
+// tokensKey cached tokens are kept per profile, default profile keeps tokens cached before profiles existed
+func (p *Profile) tokensKey() string {
+	if p.IsDefault() {
+		return Settings
+	}
+	return Settings + ":" + p.Name
+}
+
+// SetProfile selects active profile by name, profile is loaded on first use
+func SetProfile(name string) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	profileLock.Lock()
+	activeProfileName = name
+	activeProfile = nil
+	profileLock.Unlock()
+
+	resetCurrentUser()
+}
+
+// UseProfile sets active profile without loading it from store
+func UseProfile(profile *Profile) {
+	profileLock.Lock()
+	activeProfileName = profile.Name
+	activeProfile = profile
+	profileLock.Unlock()
+
+	resetCurrentUser()
+}
+
+// ActiveProfile profile selected using --profile or PIPPY_PROFILE, github.com when not set
+func ActiveProfile() (*Profile, error) {
+	profileLock.Lock()
+	defer profileLock.Unlock()
+
+	if activeProfile != nil {
+		return activeProfile, nil
+	}
+
+	profile, err := GetProfile(context.Background(), activeProfileName)
+	if err != nil {
+		return nil, err
+	}
+	activeProfile = profile
+	return activeProfile, nil
+}
+
+func GetProfile(ctx context.Context, name string) (*Profile, error) {
+	if name == DefaultProfile {
+		profile := githubProfile
+		return &profile, nil
+	}
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	profile := &Profile{}
+	if err := dbStore.LoadJSON(ProfilePrefix+name, profile); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w %s, add using pippy profile set", ErrProfileNotFound, name)
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func SaveProfile(ctx context.Context, profile *Profile) error {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	return dbStore.SaveJSON(ProfilePrefix+profile.Name, profile)
+}
+
+// ListProfiles default profile followed by saved profiles sorted by name
+func ListProfiles(ctx context.Context) ([]Profile, error) {
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	var profiles []Profile
+	profileItr := func(key any, value any) error {
+		var profile Profile
+		if err := json.Unmarshal([]byte(value.(string)), &profile); err != nil {
+			return err
+		}
+		profiles = append(profiles, profile)
+		return nil
+	}
+	if err := dbStore.LoadValues(ProfilePrefix, profileItr); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	return append([]Profile{githubProfile}, profiles...), nil
+}
+
+func DeleteProfile(ctx context.Context, name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("profile %s cannot be deleted", DefaultProfile)
+	}
+
+	dbStore, err := store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(dbStore); closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	profile := &Profile{}
+	if err := dbStore.LoadJSON(ProfilePrefix+name, profile); err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return fmt.Errorf("%w %s", ErrProfileNotFound, name)
+		}
+		return err
+	}
+
+	return dbStore.Delete(ProfilePrefix + name)
+}
+
+// ProfileOutput profile printed by profile list with --output json or yaml
+type ProfileOutput struct {
+	Profile
+	Active bool `json:"active"`
+}
+
+func ListProfilesUI(output string) error {
+	profiles, err := ListProfiles(context.Background())
+	if err != nil {
+		return err
+	}
+
+	active, err := ActiveProfile()
+	if err != nil {
+		return err
+	}
+
+	profileOutputs := []ProfileOutput{}
+	rows := [][]string{}
+	for _, profile := range profiles {
+		activeMark := ""
+		if profile.Name == active.Name {
+			activeMark = "*"
+		}
+		profileOutputs = append(profileOutputs, ProfileOutput{Profile: profile, Active: profile.Name == active.Name})
+		rows = append(rows, []string{profile.Name, profile.ApiUrl, profile.UploadUrl, profile.WebUrl, activeMark})
+	}
+
+	return helpers.WriteOutput(os.Stdout, output, profileOutputs, []string{"NAME", "API URL", "UPLOAD URL", "WEB URL", "ACTIVE"}, rows)
+}
+
+func ProfileCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "profile",
+		Usage: "github endpoint profiles, select using --profile or PIPPY_PROFILE",
+		Commands: []*cli.Command{
+			{
+				Name:  "set",
+				Usage: "create or update github enterprise server profile",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					profile, err := NewProfile(c.String("name"), c.String("api-url"), c.String("upload-url"), c.String("web-url"), c.String("client-id"))
+					if err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					if err := SaveProfile(ctx, profile); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					fmt.Printf("\nSaved profile %s, login using pippy --profile %s user login\n\n", profile.Name, profile.Name)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "profile name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "api-url",
+						Usage:    "api url, https://github.example.com/api/v3",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "upload-url",
+						Usage:    "upload url, derived from api url when blank",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "web-url",
+						Usage:    "web url serving oauth device flow, derived from api url when blank",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "client-id",
+						Usage:    "oauth app client id registered on github enterprise server",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "list profiles",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := ListProfilesUI(c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "delete",
+				Usage: "delete profile, logout using --profile first to remove cached tokens",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if err := DeleteProfile(ctx, c.String("name")); err != nil {
+						fmt.Printf("%v\n", err)
+						return err
+					}
+					fmt.Printf("\nDeleted profile %s\n\n", c.String("name"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "profile name",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package users
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ghesServer github enterprise server stand in serving device flow and user api
+func ghesServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// device flow posts form without content type
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		form, err := url.ParseQuery(string(body))
+		require.NoError(t, err)
+
+		switch r.URL.Path {
+		case "/login/device/code":
+			assert.Equal(t, "ghes-client", form.Get("client_id"))
+			_, _ = w.Write([]byte(url.Values{
+				"device_code":      {"device1"},
+				"user_code":        {"ABCD-1234"},
+				"verification_uri": {"https://ghes.example.com/login/device"},
+				"expires_in":       {"60"},
+				"interval":         {"0"},
+			}.Encode()))
+		case "/login/oauth/access_token":
+			assert.Equal(t, "ghes-client", form.Get("client_id"))
+			accessToken := "ghes_access"
+			if form.Get("grant_type") == "refresh_token" {
+				assert.Equal(t, "ghes_refresh", form.Get("refresh_token"))
+				accessToken = "ghes_refreshed"
+			} else {
+				assert.Equal(t, "device1", form.Get("device_code"))
+			}
+			_, _ = w.Write([]byte(url.Values{
+				"access_token":             {accessToken},
+				"refresh_token":            {"ghes_refresh"},
+				"expires_in":               {"28800"},
+				"refresh_token_expires_in": {"15897600"},
+			}.Encode()))
+		case "/api/v3/user":
+			assert.Contains(t, []string{"token ghes_access", "token ghes_refreshed"}, r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"login":"ghesuser","name":"GHES User","email":"ghesuser@example.com"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewProfile(t *testing.T) {
+	profile, err := NewProfile("ghes", "https://ghes.example.com/api/v3/", "", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Profile{Name: "ghes", ApiUrl: "https://ghes.example.com/api/v3", UploadUrl: "https://ghes.example.com/api/uploads", WebUrl: "https://ghes.example.com"}, profile)
+	assert.Equal(t, ClientID, profile.OAuthClientID())
+
+	profile, err = NewProfile("proxy", "https://api.example.com", "https://uploads.example.com", "https://web.example.com", "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://uploads.example.com", profile.UploadUrl)
+	assert.Equal(t, "client1", profile.OAuthClientID())
+
+	_, err = NewProfile("proxy", "https://api.example.com", "", "", "")
+	require.Error(t, err)
+
+	_, err = NewProfile(DefaultProfile, "https://ghes.example.com/api/v3", "", "", "")
+	require.Error(t, err)
+
+	_, err = NewProfile("bad name", "https://ghes.example.com/api/v3", "", "", "")
+	require.Error(t, err)
+
+	_, err = NewProfile("ghes", "ghes.example.com/api/v3", "", "", "")
+	require.Error(t, err)
+}
+
+func TestProfiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestProfiles*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+	t.Cleanup(func() {
+		SetProfile(DefaultProfile)
+	})
+
+	ctx := context.Background()
+
+	profile, err := ActiveProfile()
+	require.NoError(t, err)
+	assert.True(t, profile.IsDefault())
+	assert.Equal(t, "https://api.github.com", profile.ApiUrl)
+
+	SetProfile("ghes")
+	_, err = ActiveProfile()
+	require.ErrorIs(t, err, ErrProfileNotFound)
+
+	ghes, err := NewProfile("ghes", "https://ghes.example.com/api/v3", "", "", "")
+	require.NoError(t, err)
+	require.NoError(t, SaveProfile(ctx, ghes))
+
+	profile, err = ActiveProfile()
+	require.NoError(t, err)
+	assert.Equal(t, ghes, profile)
+
+	profiles, err := ListProfiles(ctx)
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+	assert.Equal(t, DefaultProfile, profiles[0].Name)
+	assert.Equal(t, "ghes", profiles[1].Name)
+
+	require.Error(t, DeleteProfile(ctx, DefaultProfile))
+	require.NoError(t, DeleteProfile(ctx, "ghes"))
+	require.ErrorIs(t, DeleteProfile(ctx, "ghes"), ErrProfileNotFound)
+}
+
+func TestLoginUserProfile(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestLoginUserProfile*")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	}()
+	store.HomeDir = tempDir
+
+	server := ghesServer(t)
+	ghes, err := NewProfile("ghes", server.URL+"/api/v3", "", "", "ghes-client")
+	require.NoError(t, err)
+	require.NoError(t, SaveProfile(context.Background(), ghes))
+
+	browsed := ""
+	browse = func(url string) { browsed = url }
+	t.Cleanup(func() {
+		browse = openbrowser
+		SetProfile(DefaultProfile)
+	})
+
+	// device flow against enterprise server
+	SetProfile("ghes")
+	userStore, err := LoginUser()
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghes.example.com/login/device", browsed)
+	assert.Equal(t, "ghes_access", userStore.AccessToken)
+	assert.Equal(t, "ghesuser", userStore.GithubUser.Login)
+
+	identity, err := CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "GHES User", Login: "ghesuser", Email: "ghesuser@example.com", Source: SOURCE_USER}, identity)
+
+	// tokens are cached per profile
+	SetProfile(DefaultProfile)
+	cached, err := GetCachedTokens()
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	// expired tokens are refreshed against enterprise server
+	SetProfile("ghes")
+	userStore.RefreshTime = time.Now().UTC().Add(-24 * time.Hour).Unix()
+	require.NoError(t, CacheTokens(userStore))
+
+	accessToken, err := GetCachedAccessToken()
+	require.NoError(t, err)
+	assert.Equal(t, "ghes_refreshed", accessToken)
+
+	require.NoError(t, LogoutUser())
+	cached, err = GetCachedTokens()
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+}
@@ -20,6 +20,8 @@ func (k *contextKey) String() string {
 var (
 	NameCtx  = &contextKey{"UserName"}
 	EmailCtx = &contextKey{"UserEmail"}
+	// SourceCtx identity source of actor, one of SOURCE_*
+	SourceCtx = &contextKey{"UserSource"}
 )
 
 type githubUser struct {
@@ -113,8 +115,13 @@ func GetJSON(url, token string, value interface{}) error {
 }
 
 func GithubUser(accessToken string) (*githubUser, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	var user githubUser
-	err := GetJSON("https://api.github.com/user", fmt.Sprintf("token %s", accessToken), &user)
+	err = GetJSON(profile.ApiUrl+"/user", fmt.Sprintf("token %s", accessToken), &user)
 	if err != nil {
 		return nil, err
 	}
@@ -131,8 +138,13 @@ func GithubUser(accessToken string) (*githubUser, error) {
 }
 
 func GithubPrimaryEmail(accessToken string) (string, error) {
+	profile, err := ActiveProfile()
+	if err != nil {
+		return "", err
+	}
+
 	var emails []*githubEmail
-	err := GetJSON("https://api.github.com/user/emails", fmt.Sprintf("token %s", accessToken), &emails)
+	err = GetJSON(profile.ApiUrl+"/user/emails", fmt.Sprintf("token %s", accessToken), &emails)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,390 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/pipelines"
+	"github.com/nixmade/pippy/store"
+	"github.com/nixmade/pippy/users"
+)
+
+type pipelineSummary struct {
+	Name   string           `json:"name"`
+	Stages int              `json:"stages"`
+	Locked bool             `json:"locked"`
+	Runs   map[string]int64 `json:"runs"`
+}
+
+type pipelineRunDetail struct {
+	Pipeline *pipelines.Pipeline    `json:"pipeline"`
+	Run      *pipelines.PipelineRun `json:"run"`
+	Paused   *audit.Audit           `json:"paused,omitempty"`
+}
+
+type auditEntry struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+	audit.Audit
+}
+
+type actionRequest struct {
+	Stage  int    `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Get().Error().Err(err).Msg("failed to write json response")
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, store.ErrKeyNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func (s *Server) listPipelines(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	summaries, err := pipelineSummaries(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func pipelineSummaries(ctx context.Context) ([]pipelineSummary, error) {
+	savedPipelines, err := pipelines.ListPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []pipelineSummary{}
+	for _, pipeline := range savedPipelines {
+		runs, err := pipelines.GetPipelineRunCountByState(ctx, pipeline.Name)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, pipelineSummary{Name: pipeline.Name, Stages: len(pipeline.Stages), Locked: pipeline.Locked, Runs: runs})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	return summaries, nil
+}
+
+func (s *Server) getPipeline(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pipeline, err := pipelines.GetPipeline(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pipeline)
+}
+
+func (s *Server) listPipelineRuns(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := int64(20)
+	if value := r.URL.Query().Get("count"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid count %s", value)})
+			return
+		}
+		count = parsed
+	}
+
+	pipelineRuns, err := pipelines.GetPipelineRunsN(r.Context(), r.PathValue("name"), count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if pipelineRuns == nil {
+		pipelineRuns = []*pipelines.PipelineRun{}
+	}
+
+	writeJSON(w, http.StatusOK, pipelineRuns)
+}
+
+func (s *Server) getPipelineRun(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	detail, err := getPipelineRunDetail(r.Context(), r.PathValue("name"), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func getPipelineRunDetail(ctx context.Context, name, id string) (*pipelineRunDetail, error) {
+	pipeline, err := pipelines.GetPipeline(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pipelineRun, err := pipelines.GetPipelineRun(ctx, name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &pipelineRunDetail{Pipeline: pipeline, Run: pipelineRun}
+	if pipelineRun.Paused {
+		resource := map[string]string{"Pipeline": pipelineRun.PipelineName, "PipelineRun": pipelineRun.Id}
+		latestAudit, err := audit.Latest(ctx, pipelines.AUDIT_PAUSED, resource)
+		if err != nil {
+			return nil, err
+		}
+		detail.Paused = latestAudit
+	}
+
+	return detail, nil
+}
+
+// loopbackHosts are interchangeable names for a dashboard listening on loopback
+var loopbackHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// allowedHost reports whether host is the address dashboard listens on, rejects dns rebinding to other names
+func (s *Server) allowedHost(host string) bool {
+	addrHost, addrPort, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return strings.EqualFold(host, s.addr)
+	}
+	// listening on all interfaces, any name reaching the server is its own
+	if addrHost == "" || net.ParseIP(addrHost).IsUnspecified() {
+		return true
+	}
+	requestHost, requestPort, err := net.SplitHostPort(host)
+	if err != nil || requestPort != addrPort {
+		return false
+	}
+	if strings.EqualFold(requestHost, addrHost) {
+		return true
+	}
+	return slices.Contains(loopbackHosts, strings.ToLower(addrHost)) && slices.Contains(loopbackHosts, strings.ToLower(requestHost))
+}
+
+// checkAction rejects state changing requests that are not json or not sent from dashboard itself
+func (s *Server) checkAction(r *http.Request) (int, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, errors.New("content type must be application/json")
+	}
+
+	if !s.allowedHost(r.Host) {
+		return http.StatusForbidden, fmt.Errorf("host %s is not allowed", r.Host)
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		originUrl, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(originUrl.Host, r.Host) {
+			return http.StatusForbidden, fmt.Errorf("origin %s is not allowed", origin)
+		}
+	} else if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		return http.StatusForbidden, fmt.Errorf("cross site request from %s is not allowed", site)
+	}
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) runAction(w http.ResponseWriter, r *http.Request, requireReason bool, action func(ctx context.Context, name, id string, req actionRequest) error) {
+	if status, err := s.checkAction(r); err != nil {
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	var req actionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request %v", err)})
+		return
+	}
+
+	if requireReason && strings.TrimSpace(req.Reason) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "reason is required"})
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ctx, err := users.ActorContext(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: err.Error()})
+		return
+	}
+
+	name, id := r.PathValue("name"), r.PathValue("id")
+	if err := action(ctx, name, id, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	detail, err := getPipelineRunDetail(ctx, name, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *Server) approvePipelineRun(w http.ResponseWriter, r *http.Request) {
+	s.runAction(w, r, false, func(ctx context.Context, name, id string, req actionRequest) error {
+		return pipelines.ApprovePipelineRun(ctx, name, id, req.Stage)
+	})
+}
+
+func (s *Server) pausePipelineRun(w http.ResponseWriter, r *http.Request) {
+	s.runAction(w, r, true, func(ctx context.Context, name, id string, req actionRequest) error {
+		return pipelines.PausePipelineRun(ctx, name, id, req.Reason)
+	})
+}
+
+func (s *Server) resumePipelineRun(w http.ResponseWriter, r *http.Request) {
+	s.runAction(w, r, true, func(ctx context.Context, name, id string, req actionRequest) error {
+		return pipelines.ResumePipelineRun(ctx, name, id, req.Reason)
+	})
+}
+
+func (s *Server) listAudits(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	limit := int64(50)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid limit %s", value)})
+			return
+		}
+		limit = parsed
+	}
+
+	audits, err := audit.ListAuditsN(r.Context(), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries := []auditEntry{}
+	for key, data := range audits {
+		strippedAuditKey, _ := strings.CutPrefix(key, audit.AuditPrefix)
+		auditNameId := strings.SplitN(strippedAuditKey, "/", 2)
+		entry := auditEntry{Type: auditNameId[0], Audit: data}
+		if len(auditNameId) > 1 {
+			entry.Id = auditNameId[1]
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// fingerprint summarizes pipelines and latest runs, changes are pushed to dashboard clients
+func (s *Server) fingerprint(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	summaries, err := pipelineSummaries(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pipelineRuns, err := pipelines.GetPipelineRunsN(ctx, "", 20)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode(summaries); err != nil {
+		return "", err
+	}
+	for _, pipelineRun := range pipelineRuns {
+		_, _ = fmt.Fprintf(hash, "%s/%s/%s/%t/%s\n", pipelineRun.PipelineName, pipelineRun.Id, pipelineRun.State, pipelineRun.Paused, pipelineRun.Updated)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// events streams server sent events whenever pipelines or runs change
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "streaming not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger := log.Get().With().Str("Remote", r.RemoteAddr).Logger()
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			current, err := s.fingerprint(r.Context())
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to compute dashboard changes")
+				continue
+			}
+			if current == last {
+				continue
+			}
+			last = current
+			if _, err := fmt.Fprintf(w, "event: update\ndata: {\"fingerprint\":%q}\n\n", current); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
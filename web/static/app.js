@@ -0,0 +1,213 @@
+"use strict";
+
+const app = document.getElementById("app");
+
+function escape(value) {
+  const div = document.createElement("div");
+  div.textContent = value === undefined || value === null ? "" : String(value);
+  return div.innerHTML;
+}
+
+function duration(started, completed) {
+  const start = Date.parse(started);
+  const end = Date.parse(completed);
+  if (!start || !end || end < start) {
+    return "";
+  }
+  const seconds = Math.round((end - start) / 1000);
+  const minutes = Math.floor(seconds / 60);
+  return minutes > 0 ? `${minutes}m${seconds % 60}s` : `${seconds}s`;
+}
+
+function showError(message) {
+  const error = document.getElementById("error");
+  error.textContent = message;
+  error.hidden = false;
+  setTimeout(() => { error.hidden = true; }, 5000);
+}
+
+async function api(path, options) {
+  const response = await fetch(path, options);
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+function post(path, body) {
+  return api(path, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  });
+}
+
+function runPath(name, id) {
+  return `/api/pipelines/${encodeURIComponent(name)}/runs/${encodeURIComponent(id)}`;
+}
+
+async function renderPipelines() {
+  const pipelines = await api("/api/pipelines");
+  const rows = pipelines.map((pipeline) => {
+    const total = Object.values(pipeline.runs || {}).reduce((sum, count) => sum + count, 0);
+    const states = Object.entries(pipeline.runs || {})
+      .map(([state, count]) => `<span class="state ${escape(state)}">${escape(state)}</span> ${count}`)
+      .join(", ");
+    return `<tr>
+      <td><a href="#/pipelines/${encodeURIComponent(pipeline.name)}">${escape(pipeline.name)}</a></td>
+      <td>${pipeline.stages}</td>
+      <td>${total}</td>
+      <td>${states}</td>
+      <td>${pipeline.locked ? '<span class="state Locked">LOCKED</span>' : "NO"}</td>
+    </tr>`;
+  });
+  app.innerHTML = `<h2>Pipelines</h2>
+    <table>
+      <tr><th>NAME</th><th>STAGES</th><th>RUNS</th><th>RUNS BY STATE</th><th>APPROVALS LOCKED</th></tr>
+      ${rows.join("")}
+    </table>`;
+}
+
+async function renderRuns(name) {
+  const runs = await api(`/api/pipelines/${encodeURIComponent(name)}/runs?count=50`);
+  const rows = runs.map((run) => {
+    const inputs = Object.entries(run.input || {}).map(([key, value]) => `${escape(key)}=${escape(value)}`).join(", ");
+    return `<tr>
+      <td>${escape(new Date(run.created).toLocaleString())}</td>
+      <td><a href="#/pipelines/${encodeURIComponent(name)}/runs/${encodeURIComponent(run.id)}">${escape(run.id)}</a></td>
+      <td class="state ${escape(run.state)}">${escape(run.state)}${run.paused ? ' <span class="Paused">(paused)</span>' : ""}</td>
+      <td>${duration(run.created, run.updated)}</td>
+      <td>${inputs}</td>
+      <td>${escape(run.trigger_metadata && run.trigger_metadata.login)}</td>
+    </tr>`;
+  });
+  app.innerHTML = `<h2><a href="#/">Pipelines</a> / ${escape(name)}</h2>
+    <table>
+      <tr><th>TIME</th><th>ID</th><th>STATE</th><th>RUN TIME</th><th>INPUTS</th><th>TRIGGERED BY</th></tr>
+      ${rows.join("")}
+    </table>`;
+}
+
+function renderStage(stage, index, pipelineStage, run) {
+  const title = stage.title || stage.name;
+  const approval = stage.metadata && stage.metadata.approval;
+  const approvedBy = approval && (approval.name || approval.login) ? `${approval.name}(${approval.login || ""})` : "";
+  let html = `<div class="stage ${escape(stage.state)}">
+    <div><strong>${index + 1}. ${escape(title)}</strong>
+      <span class="state ${escape(stage.state)}">${escape(stage.state || "Pending")}</span>
+      ${duration(stage.started, stage.completed)}</div>`;
+  if (stage.url) {
+    html += `<div class="detail"><a href="${escape(stage.url)}" target="_blank" rel="noopener">${escape(stage.url)}</a></div>`;
+  }
+  if (stage.reason) {
+    html += `<div class="detail">${escape(stage.reason)}</div>`;
+  }
+  if (approvedBy) {
+    html += `<div class="detail">Approved by ${escape(approvedBy)}</div>`;
+  } else if (pipelineStage && pipelineStage.approval && !["Success", "Failed", "Canceled"].includes(run.state)) {
+    html += `<div class="detail"><button data-approve="${index}">Approve</button></div>`;
+  }
+  if (stage.rollback) {
+    html += `<div class="rollback">Rollback <span class="state ${escape(stage.rollback.state)}">${escape(stage.rollback.state)}</span> ${escape(stage.rollback.title)}`;
+    if (stage.rollback.url) {
+      html += `<div class="detail"><a href="${escape(stage.rollback.url)}" target="_blank" rel="noopener">${escape(stage.rollback.url)}</a></div>`;
+    }
+    html += "</div>";
+  }
+  return html + "</div>";
+}
+
+async function renderRun(name, id) {
+  const detail = await api(runPath(name, id));
+  const run = detail.run;
+  const stages = (run.stages || []).map((stage, i) => renderStage(stage, i, detail.pipeline.stages[i], run));
+  let status = `<span class="state ${escape(run.state)}">${escape(run.state)}</span> updated ${escape(new Date(run.updated).toLocaleString())}`;
+  if (detail.paused) {
+    status += `<div class="detail Paused">Paused at ${escape(new Date(detail.paused.Time).toLocaleString())} by ${escape(detail.paused.Actor)}(${escape(detail.paused.Email)}) - "${escape(detail.paused.Message)}"</div>`;
+  }
+  if (detail.pipeline.locked) {
+    status += '<div class="detail Locked">Pipeline locked, approvals are denied</div>';
+  }
+  const action = run.paused ? '<button data-action="resume">Resume</button>' : '<button data-action="pause">Pause</button>';
+  app.innerHTML = `<h2><a href="#/">Pipelines</a> / <a href="#/pipelines/${encodeURIComponent(name)}">${escape(name)}</a> / ${escape(id)}</h2>
+    <div>Started ${escape(new Date(run.created).toLocaleString())}, ${status}</div>
+    <div class="actions">${action}</div>
+    ${stages.join("")}`;
+
+  app.querySelectorAll("[data-approve]").forEach((button) => {
+    button.addEventListener("click", async () => {
+      try {
+        await post(`${runPath(name, id)}/approve`, { stage: Number(button.dataset.approve) });
+        await render();
+      } catch (err) {
+        showError(err.message);
+      }
+    });
+  });
+
+  app.querySelectorAll("[data-action]").forEach((button) => {
+    button.addEventListener("click", async () => {
+      const reason = window.prompt(`Reason to ${button.dataset.action} pipeline run`);
+      if (!reason) {
+        return;
+      }
+      try {
+        await post(`${runPath(name, id)}/${button.dataset.action}`, { reason: reason });
+        await render();
+      } catch (err) {
+        showError(err.message);
+      }
+    });
+  });
+}
+
+async function renderAudits() {
+  const audits = await api("/api/audits?limit=100");
+  const rows = audits.map((entry) => {
+    const resource = Object.entries(entry.Resource || {}).map(([key, value]) => `${escape(key)}=${escape(value)}`).join(", ");
+    return `<tr>
+      <td>${escape(new Date(entry.Time).toLocaleString())}</td>
+      <td>${escape(entry.id)}</td>
+      <td>${escape(entry.type)}</td>
+      <td>${resource}</td>
+      <td>${escape(entry.Actor)}</td>
+      <td>${escape(entry.Email)}</td>
+      <td>${escape(entry.Message)}</td>
+    </tr>`;
+  });
+  app.innerHTML = `<h2>Audits</h2>
+    <table>
+      <tr><th>TIME</th><th>ID</th><th>TYPE</th><th>RESOURCE</th><th>ACTOR</th><th>EMAIL</th><th>MESSAGE</th></tr>
+      ${rows.join("")}
+    </table>`;
+}
+
+async function render() {
+  const parts = window.location.hash.replace(/^#\/?/, "").split("/").map(decodeURIComponent);
+  try {
+    if (parts[0] === "audits") {
+      await renderAudits();
+    } else if (parts[0] === "pipelines" && parts[2] === "runs" && parts[3]) {
+      await renderRun(parts[1], parts[3]);
+    } else if (parts[0] === "pipelines" && parts[1]) {
+      await renderRuns(parts[1]);
+    } else {
+      await renderPipelines();
+    }
+  } catch (err) {
+    showError(err.message);
+  }
+}
+
+function listen() {
+  const live = document.getElementById("live");
+  const events = new EventSource("/api/events");
+  events.addEventListener("open", () => live.classList.add("connected"));
+  events.addEventListener("error", () => live.classList.remove("connected"));
+  events.addEventListener("update", () => render());
+}
+
+window.addEventListener("hashchange", render);
+render();
+listen();
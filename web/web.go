@@ -0,0 +1,149 @@
+package web
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/webhooks"
+
+	"github.com/urfave/cli/v3"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "web",
+		Usage: "serve local web dashboard for pipelines and runs",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			// interrupt stops server and cancels pipeline runs triggered by webhooks
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if err := Serve(ctx, c.String("addr"), c.Duration("refresh"), c.String("webhook-secret")); err != nil {
+				fmt.Printf("%v\n", err)
+				return err
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "addr",
+				Usage:    "address to listen on",
+				Value:    "localhost:8080",
+				Required: false,
+			},
+			&cli.DurationFlag{
+				Name:     "refresh",
+				Usage:    "interval to check for pipeline and run changes",
+				Value:    2 * time.Second,
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "webhook-secret",
+				Usage:    "github webhook secret, enables receiving workflow_run events at /webhooks/github",
+				Sources:  cli.EnvVars("PIPPY_WEBHOOK_SECRET"),
+				Required: false,
+			},
+		},
+	}
+}
+
+// Server serves dashboard assets and json api backed by pippy store
+type Server struct {
+	// store opens a single handle per call, serialize access across requests
+	lock    sync.Mutex
+	addr    string
+	refresh time.Duration
+	mux     *http.ServeMux
+}
+
+// NewServer creates dashboard server listening on addr, actions are only accepted from pages served on addr
+func NewServer(addr string, refresh time.Duration) (*Server, error) {
+	s := &Server{addr: addr, refresh: refresh, mux: http.NewServeMux()}
+
+	static, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.Handle("GET /", http.FileServerFS(static))
+	s.mux.HandleFunc("GET /api/pipelines", s.listPipelines)
+	s.mux.HandleFunc("GET /api/pipelines/{name}", s.getPipeline)
+	s.mux.HandleFunc("GET /api/pipelines/{name}/runs", s.listPipelineRuns)
+	s.mux.HandleFunc("GET /api/pipelines/{name}/runs/{id}", s.getPipelineRun)
+	s.mux.HandleFunc("POST /api/pipelines/{name}/runs/{id}/approve", s.approvePipelineRun)
+	s.mux.HandleFunc("POST /api/pipelines/{name}/runs/{id}/pause", s.pausePipelineRun)
+	s.mux.HandleFunc("POST /api/pipelines/{name}/runs/{id}/resume", s.resumePipelineRun)
+	s.mux.HandleFunc("GET /api/audits", s.listAudits)
+	s.mux.HandleFunc("GET /api/events", s.events)
+
+	return s, nil
+}
+
+// Handle registers additional handlers on the dashboard server
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func Serve(ctx context.Context, addr string, refresh time.Duration, webhookSecret string) error {
+	s, err := NewServer(addr, refresh)
+	if err != nil {
+		return err
+	}
+
+	var webhookHandler *webhooks.Handler
+	if webhookSecret != "" {
+		webhookHandler = &webhooks.Handler{Secret: webhookSecret, Context: ctx}
+		s.Handle("POST /webhooks/github", webhookHandler)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	logger := log.Get().With().Str("Addr", addr).Logger()
+	logger.Info().Msg("starting web dashboard")
+	fmt.Printf("Serving pippy dashboard at http://%s\n", addr)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error().Err(err).Msg("failed to shutdown web dashboard")
+		}
+	}()
+
+	err = server.ListenAndServe()
+	// triggered runs are cancelled with ctx, wait for them to save their state
+	if webhookHandler != nil {
+		webhookHandler.Wait()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error().Err(err).Msg("web dashboard stopped")
+		return err
+	}
+
+	return nil
+}
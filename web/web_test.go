@@ -0,0 +1,139 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+	"github.com/nixmade/pippy/pipelines"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAddr = "localhost:8080"
+
+func setupServer(t *testing.T) *Server {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestWeb*")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	})
+	store.HomeDir = tempDir
+
+	ctx := context.Background()
+	pipeline := &pipelines.Pipeline{
+		Name: "web",
+		Stages: []pipelines.Stage{
+			{Repo: "org1/repo1", Workflow: github.Workflow{Id: 1, Name: "Deploy"}},
+		},
+	}
+	require.NoError(t, pipelines.SavePipeline(ctx, pipeline))
+
+	dbStore, err := store.Get(ctx)
+	require.NoError(t, err)
+	pipelineRun := &pipelines.PipelineRun{Id: "run1", PipelineName: "web", State: string(pipelines.IN_PROGRESS), Created: time.Now().UTC()}
+	require.NoError(t, dbStore.SaveJSON(pipelines.PipelineRunPrefix+"web/run1", pipelineRun))
+	require.NoError(t, store.Close(dbStore))
+
+	s, err := NewServer(testAddr, time.Second)
+	require.NoError(t, err)
+	return s
+}
+
+func action(s *Server, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "http://"+testAddr+path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestListPipelines(t *testing.T) {
+	s := setupServer(t)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/api/pipelines", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var summaries []pipelineSummary
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &summaries))
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "web", summaries[0].Name)
+	assert.Equal(t, int64(1), summaries[0].Runs[string(pipelines.IN_PROGRESS)])
+}
+
+func TestRunActionRejected(t *testing.T) {
+	s := setupServer(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{name: "no content type", path: "/api/pipelines/web/runs/run1/pause", headers: map[string]string{}, status: http.StatusUnsupportedMediaType},
+		{name: "form content type", path: "/api/pipelines/web/runs/run1/pause", headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, status: http.StatusUnsupportedMediaType},
+		{name: "text content type", path: "/api/pipelines/web/runs/run1/approve", headers: map[string]string{"Content-Type": "text/plain"}, status: http.StatusUnsupportedMediaType},
+		{name: "cross origin", path: "/api/pipelines/web/runs/run1/pause", headers: map[string]string{"Content-Type": "application/json", "Origin": "https://evil.example.com"}, status: http.StatusForbidden},
+		{name: "other port", path: "/api/pipelines/web/runs/run1/resume", headers: map[string]string{"Content-Type": "application/json", "Origin": "http://localhost:9090"}, status: http.StatusForbidden},
+		{name: "cross site", path: "/api/pipelines/web/runs/run1/approve", headers: map[string]string{"Content-Type": "application/json", "Sec-Fetch-Site": "cross-site"}, status: http.StatusForbidden},
+		{name: "missing reason", path: "/api/pipelines/web/runs/run1/pause", headers: map[string]string{"Content-Type": "application/json; charset=utf-8", "Origin": "http://" + testAddr}, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := action(s, test.path, `{"reason":""}`, test.headers)
+			assert.Equal(t, test.status, recorder.Code, recorder.Body.String())
+		})
+	}
+
+	// dns rebinding reaches server with a host that is not its own
+	req := httptest.NewRequest(http.MethodPost, "http://rebind.example.com:8080/api/pipelines/web/runs/run1/pause", strings.NewReader(`{"reason":"test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "http://rebind.example.com:8080")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	pipelineRun, err := pipelines.GetPipelineRun(context.Background(), "web", "run1")
+	require.NoError(t, err)
+	assert.False(t, pipelineRun.Paused)
+}
+
+func TestPauseResumePipelineRun(t *testing.T) {
+	s := setupServer(t)
+	githubtest.NewServer(t)
+
+	// loopback names are interchangeable
+	req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8080/api/pipelines/web/runs/run1/pause", strings.NewReader(`{"reason":"investigating"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "http://127.0.0.1:8080")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var detail pipelineRunDetail
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &detail))
+	assert.True(t, detail.Run.Paused)
+	require.NotNil(t, detail.Paused)
+	assert.Equal(t, "investigating", detail.Paused.Message)
+
+	// requests without origin are not sent by a browser
+	recorder = action(s, "/api/pipelines/web/runs/run1/resume", `{"reason":"fixed"}`, map[string]string{"Content-Type": "application/json"})
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	pipelineRun, err := pipelines.GetPipelineRun(context.Background(), "web", "run1")
+	require.NoError(t, err)
+	assert.False(t, pipelineRun.Paused)
+}
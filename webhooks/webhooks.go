@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/log"
+	"github.com/nixmade/pippy/pipelines"
+
+	gogithub "github.com/google/go-github/v75/github"
+)
+
+const (
+	SignatureHeader = "X-Hub-Signature-256"
+)
+
+var (
+	ErrMissingSecret = errors.New("webhook secret is not configured")
+)
+
+// Handler receives github webhooks, verifies payload signature and pushes events to pipelines
+type Handler struct {
+	Secret string
+	// Context is the server lifetime, triggered pipeline runs are cancelled once it is done, defaults to context.Background
+	Context context.Context
+	// RunPipeline starts triggered pipeline runs, defaults to pipelines.RunPipeline
+	RunPipeline func(ctx context.Context, name, runId string, inputs map[string]string, templateValues map[string]string, trigger pipelines.TriggerMetadata, force bool) error
+
+	runs sync.WaitGroup
+}
+
+// Wait blocks until triggered pipeline runs return
+func (h *Handler) Wait() {
+	h.runs.Wait()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := log.Get().With().Str("Event", gogithub.WebHookType(r)).Str("Delivery", gogithub.DeliveryID(r)).Logger()
+
+	if h.Secret == "" {
+		logger.Error().Err(ErrMissingSecret).Msg("rejecting github webhook")
+		http.Error(w, ErrMissingSecret.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := gogithub.ValidatePayloadFromBody(r.Header.Get("Content-Type"), r.Body, r.Header.Get(SignatureHeader), []byte(h.Secret))
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid github webhook signature")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := gogithub.ParseWebHook(gogithub.WebHookType(r), payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to parse github webhook")
+		http.Error(w, fmt.Sprintf("failed to parse webhook %v", err), http.StatusBadRequest)
+		return
+	}
+
+	switch event := event.(type) {
+	case *gogithub.WorkflowRunEvent:
+		if event.WorkflowRun == nil {
+			http.Error(w, "workflow run missing from payload", http.StatusBadRequest)
+			return
+		}
+		workflowRun := github.NewWorkflowRun(event.WorkflowRun)
+		stageRunId, err := pipelines.UpdateWorkflowRun(r.Context(), workflowRun)
+		if err != nil {
+			logger.Error().Err(err).Str("WorkflowRun", workflowRun.Name).Msg("failed to update workflow run")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if stageRunId == "" {
+			logger.Info().Str("WorkflowRun", workflowRun.Name).Msg("ignoring workflow run not started by pippy")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		logger.Info().Str("WorkflowRun", workflowRun.Name).Str("RunId", stageRunId).Str("Status", workflowRun.Status).Str("Conclusion", workflowRun.Conclusion).Msg("updated workflow run")
+		w.WriteHeader(http.StatusAccepted)
+	case *gogithub.PushEvent, *gogithub.ReleaseEvent, *gogithub.PullRequestEvent:
+		h.trigger(w, r, payload)
+	default:
+		logger.Info().Msg("ignoring github webhook event")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) trigger(w http.ResponseWriter, r *http.Request, payload []byte) {
+	logger := log.Get().With().Str("Event", gogithub.WebHookType(r)).Str("Delivery", gogithub.DeliveryID(r)).Logger()
+
+	triggeredRuns, err := pipelines.TriggerPipelines(r.Context(), gogithub.WebHookType(r), gogithub.DeliveryID(r), payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to trigger pipelines")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(triggeredRuns) <= 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	runPipeline := h.RunPipeline
+	if runPipeline == nil {
+		runPipeline = pipelines.RunPipeline
+	}
+
+	ctx := h.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for _, triggeredRun := range triggeredRuns {
+		// runs outlive the webhook request but not the server
+		h.runs.Add(1)
+		go func(triggeredRun pipelines.TriggeredRun) {
+			defer h.runs.Done()
+			if err := runPipeline(ctx, triggeredRun.Pipeline, triggeredRun.RunId, triggeredRun.Inputs, nil, triggeredRun.Trigger, false); err != nil {
+				logger.Error().Err(err).Str("Pipeline", triggeredRun.Pipeline).Str("RunId", triggeredRun.RunId).Msg("triggered pipeline run failed")
+			}
+		}(triggeredRun)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,259 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nixmade/pippy/audit"
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/pipelines"
+	"github.com/nixmade/pippy/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret     = "It's a Secret to Everybody"
+	testStageRunId = "0f8fad5b-d9cb-469f-a165-70867728950e"
+)
+
+func sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliver(t *testing.T, handler http.Handler, event, file, secret string) *httptest.ResponseRecorder {
+	return deliverWithId(t, handler, event, file, secret, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+}
+
+func deliverWithId(t *testing.T, handler http.Handler, event, file, secret, deliveryId string) *httptest.ResponseRecorder {
+	payload, err := os.ReadFile(filepath.Join("testdata", file))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", deliveryId)
+	req.Header.Set(SignatureHeader, sign(payload, secret))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func setupStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp(os.TempDir(), "TestWebhooks*")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, os.RemoveAll(tempDir))
+	})
+	store.HomeDir = tempDir
+}
+
+func TestWorkflowRunInvalidSignature(t *testing.T) {
+	setupStore(t)
+	handler := &Handler{Secret: testSecret}
+
+	resp := deliver(t, handler, "workflow_run", "workflow_run_in_progress.json", "wrong secret")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = deliver(t, &Handler{}, "workflow_run", "workflow_run_in_progress.json", "")
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+func TestWorkflowRunEvents(t *testing.T) {
+	setupStore(t)
+	handler := &Handler{Secret: testSecret}
+
+	resp := deliver(t, handler, "workflow_run", "workflow_run_in_progress.json", testSecret)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+
+	workflowRun, err := pipelines.GetWorkflowRunUpdate(context.Background(), testStageRunId)
+	require.NoError(t, err)
+	require.NotNil(t, workflowRun)
+	assert.Equal(t, "in_progress", workflowRun.Status)
+	assert.Equal(t, int64(7102458123), workflowRun.Id)
+	assert.Equal(t, "https://github.com/org1/repo1/actions/runs/7102458123", workflowRun.Url)
+
+	resp = deliver(t, handler, "workflow_run", "workflow_run_completed.json", testSecret)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+
+	// redelivered in progress event is older than completed, should be ignored
+	resp = deliver(t, handler, "workflow_run", "workflow_run_in_progress.json", testSecret)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+
+	workflowRun, err = pipelines.GetWorkflowRunUpdate(context.Background(), testStageRunId)
+	require.NoError(t, err)
+	require.NotNil(t, workflowRun)
+	assert.Equal(t, "completed", workflowRun.Status)
+	assert.Equal(t, "success", workflowRun.Conclusion)
+}
+
+func TestWorkflowRunNotPippy(t *testing.T) {
+	setupStore(t)
+	handler := &Handler{Secret: testSecret}
+
+	resp := deliver(t, handler, "workflow_run", "workflow_run_push.json", testSecret)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = deliver(t, handler, "ping", "workflow_run_push.json", testSecret)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+}
+
+type triggeredRuns struct {
+	lock sync.Mutex
+	runs []pipelines.TriggeredRun
+}
+
+func (r *triggeredRuns) run(ctx context.Context, name, runId string, inputs map[string]string, templateValues map[string]string, trigger pipelines.TriggerMetadata, force bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.runs = append(r.runs, pipelines.TriggeredRun{Pipeline: name, RunId: runId, Inputs: inputs, Trigger: trigger})
+	return nil
+}
+
+func (r *triggeredRuns) get() []pipelines.TriggeredRun {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]pipelines.TriggeredRun{}, r.runs...)
+}
+
+func saveTriggerPipeline(t *testing.T, name string, triggers ...pipelines.Trigger) {
+	pipeline := &pipelines.Pipeline{
+		Name:     name,
+		Stages:   []pipelines.Stage{{Repo: "org1/repo1", Workflow: github.Workflow{Name: "Deploy", Path: ".github/workflows/deploy.yml"}}},
+		Triggers: triggers,
+	}
+	require.NoError(t, pipelines.SavePipeline(context.Background(), pipeline))
+}
+
+func TestTriggerPush(t *testing.T) {
+	setupStore(t)
+	saveTriggerPipeline(t, "pipeline1", pipelines.Trigger{Event: pipelines.TRIGGER_PUSH, Repo: "org1/repo1", Inputs: map[string]string{"version": "${{ event.after }}", "description": "push to ${{ event.ref_name }}"}})
+	saveTriggerPipeline(t, "pipeline2", pipelines.Trigger{Event: pipelines.TRIGGER_PUSH, Repo: "org1/repo1", Branch: "release/*"})
+	saveTriggerPipeline(t, "pipeline3", pipelines.Trigger{Event: pipelines.TRIGGER_PUSH, Repo: "org1/repo2"})
+
+	runs := &triggeredRuns{}
+	handler := &Handler{Secret: testSecret, RunPipeline: runs.run}
+
+	resp := deliverWithId(t, handler, "push", "push_main.json", testSecret, "a1b2c3d4-0001")
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	require.Eventually(t, func() bool { return len(runs.get()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	run := runs.get()[0]
+	assert.Equal(t, "pipeline1", run.Pipeline)
+	assert.NotEmpty(t, run.RunId)
+	assert.Equal(t, map[string]string{"version": "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "description": "push to main"}, run.Inputs)
+	assert.Equal(t, "Codertocat", run.Trigger.Login)
+	assert.Equal(t, "21031067+Codertocat@users.noreply.github.com", run.Trigger.Email)
+	assert.Contains(t, run.Trigger.Reason, "a1b2c3d4-0001")
+
+	// run is saved before delivery is acknowledged
+	pipelineRun, err := pipelines.GetPipelineRun(context.Background(), "pipeline1", run.RunId)
+	require.NoError(t, err)
+	assert.Equal(t, run.Inputs, pipelineRun.Inputs)
+	assert.Equal(t, "Codertocat", pipelineRun.Trigger.Login)
+
+	triggered, err := audit.Latest(context.Background(), pipelines.AUDIT_TRIGGERED, map[string]string{"Pipeline": "pipeline1", "PipelineRun": run.RunId})
+	require.NoError(t, err)
+	assert.Equal(t, "Codertocat", triggered.Actor)
+	assert.Equal(t, pipelines.SOURCE_WEBHOOK, triggered.Source)
+
+	// redelivery of the same event must not start another run
+	resp = deliverWithId(t, handler, "push", "push_main.json", testSecret, "a1b2c3d4-0001")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, runs.get(), 1)
+}
+
+func TestTriggerConcurrentRedelivery(t *testing.T) {
+	setupStore(t)
+	saveTriggerPipeline(t, "pipeline1", pipelines.Trigger{Event: pipelines.TRIGGER_PUSH, Repo: "org1/repo1"})
+
+	runs := &triggeredRuns{}
+	handler := &Handler{Secret: testSecret, RunPipeline: runs.run}
+
+	// github redelivers while the first delivery is still being handled
+	codes := make([]int, 8)
+	var deliveries sync.WaitGroup
+	for i := range codes {
+		deliveries.Add(1)
+		go func(i int) {
+			defer deliveries.Done()
+			codes[i] = deliverWithId(t, handler, "push", "push_main.json", testSecret, "a1b2c3d4-0006").Code
+		}(i)
+	}
+	deliveries.Wait()
+
+	accepted := 0
+	for _, code := range codes {
+		if code == http.StatusAccepted {
+			accepted++
+			continue
+		}
+		assert.Equal(t, http.StatusNoContent, code)
+	}
+	assert.Equal(t, 1, accepted)
+	require.Eventually(t, func() bool { return len(runs.get()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	handler.Wait()
+
+	pipelineRuns, err := pipelines.GetPipelineRunsN(context.Background(), "pipeline1", 10)
+	require.NoError(t, err)
+	assert.Len(t, pipelineRuns, 1)
+}
+
+func TestTriggerTagReleasePullRequest(t *testing.T) {
+	setupStore(t)
+	saveTriggerPipeline(t, "tags", pipelines.Trigger{Event: pipelines.TRIGGER_TAG, Repo: "org1/repo1", Tag: "v*", Inputs: map[string]string{"version": "${{ event.ref_name }}"}})
+	saveTriggerPipeline(t, "releases", pipelines.Trigger{Event: pipelines.TRIGGER_RELEASE, Repo: "org1/repo1", Inputs: map[string]string{"version": "${{ event.release.tag_name }}"}})
+	saveTriggerPipeline(t, "merges", pipelines.Trigger{Event: pipelines.TRIGGER_PULL_REQUEST, Repo: "org1/repo1", Inputs: map[string]string{"version": "${{ event.pull_request.merge_commit_sha }}", "pr": "${{ event.number }}"}})
+	saveTriggerPipeline(t, "missing", pipelines.Trigger{Event: pipelines.TRIGGER_RELEASE, Repo: "org1/repo1", Inputs: map[string]string{"version": "${{ event.release.unknown }}"}})
+
+	runs := &triggeredRuns{}
+	handler := &Handler{Secret: testSecret, RunPipeline: runs.run}
+
+	require.Equal(t, http.StatusAccepted, deliverWithId(t, handler, "push", "push_tag.json", testSecret, "a1b2c3d4-0002").Code)
+	require.Equal(t, http.StatusAccepted, deliverWithId(t, handler, "release", "release_published.json", testSecret, "a1b2c3d4-0003").Code)
+	require.Equal(t, http.StatusAccepted, deliverWithId(t, handler, "pull_request", "pull_request_merged.json", testSecret, "a1b2c3d4-0004").Code)
+	require.Eventually(t, func() bool { return len(runs.get()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	inputs := make(map[string]map[string]string)
+	for _, run := range runs.get() {
+		inputs[run.Pipeline] = run.Inputs
+	}
+	assert.Equal(t, map[string]string{"version": "v1.2.0"}, inputs["tags"])
+	assert.Equal(t, map[string]string{"version": "v1.2.0"}, inputs["releases"])
+	assert.Equal(t, map[string]string{"version": "c4295bd74fb0f4fda03689c3df3f2803b658fd85", "pr": "2"}, inputs["merges"])
+	assert.NotContains(t, inputs, "missing")
+}
+
+func TestTriggerRunsCancelledWithServer(t *testing.T) {
+	setupStore(t)
+	saveTriggerPipeline(t, "pipeline1", pipelines.Trigger{Event: pipelines.TRIGGER_PUSH, Repo: "org1/repo1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	handler := &Handler{Secret: testSecret, Context: ctx, RunPipeline: func(ctx context.Context, name, runId string, inputs map[string]string, templateValues map[string]string, trigger pipelines.TriggerMetadata, force bool) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	require.Equal(t, http.StatusAccepted, deliverWithId(t, handler, "push", "push_main.json", testSecret, "a1b2c3d4-0005").Code)
+	<-started
+
+	cancel()
+	handler.Wait()
+}
@@ -0,0 +1,54 @@
+package workflows
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nixmade/pippy/github"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// FixBranch prefix of branches pippy commits rewritten workflows to, one fix pull request is open per repo
+	FixBranch = "pippy/workflow-fix"
+
+	fixTitle = "Configure workflows for pippy"
+)
+
+// FixRepoWorkflows opens a pull request with pippy ready versions of workflows at paths,
+// an already open fix pull request is returned instead with opened false
+func FixRepoWorkflows(c github.Client, orgRepo string, paths []string) (*github.PullRequest, bool, error) {
+	orgRepoSlice := strings.SplitN(orgRepo, "/", 2)
+	org := orgRepoSlice[0]
+	repo := orgRepoSlice[1]
+
+	pull, err := c.FindPullRequest(org, repo, FixBranch)
+	if err != nil {
+		return nil, false, err
+	}
+	if pull != nil {
+		return pull, false, nil
+	}
+
+	files := make(map[string]string)
+	var body strings.Builder
+	body.WriteString("Adds `pippy_run_id` to `workflow_dispatch` inputs and `run-name` so pippy can dispatch these workflows and find the runs it started.\n\n")
+	for _, path := range paths {
+		_, newFile, err := c.ValidateWorkflowFull(org, repo, path)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to rewrite workflow %s: %w", path, err)
+		}
+		files[path] = newFile
+		body.WriteString("- `" + path + "`\n")
+	}
+
+	// each fix gets its own branch, branches left behind by closed pull requests are never rewritten
+	branch := FixBranch + "-" + uuid.NewString()[:8]
+	pull, err = c.CreatePullRequest(org, repo, branch, fixTitle, body.String(), files)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return pull, true, nil
+}
@@ -0,0 +1,140 @@
+package workflows
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/github/githubtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	pushWorkflow = `name: Build
+on: [push]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: make
+`
+	inputsWorkflow = `name: Deploy
+on:
+  workflow_dispatch:
+    inputs:
+      version:
+        type: string
+        description: version to deploy
+        required: true
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo ${{ inputs.version }}
+`
+	readyWorkflow = `name: Release
+run-name: Release - ${{ inputs.pippy_run_id }}
+on:
+  workflow_dispatch:
+    inputs:
+      pippy_run_id:
+        type: string
+jobs:
+  release:
+    runs-on: ubuntu-latest
+    steps:
+      - run: make release
+`
+)
+
+func setupFixTest(t *testing.T) *githubtest.Server {
+	server := githubtest.NewServer(t)
+	server.AddRepo("org1/repo1", true)
+	for path, content := range map[string]string{
+		".github/workflows/build.yml":   pushWorkflow,
+		".github/workflows/deploy.yml":  inputsWorkflow,
+		".github/workflows/release.yml": readyWorkflow,
+	} {
+		_, err := server.AddWorkflow("org1/repo1", path, content, nil)
+		require.NoError(t, err)
+	}
+	return server
+}
+
+func TestFixRepoWorkflows(t *testing.T) {
+	server := setupFixTest(t)
+
+	var failed []string
+	for _, path := range []string{".github/workflows/build.yml", ".github/workflows/deploy.yml", ".github/workflows/release.yml"} {
+		changes, _, err := github.DefaultClient.ValidateWorkflow("org1", "repo1", path)
+		require.NoError(t, err)
+		if len(changes) > 0 {
+			failed = append(failed, path)
+		}
+	}
+	assert.ElementsMatch(t, []string{".github/workflows/build.yml", ".github/workflows/deploy.yml"}, failed)
+
+	pull, opened, err := FixRepoWorkflows(github.DefaultClient, "org1/repo1", failed)
+	require.NoError(t, err)
+	assert.True(t, opened)
+	assert.True(t, strings.HasPrefix(pull.Branch, FixBranch+"-"), pull.Branch)
+	assert.NotEmpty(t, pull.Url)
+
+	pulls := server.PullRequests("org1/repo1")
+	require.Len(t, pulls, 1)
+	assert.Equal(t, "main", pulls[0].Base)
+	assert.Equal(t, pull.Branch, pulls[0].Head)
+	assert.Contains(t, pulls[0].Body, ".github/workflows/deploy.yml")
+
+	for _, path := range failed {
+		content, ok := server.File("org1/repo1", pull.Branch, path)
+		require.True(t, ok)
+
+		definition := struct {
+			RunName string `yaml:"run-name"`
+			On      struct {
+				WorkflowDispatch struct {
+					Inputs map[string]map[string]interface{} `yaml:"inputs"`
+				} `yaml:"workflow_dispatch"`
+			} `yaml:"on"`
+		}{}
+		require.NoError(t, yaml.Unmarshal([]byte(content), &definition))
+		assert.Contains(t, definition.RunName, "inputs.pippy_run_id")
+		assert.Equal(t, "string", definition.On.WorkflowDispatch.Inputs["pippy_run_id"]["type"])
+
+		// default branch is untouched until pull request is merged
+		original, ok := server.File("org1/repo1", "", path)
+		require.True(t, ok)
+		assert.NotEqual(t, original, content)
+	}
+
+	deploy, ok := server.File("org1/repo1", pull.Branch, ".github/workflows/deploy.yml")
+	require.True(t, ok)
+	assert.Contains(t, deploy, "description: version to deploy")
+
+	// open fix pull request is not duplicated
+	existing, opened, err := FixRepoWorkflows(github.DefaultClient, "org1/repo1", failed)
+	require.NoError(t, err)
+	assert.False(t, opened)
+	assert.Equal(t, pull.Url, existing.Url)
+	assert.Len(t, server.PullRequests("org1/repo1"), 1)
+
+	// branch left behind by a closed pull request is left untouched
+	server.ClosePullRequest("org1/repo1", pull.Number)
+	reopened, opened, err := FixRepoWorkflows(github.DefaultClient, "org1/repo1", failed)
+	require.NoError(t, err)
+	assert.True(t, opened)
+	assert.NotEqual(t, pull.Url, reopened.Url)
+	assert.NotEqual(t, pull.Branch, reopened.Branch)
+	assert.Len(t, server.PullRequests("org1/repo1"), 2)
+	stale, ok := server.File("org1/repo1", pull.Branch, ".github/workflows/deploy.yml")
+	require.True(t, ok)
+	assert.Equal(t, deploy, stale)
+
+	// existing branch is never reset
+	_, err = github.DefaultClient.CreatePullRequest("org1", "repo1", pull.Branch, fixTitle, "", map[string]string{})
+	require.Error(t, err)
+}
@@ -3,13 +3,15 @@ package workflows
 import (
 	"context"
 	"fmt"
+	"os"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
 
 	"github.com/charmbracelet/bubbles/list"
-	"github.com/charmbracelet/huh"
 	"github.com/urfave/cli/v3"
 )
 
@@ -22,9 +24,8 @@ func Command() *cli.Command {
 				Name:  "list",
 				Usage: "list workflows for a repo",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := RunListWorkflows(github.DefaultClient, c.String("type")); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := RunListWorkflows(github.DefaultClient, c.String("type"), c.StringSlice("repo"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -42,15 +43,19 @@ func Command() *cli.Command {
 							return fmt.Errorf("please provide a valid value in %s", strings.Join(validValues, ","))
 						},
 					},
+					&cli.StringSliceFlag{
+						Name:     "repo",
+						Usage:    "org/repo or glob of repos in repo type, skips choosing repos, --repo 'nixmade/*'",
+						Required: false,
+					},
 				},
 			},
 			{
 				Name:  "validate",
 				Usage: "checks if the repo has correct configuration for pippy to function correctly",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					if err := RunValidateRepoWorkflows(github.DefaultClient, c.String("type")); err != nil {
-						fmt.Printf("%v\n", err)
-						return err
+					if err := RunValidateRepoWorkflows(github.DefaultClient, c.String("type"), c.StringSlice("repo"), c.Bool("fix"), c.String("output")); err != nil {
+						return helpers.PrintError(c.String("output"), err)
 					}
 					return nil
 				},
@@ -68,41 +73,74 @@ func Command() *cli.Command {
 							return fmt.Errorf("please provide a valid value in %s", strings.Join(validValues, ","))
 						},
 					},
+					&cli.StringSliceFlag{
+						Name:     "repo",
+						Usage:    "org/repo or glob of repos in repo type, skips choosing repos, --repo 'nixmade/*'",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "fix",
+						Usage:    "open a pull request with pippy ready workflows for every repo failing validation",
+						Required: false,
+					},
 				},
 			},
 		},
 	}
 }
 
-func RunListWorkflows(c github.Client, repoType string) error {
-	var orgRepo string
-	repos, err := GetRepos(repoType)
-	if err != nil {
-		return err
-	}
-	if err := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[string]().
-				Options(huh.NewOptions(repos...)...).
-				Title("Choose a repo").
-				Description("workflows for selected repo").
-				Value(&orgRepo),
-		)).Run(); err != nil {
-		return err
-	}
+type workflowOutput struct {
+	Repo  string `json:"repo"`
+	Name  string `json:"name"`
+	Id    int64  `json:"id"`
+	State string `json:"state"`
+	Path  string `json:"path"`
+	Url   string `json:"url"`
+}
 
-	orgRepoSlice := strings.SplitN(orgRepo, "/", 2)
-	org := orgRepoSlice[0]
-	repo := orgRepoSlice[1]
-	workflowItems, err := c.ListWorkflows(org, repo)
+// RunListWorkflows opens interactive list for chosen repo unless output or repos are provided
+func RunListWorkflows(c github.Client, repoType string, patterns []string, output string) error {
+	orgRepos, err := selectRepos(c, repoType, patterns, output, false)
 	if err != nil {
 		return err
 	}
 
-	var listItems []list.Item
-	for _, workflowItem := range workflowItems {
-		listItems = append(listItems, workflowItem)
+	if output == "" && len(patterns) <= 0 {
+		orgRepoSlice := strings.SplitN(orgRepos[0], "/", 2)
+		org := orgRepoSlice[0]
+		repo := orgRepoSlice[1]
+		workflowItems, err := c.ListWorkflows(org, repo)
+		if err != nil {
+			return err
+		}
+
+		var listItems []list.Item
+		for _, workflowItem := range workflowItems {
+			listItems = append(listItems, workflowItem)
+		}
+
+		return Run(org, repo, listItems)
 	}
 
-	return Run(org, repo, listItems)
+	workflowOutputs := []workflowOutput{}
+	rows := [][]string{}
+	for _, orgRepo := range orgRepos {
+		orgRepoSlice := strings.SplitN(orgRepo, "/", 2)
+		workflowItems, err := c.ListWorkflows(orgRepoSlice[0], orgRepoSlice[1])
+		if err != nil {
+			return err
+		}
+		for _, workflowItem := range workflowItems {
+			workflowOutputs = append(workflowOutputs, workflowOutput{
+				Repo:  orgRepo,
+				Name:  workflowItem.Name,
+				Id:    workflowItem.Id,
+				State: workflowItem.State,
+				Path:  workflowItem.Path,
+				Url:   workflowItem.Url,
+			})
+			rows = append(rows, []string{orgRepo, workflowItem.Name, strconv.FormatInt(workflowItem.Id, 10), workflowItem.State, workflowItem.Path})
+		}
+	}
+	return helpers.WriteOutput(os.Stdout, output, workflowOutputs, []string{"REPO", "WORKFLOW", "ID", "STATE", "PATH"}, rows)
 }
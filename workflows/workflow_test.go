@@ -0,0 +1,146 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// captureStdout output written to stdout by fn
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	fnErr := fn()
+	require.NoError(t, writer.Close())
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(out), fnErr
+}
+
+func TestSelectRepos(t *testing.T) {
+	server := setupFixTest(t)
+	server.AddRepo("org1/repo2", false)
+	server.AddRepo("org2/repo1", false)
+
+	orgRepos, err := selectRepos(github.DefaultClient, "owner", []string{"org1/*"}, helpers.OutputJSON, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org1/repo1", "org1/repo2"}, orgRepos)
+
+	// exact names are used without listing repos
+	orgRepos, err = selectRepos(github.DefaultClient, "owner", []string{"org3/repo1", "*/repo1"}, helpers.OutputJSON, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org3/repo1", "org1/repo1", "org2/repo1"}, orgRepos)
+
+	_, err = selectRepos(github.DefaultClient, "owner", []string{"org9/*"}, helpers.OutputJSON, true)
+	assert.EqualError(t, err, "no owner repos match org9/*")
+
+	_, err = selectRepos(github.DefaultClient, "owner", []string{"repo1"}, helpers.OutputJSON, true)
+	assert.EqualError(t, err, "repo repo1 is not in org/repo format")
+
+	_, err = selectRepos(github.DefaultClient, "owner", nil, helpers.OutputJSON, true)
+	assert.EqualError(t, err, "--repo is required with --output")
+}
+
+func TestRunValidateRepoWorkflowsOutput(t *testing.T) {
+	server := setupFixTest(t)
+	server.AddRepo("org1/repo2", false)
+	_, err := server.AddWorkflow("org1/repo2", ".github/workflows/release.yml", readyWorkflow, nil)
+	require.NoError(t, err)
+
+	out, err := captureStdout(t, func() error {
+		return RunValidateRepoWorkflows(github.DefaultClient, "owner", []string{"org1/*"}, false, helpers.OutputJSON)
+	})
+	assert.EqualError(t, err, "2 workflows failed validation")
+
+	var validations []WorkflowValidation
+	require.NoError(t, json.Unmarshal([]byte(out), &validations))
+	require.Len(t, validations, 4)
+	passed := map[string]bool{}
+	for _, validation := range validations {
+		passed[validation.Repo+":"+validation.Path] = validation.Passed
+		if !validation.Passed {
+			assert.NotEmpty(t, validation.Changes)
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"org1/repo1:.github/workflows/build.yml":   false,
+		"org1/repo1:.github/workflows/deploy.yml":  false,
+		"org1/repo1:.github/workflows/release.yml": true,
+		"org1/repo2:.github/workflows/release.yml": true,
+	}, passed)
+
+	out, err = captureStdout(t, func() error {
+		return RunValidateRepoWorkflows(github.DefaultClient, "owner", []string{"org1/repo2"}, false, helpers.OutputYAML)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "passed: true")
+
+	// failing workflows are reported with fix pull request, validation still fails until it is merged
+	out, err = captureStdout(t, func() error {
+		return RunValidateRepoWorkflows(github.DefaultClient, "owner", []string{"org1/repo1"}, true, helpers.OutputJSON)
+	})
+	assert.EqualError(t, err, "2 workflows failed validation")
+	require.NoError(t, json.Unmarshal([]byte(out), &validations))
+	pulls := server.PullRequests("org1/repo1")
+	require.Len(t, pulls, 1)
+	for _, validation := range validations {
+		if validation.Passed {
+			assert.Empty(t, validation.PullRequest)
+		} else {
+			assert.Equal(t, pulls[0].Url, validation.PullRequest)
+		}
+	}
+}
+
+func TestPrintErrorOutput(t *testing.T) {
+	out, err := captureStdout(t, func() error {
+		return helpers.PrintError(helpers.OutputJSON, fmt.Errorf("2 workflows failed validation"))
+	})
+	var printedErr *helpers.PrintedError
+	require.ErrorAs(t, err, &printedErr)
+	assert.EqualError(t, err, "2 workflows failed validation")
+	var errorOutput helpers.ErrorOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &errorOutput))
+	assert.Equal(t, "2 workflows failed validation", errorOutput.Error)
+
+	out, _ = captureStdout(t, func() error {
+		return helpers.PrintError(helpers.OutputYAML, fmt.Errorf("2 workflows failed validation"))
+	})
+	assert.Equal(t, "error: 2 workflows failed validation\n", out)
+}
+
+func TestValidateCommandOutput(t *testing.T) {
+	setupFixTest(t)
+
+	cmd := &cli.Command{
+		Name:     "pippy",
+		Flags:    []cli.Flag{helpers.OutputFlag()},
+		Commands: []*cli.Command{Command()},
+	}
+	out, err := captureStdout(t, func() error {
+		return cmd.Run(context.Background(), []string{"pippy", "--output", "json", "workflow", "validate", "--repo", "org1/*"})
+	})
+	var printedErr *helpers.PrintedError
+	require.ErrorAs(t, err, &printedErr)
+	assert.EqualError(t, err, "2 workflows failed validation")
+
+	// failed validation changes only exit status, stdout is a single json document
+	var validations []WorkflowValidation
+	require.NoError(t, json.Unmarshal([]byte(out), &validations))
+	assert.Len(t, validations, 3)
+}
@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/nixmade/pippy/github"
+	"github.com/nixmade/pippy/helpers"
+	"github.com/nixmade/pippy/repos"
 )
 
 var (
@@ -78,58 +81,204 @@ func GetRepos(repoType string) ([]string, error) {
 	return titles, nil
 }
 
-func RunValidateRepoWorkflows(c github.Client, repoType string) error {
-	var orgRepos []string
-	repos, err := GetRepos(repoType)
+// WorkflowValidation result of validating workflow in repo, changes are required for pippy to dispatch it
+type WorkflowValidation struct {
+	Repo        string   `json:"repo"`
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Passed      bool     `json:"passed"`
+	Changes     []string `json:"changes,omitempty"`
+	PullRequest string   `json:"pull_request,omitempty"`
+}
+
+// selectRepos repos matching patterns, repos are chosen interactively when no patterns are provided
+func selectRepos(c github.Client, repoType string, patterns []string, output string, multiple bool) ([]string, error) {
+	if len(patterns) > 0 {
+		return repos.MatchRepos(c, repoType, patterns)
+	}
+	if output != "" {
+		return nil, fmt.Errorf("--repo is required with --output")
+	}
+
+	titles, err := GetRepos(repoType)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	if !multiple {
+		var orgRepo string
+		if err := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Options(huh.NewOptions(titles...)...).
+					Title("Choose a repo").
+					Description("workflows for selected repo").
+					Value(&orgRepo),
+			)).Run(); err != nil {
+			return nil, err
+		}
+		return []string{orgRepo}, nil
+	}
+
+	var orgRepos []string
 	if err := huh.NewForm(
 		huh.NewGroup(
 			huh.NewMultiSelect[string]().
-				Options(huh.NewOptions(repos...)...).
+				Options(huh.NewOptions(titles...)...).
 				Title("Choose single/multiple repo").
 				Description("use spacebar to select, workflows for selected repos will be validated next").
 				Value(&orgRepos),
 		)).Run(); err != nil {
+		return nil, err
+	}
+	return orgRepos, nil
+}
+
+// RunValidateRepoWorkflows validates workflows in repos matching patterns, fails when any workflow fails validation
+func RunValidateRepoWorkflows(c github.Client, repoType string, patterns []string, fix bool, output string) error {
+	orgRepos, err := selectRepos(c, repoType, patterns, output, true)
+	if err != nil {
 		return err
 	}
 
+	var validations []WorkflowValidation
+	var pullRequests []string
 	for _, orgRepo := range orgRepos {
-		orgRepoSlice := strings.SplitN(orgRepo, "/", 2)
-		org := orgRepoSlice[0]
-		repo := orgRepoSlice[1]
-		workflows, err := c.ListWorkflows(org, repo)
+		repoValidations, err := ValidateRepoWorkflows(c, orgRepo)
 		if err != nil {
 			return err
 		}
-
-		if len(workflows) <= 0 {
-			fmt.Println(currentStyle.Render("\nNo workflows found in repo " + orgRepo + "\n"))
-			continue
+		if output == "" {
+			showRepoValidations(orgRepo, repoValidations)
 		}
 
-		fmt.Println(currentStyle.Render("\nValidations for repo " + orgRepo + "\n"))
-		for _, workflow := range workflows {
-			if workflow.Path == "" {
-				continue
-			}
-			if changes, _, err := c.ValidateWorkflow(org, repo, workflow.Path); err != nil {
+		failed := failedPaths(repoValidations)
+		if fix && len(failed) > 0 {
+			pull, opened, err := FixRepoWorkflows(c, orgRepo, failed)
+			if err != nil {
 				return err
-			} else {
-				if len(changes) > 0 {
-					fmt.Println(crossMark.Render() + " " + failedStyle.Render(workflow.Name) + "(" + failedStyle.Render(workflow.Path) + descriptionStyle.Render(") failed validation, make changes in corresponding sections:\n"))
-					for i, change := range changes {
-						fmt.Println(currentStyle.Render("#" + strconv.Itoa(i+1) + "\n"))
-						fmt.Println(descriptionStyle.Render(strings.ReplaceAll(change, "\"", "")))
-					}
+			}
+			for i := range repoValidations {
+				if !repoValidations[i].Passed {
+					repoValidations[i].PullRequest = pull.Url
+				}
+			}
+			if output == "" {
+				if opened {
+					fmt.Println(checkMark.Render() + " " + doneStyle.Render("Opened fix pull request "+pull.Url) + "\n")
+					pullRequests = append(pullRequests, pull.Url)
 				} else {
-					fmt.Println(checkMark.Render() + " " + doneStyle.Render(workflow.Name) + "(" + doneStyle.Render(workflow.Path) + ") passed validation")
+					fmt.Println(currentStyle.Render("Skipping repo " + orgRepo + ", fix pull request already open " + pull.Url + "\n"))
 				}
 			}
 		}
-		fmt.Println(currentStyle.Render("End of Validations for repo " + orgRepo + "\n"))
+
+		validations = append(validations, repoValidations...)
+	}
+
+	if len(pullRequests) > 0 {
+		fmt.Println(currentStyle.Render("Fix pull requests, merge them for pippy to dispatch workflows:"))
+		for _, url := range pullRequests {
+			fmt.Println(descriptionStyle.Render(url))
+		}
+	}
+
+	if output != "" {
+		rows := [][]string{}
+		for _, validation := range validations {
+			status := "passed"
+			if !validation.Passed {
+				status = "failed"
+			}
+			rows = append(rows, []string{validation.Repo, validation.Name, validation.Path, status, validation.PullRequest})
+		}
+		if validations == nil {
+			validations = []WorkflowValidation{}
+		}
+		if err := helpers.WriteOutput(os.Stdout, output, validations, []string{"REPO", "WORKFLOW", "PATH", "STATUS", "PULL REQUEST"}, rows); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, validation := range validations {
+		if !validation.Passed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d workflows failed validation", failed)
+		if output != "" {
+			// failures are in the written output, keeps json or yaml a single document
+			return &helpers.PrintedError{Err: err}
+		}
+		return err
 	}
 
 	return nil
 }
+
+// ValidateRepoWorkflows validates every workflow in repo
+func ValidateRepoWorkflows(c github.Client, orgRepo string) ([]WorkflowValidation, error) {
+	orgRepoSlice := strings.SplitN(orgRepo, "/", 2)
+	if len(orgRepoSlice) != 2 {
+		return nil, fmt.Errorf("repo %s is not in org/repo format", orgRepo)
+	}
+	org := orgRepoSlice[0]
+	repo := orgRepoSlice[1]
+	workflows, err := c.ListWorkflows(org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var validations []WorkflowValidation
+	for _, workflow := range workflows {
+		if workflow.Path == "" {
+			continue
+		}
+		changes, _, err := c.ValidateWorkflow(org, repo, workflow.Path)
+		if err != nil {
+			return nil, err
+		}
+		validations = append(validations, WorkflowValidation{
+			Repo:    orgRepo,
+			Name:    workflow.Name,
+			Path:    workflow.Path,
+			Passed:  len(changes) <= 0,
+			Changes: changes,
+		})
+	}
+
+	return validations, nil
+}
+
+func failedPaths(validations []WorkflowValidation) []string {
+	var failed []string
+	for _, validation := range validations {
+		if !validation.Passed {
+			failed = append(failed, validation.Path)
+		}
+	}
+	return failed
+}
+
+func showRepoValidations(orgRepo string, validations []WorkflowValidation) {
+	if len(validations) <= 0 {
+		fmt.Println(currentStyle.Render("\nNo workflows found in repo " + orgRepo + "\n"))
+		return
+	}
+
+	fmt.Println(currentStyle.Render("\nValidations for repo " + orgRepo + "\n"))
+	for _, validation := range validations {
+		if !validation.Passed {
+			fmt.Println(crossMark.Render() + " " + failedStyle.Render(validation.Name) + "(" + failedStyle.Render(validation.Path) + descriptionStyle.Render(") failed validation, make changes in corresponding sections:\n"))
+			for i, change := range validation.Changes {
+				fmt.Println(currentStyle.Render("#" + strconv.Itoa(i+1) + "\n"))
+				fmt.Println(descriptionStyle.Render(strings.ReplaceAll(change, "\"", "")))
+			}
+		} else {
+			fmt.Println(checkMark.Render() + " " + doneStyle.Render(validation.Name) + "(" + doneStyle.Render(validation.Path) + ") passed validation")
+		}
+	}
+	fmt.Println(currentStyle.Render("End of Validations for repo " + orgRepo + "\n"))
+}